@@ -0,0 +1,64 @@
+package v1
+
+import (
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// AnnotationKeyBlobRole annotates each blob descriptor in an artifact
+// manifest with the blob's role in the artifact, which distinguishes blobs
+// that share a media type.
+const AnnotationKeyBlobRole = "io.operatorframework.blob.role"
+
+const (
+	BlobRoleMetadata      = "metadata"
+	BlobRoleDescription   = "description"
+	BlobRoleIcon          = "icon"
+	BlobRolePackageIndex  = "package-index"
+	BlobRoleUpgradeEdges  = "upgrade-edges"
+	BlobRoleUpgradeSkips  = "upgrade-skips"
+	BlobRoleProperties    = "properties"
+	BlobRoleConstraints   = "constraints"
+	BlobRoleRelatedImages = "related-images"
+	BlobRoleContent       = "content"
+)
+
+// blobAnnotations returns the descriptor annotations of a blob with role,
+// which generic OCI tools write to a file named title.
+func blobAnnotations(title, role string) map[string]string {
+	return map[string]string{
+		ocispec.AnnotationTitle: title,
+		AnnotationKeyBlobRole:   role,
+	}
+}
+
+// BlobRole returns the role of the blob described by desc. Blobs pushed
+// without a role annotation are assigned a role by media type.
+func BlobRole(desc ocispec.Descriptor) string {
+	if role, ok := desc.Annotations[AnnotationKeyBlobRole]; ok {
+		return role
+	}
+	switch desc.MediaType {
+	case MediaTypeCatalogMetadata, MediaTypePackageMetadata, MediaTypeChannelMetadata, MediaTypeBundleMetadata:
+		return BlobRoleMetadata
+	case MediaTypeDescription:
+		return BlobRoleDescription
+	case MediaTypePackageIndex:
+		return BlobRolePackageIndex
+	case MediaTypeUpgradeEdges, MediaTypeChannelUpgradeEdges:
+		return BlobRoleUpgradeEdges
+	case MediaTypeUpgradeSkips:
+		return BlobRoleUpgradeSkips
+	case MediaTypeProperties:
+		return BlobRoleProperties
+	case MediaTypeConstraints:
+		return BlobRoleConstraints
+	case MediaTypeRelatedImages:
+		return BlobRoleRelatedImages
+	case MediaTypeBundleContent:
+		return BlobRoleContent
+	}
+	if IsIconMediaType(desc.MediaType) {
+		return BlobRoleIcon
+	}
+	return ""
+}
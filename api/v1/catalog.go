@@ -0,0 +1,63 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// SchemaCatalogMetadata is the schema of the file-based catalog object that
+// holds the catalog's metadata and icon. It belongs to no package.
+const SchemaCatalogMetadata = "io.operatorframework.catalog"
+
+type fbcCatalogMetadata struct {
+	Schema string `json:"schema"`
+	CatalogMetadata
+	Icon *declcfg.Icon `json:"icon,omitempty"`
+}
+
+// LoadCatalogIcon loads the icon of the catalog described by metadataFile,
+// resolved as by LoadCatalog relative to the file's directory. It returns
+// nil if the catalog has no icon.
+func LoadCatalogIcon(metadataFile string) (*Icon, error) {
+	return loadIcon(filepath.Dir(metadataFile), metadataFile)
+}
+
+// metadataToFBC returns the catalog metadata object for c, or nil if c has
+// neither metadata nor an icon.
+func (c Catalog) metadataToFBC() (*declcfg.Meta, error) {
+	if c.Metadata == (CatalogMetadata{}) && c.Icon == nil {
+		return nil, nil
+	}
+	m := fbcCatalogMetadata{Schema: SchemaCatalogMetadata, CatalogMetadata: c.Metadata}
+	if c.Icon != nil {
+		m.Icon = &declcfg.Icon{Data: c.Icon.ImageData, MediaType: c.Icon.ImageMediaType}
+	}
+	blob, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return &declcfg.Meta{Schema: SchemaCatalogMetadata, Blob: blob}, nil
+}
+
+// fbcCatalogMetadataFor returns the catalog metadata and icon from the
+// catalog metadata object in others, if there is one.
+func fbcCatalogMetadataFor(others []declcfg.Meta) (CatalogMetadata, *Icon, error) {
+	for _, o := range others {
+		if o.Schema != SchemaCatalogMetadata {
+			continue
+		}
+		var m fbcCatalogMetadata
+		if err := json.Unmarshal(o.Blob, &m); err != nil {
+			return CatalogMetadata{}, nil, fmt.Errorf("parse %s: %v", SchemaCatalogMetadata, err)
+		}
+		var icon *Icon
+		if m.Icon != nil {
+			icon = &Icon{ImageData: m.Icon.Data, ImageMediaType: m.Icon.MediaType}
+		}
+		return m.CatalogMetadata, icon, nil
+	}
+	return CatalogMetadata{}, nil, nil
+}
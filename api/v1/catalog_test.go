@@ -0,0 +1,62 @@
+package v1
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+func TestCatalogMetadataRoundTripFBC(t *testing.T) {
+	icon := &Icon{ImageData: []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ImageMediaType: MediaTypeIconSVG}
+	for _, tc := range []struct {
+		name    string
+		catalog Catalog
+		want    int
+	}{
+		{name: "empty", catalog: Catalog{}, want: 0},
+		{
+			name: "metadata and icon",
+			catalog: Catalog{
+				Metadata: CatalogMetadata{
+					DisplayName: "Example",
+					Publisher:   "Example, Inc.",
+					Description: "An example catalog",
+					Homepage:    "https://example.com",
+					Support:     "support@example.com",
+				},
+				Icon: icon,
+			},
+			want: 1,
+		},
+		{name: "icon only", catalog: Catalog{Icon: icon}, want: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := tc.catalog.ToFBC(context.Background(), "example.com/repo")
+			if err != nil {
+				t.Fatalf("ToFBC: %v", err)
+			}
+			if len(cfg.Others) != tc.want {
+				t.Fatalf("expected %d other objects, got %d", tc.want, len(cfg.Others))
+			}
+			c, err := catalogFromDeclarativeConfig(context.Background(), cfg, nil, false, nil)
+			if err != nil {
+				t.Fatalf("import: %v", err)
+			}
+			if c.Metadata != tc.catalog.Metadata {
+				t.Errorf("expected metadata %+v, got %+v", tc.catalog.Metadata, c.Metadata)
+			}
+			if !reflect.DeepEqual(c.Icon, tc.catalog.Icon) {
+				t.Errorf("expected icon %v, got %v", tc.catalog.Icon, c.Icon)
+			}
+		})
+	}
+}
+
+func TestFBCCatalogMetadataForInvalid(t *testing.T) {
+	_, _, err := fbcCatalogMetadataFor([]declcfg.Meta{{Schema: SchemaCatalogMetadata, Blob: []byte(`{"support": 1}`)}})
+	if err == nil {
+		t.Fatal("expected error for invalid catalog metadata")
+	}
+}
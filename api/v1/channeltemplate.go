@@ -0,0 +1,107 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+)
+
+// channelTemplate generates channels from promotion lists in a package's
+// channel-template.yaml. Bundles promoted to stable are also in fast and
+// candidate, and bundles promoted to fast are also in candidate. Each list
+// is split into one channel per major and/or minor version line, named
+// after the list and the line, e.g. "stable-v1" or "fast-v1.2". If neither
+// is generated, each list becomes a single channel named after it. Lists
+// use the entry syntax of selectBundles, and bundles matched by Exclude are
+// left out of every list.
+type channelTemplate struct {
+	GenerateMajorChannels bool `json:"generateMajorChannels"`
+	GenerateMinorChannels bool `json:"generateMinorChannels"`
+
+	Candidate []string `json:"candidate"`
+	Fast      []string `json:"fast"`
+	Stable    []string `json:"stable"`
+	Exclude   []string `json:"exclude"`
+}
+
+// loadGeneratedChannels returns the channels generated from the package's
+// channel-template.yaml, sorted by name, or nil if it does not exist.
+func loadGeneratedChannels(packageDir string, bundles []Bundle) ([]Channel, error) {
+	data, err := os.ReadFile(filepath.Join(packageDir, "channel-template.yaml"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var tmpl channelTemplate
+	if err := yaml.Unmarshal(data, &tmpl); err != nil {
+		return nil, err
+	}
+
+	stable, err := selectBundles(tmpl.Stable, tmpl.Exclude, bundles)
+	if err != nil {
+		return nil, fmt.Errorf("stable: %v", err)
+	}
+	fast, err := selectBundles(tmpl.Fast, tmpl.Exclude, bundles)
+	if err != nil {
+		return nil, fmt.Errorf("fast: %v", err)
+	}
+	candidate, err := selectBundles(tmpl.Candidate, tmpl.Exclude, bundles)
+	if err != nil {
+		return nil, fmt.Errorf("candidate: %v", err)
+	}
+	fast = append(fast, stable...)
+	candidate = append(candidate, fast...)
+
+	byName := map[string][]Bundle{}
+	for _, l := range []struct {
+		name    string
+		bundles []Bundle
+	}{
+		{"candidate", candidate},
+		{"fast", fast},
+		{"stable", stable},
+	} {
+		for _, b := range l.bundles {
+			v := b.Metadata.Version
+			var names []string
+			if tmpl.GenerateMajorChannels {
+				names = append(names, fmt.Sprintf("%s-v%d", l.name, v.Major))
+			}
+			if tmpl.GenerateMinorChannels {
+				names = append(names, fmt.Sprintf("%s-v%d.%d", l.name, v.Major, v.Minor))
+			}
+			if len(names) == 0 {
+				names = append(names, l.name)
+			}
+			for _, name := range names {
+				byName[name] = appendUniqueBundle(byName[name], b)
+			}
+		}
+	}
+
+	channels := make([]Channel, 0, len(byName))
+	for name, chBundles := range byName {
+		sort.SliceStable(chBundles, func(i, j int) bool { return bundleLess(chBundles[i], chBundles[j]) })
+		channels = append(channels, Channel{
+			Metadata: ChannelMetadata{Name: name},
+			Bundles:  chBundles,
+		})
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Metadata.Name < channels[j].Metadata.Name })
+	return channels, nil
+}
+
+func appendUniqueBundle(bundles []Bundle, b Bundle) []Bundle {
+	for _, existing := range bundles {
+		if existing.Metadata.VersionRelease() == b.Metadata.VersionRelease() {
+			return bundles
+		}
+	}
+	return append(bundles, b)
+}
@@ -0,0 +1,219 @@
+package v1
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func channelNamesAndBundles(channels []Channel) map[string][]string {
+	out := map[string][]string{}
+	for _, ch := range channels {
+		out[ch.Metadata.Name] = []string{}
+		for _, b := range ch.Bundles {
+			out[ch.Metadata.Name] = append(out[ch.Metadata.Name], b.Metadata.VersionRelease().String())
+		}
+	}
+	return out
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadGeneratedChannels(t *testing.T) {
+	bundles := testBundles("foo", "1.0.0-0", "1.1.0-0", "1.1.1-0", "2.0.0-0")
+	for _, tc := range []struct {
+		name     string
+		template string
+		want     map[string][]string
+		wantErr  string
+	}{
+		{
+			name:     "no template",
+			template: "",
+		},
+		{
+			name:     "one channel per list",
+			template: "candidate: [2.0.0]\nfast: [1.1.1]\nstable: [1.0.0, 1.1.0]\n",
+			want: map[string][]string{
+				"candidate": {"1.0.0-0", "1.1.0-0", "1.1.1-0", "2.0.0-0"},
+				"fast":      {"1.0.0-0", "1.1.0-0", "1.1.1-0"},
+				"stable":    {"1.0.0-0", "1.1.0-0"},
+			},
+		},
+		{
+			name:     "major channels",
+			template: "generateMajorChannels: true\nfast: [2.0.0]\nstable: [\">=1.0.0 <2.0.0\"]\n",
+			want: map[string][]string{
+				"candidate-v1": {"1.0.0-0", "1.1.0-0", "1.1.1-0"},
+				"candidate-v2": {"2.0.0-0"},
+				"fast-v1":      {"1.0.0-0", "1.1.0-0", "1.1.1-0"},
+				"fast-v2":      {"2.0.0-0"},
+				"stable-v1":    {"1.0.0-0", "1.1.0-0", "1.1.1-0"},
+			},
+		},
+		{
+			name:     "major and minor channels",
+			template: "generateMajorChannels: true\ngenerateMinorChannels: true\nstable: [1.0.0, 1.1.0, 1.1.1]\n",
+			want: map[string][]string{
+				"candidate-v1":   {"1.0.0-0", "1.1.0-0", "1.1.1-0"},
+				"candidate-v1.0": {"1.0.0-0"},
+				"candidate-v1.1": {"1.1.0-0", "1.1.1-0"},
+				"fast-v1":        {"1.0.0-0", "1.1.0-0", "1.1.1-0"},
+				"fast-v1.0":      {"1.0.0-0"},
+				"fast-v1.1":      {"1.1.0-0", "1.1.1-0"},
+				"stable-v1":      {"1.0.0-0", "1.1.0-0", "1.1.1-0"},
+				"stable-v1.0":    {"1.0.0-0"},
+				"stable-v1.1":    {"1.1.0-0", "1.1.1-0"},
+			},
+		},
+		{
+			name:     "exclude applies to every list",
+			template: "candidate: [\">=1.0.0\"]\nstable: [\">=1.0.0\"]\nexclude: [1.1.0]\n",
+			want: map[string][]string{
+				"candidate": {"1.0.0-0", "1.1.1-0", "2.0.0-0"},
+				"fast":      {"1.0.0-0", "1.1.1-0", "2.0.0-0"},
+				"stable":    {"1.0.0-0", "1.1.1-0", "2.0.0-0"},
+			},
+		},
+		{
+			name:     "unknown bundle",
+			template: "fast: [3.0.0]\n",
+			wantErr:  "fast: ",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.template != "" {
+				writeTestFiles(t, dir, map[string]string{"channel-template.yaml": tc.template})
+			}
+			channels, err := loadGeneratedChannels(dir, bundles)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want == nil {
+				if channels != nil {
+					t.Errorf("expected no channels, got %v", channelNamesAndBundles(channels))
+				}
+				return
+			}
+			if got := channelNamesAndBundles(channels); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected channels %v, got %v", tc.want, got)
+			}
+			for i := 1; i < len(channels); i++ {
+				if channels[i-1].Metadata.Name >= channels[i].Metadata.Name {
+					t.Errorf("expected channels sorted by name, got %q before %q", channels[i-1].Metadata.Name, channels[i].Metadata.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadChannels(t *testing.T) {
+	bundles := testBundles("foo", "1.0.0-0", "1.1.0-0")
+	for _, tc := range []struct {
+		name    string
+		files   map[string]string
+		want    map[string][]string
+		wantErr string
+	}{
+		{
+			name: "defined and generated channels",
+			files: map[string]string{
+				"channel-template.yaml":      "stable: [1.0.0]\n",
+				"channels/beta/channel.yaml": "name: beta\n",
+				"channels/beta/entries.yaml": "entries: [1.1.0]\n",
+			},
+			want: map[string][]string{
+				"beta":      {"1.1.0-0"},
+				"candidate": {"1.0.0-0"},
+				"fast":      {"1.0.0-0"},
+				"stable":    {"1.0.0-0"},
+			},
+		},
+		{
+			name: "generated channels only",
+			files: map[string]string{
+				"channel-template.yaml": "candidate: [1.1.0]\n",
+			},
+			want: map[string][]string{
+				"candidate": {"1.1.0-0"},
+			},
+		},
+		{
+			name: "generated channel is also defined",
+			files: map[string]string{
+				"channel-template.yaml":      "stable: [1.0.0]\n",
+				"channels/fast/channel.yaml": "name: fast\n",
+				"channels/fast/entries.yaml": "entries: [1.1.0]\n",
+			},
+			wantErr: `channel "fast" is both generated and defined`,
+		},
+		{
+			name:    "no channels",
+			wantErr: "no such file or directory",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFiles(t, dir, tc.files)
+			channels, err := loadChannels(dir, bundles)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := channelNamesAndBundles(channels); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected channels %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultChannel(t *testing.T) {
+	channels := []Channel{{Metadata: ChannelMetadata{Name: "candidate"}}, {Metadata: ChannelMetadata{Name: "stable"}}}
+	for _, tc := range []struct {
+		name           string
+		defaultChannel string
+		wantErr        string
+	}{
+		{name: "unset"},
+		{name: "known channel", defaultChannel: "stable"},
+		{name: "unknown channel", defaultChannel: "fast", wantErr: `default channel "fast" is not one of the package's channels ["candidate" "stable"]`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateDefaultChannel(tc.defaultChannel, channels)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// SchemaDeprecations is the schema of the file-based catalog object that
+// lists the deprecated package, channels and bundles of a package.
+const SchemaDeprecations = "olm.deprecations"
+
+// Deprecation marks a package, channel or bundle as deprecated. Deprecated
+// content remains installable, but users should be warned to move off it.
+type Deprecation struct {
+	Message string `json:"message"`
+}
+
+type fbcDeprecations struct {
+	Schema  string                `json:"schema"`
+	Package string                `json:"package"`
+	Entries []fbcDeprecationEntry `json:"entries"`
+}
+
+type fbcDeprecationEntry struct {
+	Reference fbcDeprecationReference `json:"reference"`
+	Message   string                  `json:"message"`
+}
+
+type fbcDeprecationReference struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name,omitempty"`
+}
+
+// bundleDeprecation returns the deprecation set by the
+// AnnotationKeyBundleDeprecation annotation in the bundle's
+// metadata/annotations.yaml, if any.
+func bundleDeprecation(annotations map[string]string) *Deprecation {
+	if msg, ok := annotations[AnnotationKeyBundleDeprecation]; ok {
+		return &Deprecation{Message: msg}
+	}
+	return nil
+}
+
+// DeprecationWarnings returns a warning for every deprecated package,
+// channel and bundle in the catalog.
+func (c Catalog) DeprecationWarnings() []string {
+	var warnings []string
+	for _, p := range c.Packages {
+		warnings = append(warnings, p.DeprecationWarnings()...)
+	}
+	return warnings
+}
+
+// DeprecationWarnings returns a warning for the package, if it is
+// deprecated, and for each of its deprecated channels and bundles.
+func (p Package) DeprecationWarnings() []string {
+	var warnings []string
+	if d := p.Metadata.Deprecation; d != nil {
+		warnings = append(warnings, fmt.Sprintf("package %q is deprecated: %s", p.Metadata.Name, d.Message))
+	}
+	seen := map[VersionRelease]struct{}{}
+	for _, ch := range p.Channels {
+		if d := ch.Metadata.Deprecation; d != nil {
+			warnings = append(warnings, fmt.Sprintf("channel %q of package %q is deprecated: %s", ch.Metadata.Name, p.Metadata.Name, d.Message))
+		}
+		for _, b := range ch.Bundles {
+			v := b.Metadata.VersionRelease()
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = struct{}{}
+			if msg := b.DeprecationWarning(); msg != "" {
+				warnings = append(warnings, msg)
+			}
+		}
+	}
+	return warnings
+}
+
+// DeprecationWarning returns a warning if the bundle is deprecated, or an
+// empty string if it is not.
+func (b Bundle) DeprecationWarning() string {
+	if b.Metadata.Deprecation == nil {
+		return ""
+	}
+	return fmt.Sprintf("bundle %s %s is deprecated: %s", b.Metadata.Package, b.Metadata.VersionRelease(), b.Metadata.Deprecation.Message)
+}
+
+// deprecationsToFBC returns the olm.deprecations object for p, or nil if
+// nothing in p is deprecated. bundleName returns the file-based catalog name
+// of a bundle.
+func (p Package) deprecationsToFBC(bundleName func(Bundle) string) (*declcfg.Meta, error) {
+	var entries []fbcDeprecationEntry
+	if d := p.Metadata.Deprecation; d != nil {
+		entries = append(entries, fbcDeprecationEntry{
+			Reference: fbcDeprecationReference{Schema: declcfg.SchemaPackage},
+			Message:   d.Message,
+		})
+	}
+	for _, ch := range p.Channels {
+		if d := ch.Metadata.Deprecation; d != nil {
+			entries = append(entries, fbcDeprecationEntry{
+				Reference: fbcDeprecationReference{Schema: declcfg.SchemaChannel, Name: ch.Metadata.Name},
+				Message:   d.Message,
+			})
+		}
+	}
+	bundleEntries := map[string]fbcDeprecationEntry{}
+	for _, ch := range p.Channels {
+		for _, b := range ch.Bundles {
+			if d := b.Metadata.Deprecation; d != nil {
+				name := bundleName(b)
+				bundleEntries[name] = fbcDeprecationEntry{
+					Reference: fbcDeprecationReference{Schema: declcfg.SchemaBundle, Name: name},
+					Message:   d.Message,
+				}
+			}
+		}
+	}
+	for _, name := range sets.List(sets.KeySet(bundleEntries)) {
+		entries = append(entries, bundleEntries[name])
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	blob, err := json.Marshal(fbcDeprecations{
+		Schema:  SchemaDeprecations,
+		Package: p.Metadata.Name,
+		Entries: entries,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &declcfg.Meta{
+		Schema:  SchemaDeprecations,
+		Package: p.Metadata.Name,
+		Blob:    blob,
+	}, nil
+}
+
+// fbcDeprecationsFor returns the deprecations in the olm.deprecations
+// objects for package pkgName, keyed by the deprecated object's reference.
+func fbcDeprecationsFor(others []declcfg.Meta, pkgName string) (map[fbcDeprecationReference]*Deprecation, error) {
+	out := map[fbcDeprecationReference]*Deprecation{}
+	for _, m := range others {
+		if m.Schema != SchemaDeprecations || m.Package != pkgName {
+			continue
+		}
+		var deprecations fbcDeprecations
+		if err := json.Unmarshal(m.Blob, &deprecations); err != nil {
+			return nil, fmt.Errorf("parse %s for package %q: %v", SchemaDeprecations, pkgName, err)
+		}
+		for _, e := range deprecations.Entries {
+			out[e.Reference] = &Deprecation{Message: e.Message}
+		}
+	}
+	return out, nil
+}
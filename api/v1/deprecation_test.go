@@ -0,0 +1,177 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+func deprecatedBundle(pkg, vr, msg string) Bundle {
+	b := testBundle(pkg, vr)
+	b.Metadata.Deprecation = &Deprecation{Message: msg}
+	return b
+}
+
+func TestDeprecationsRoundTripFBC(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		pkg         Package
+		wantEntries []fbcDeprecationEntry
+	}{
+		{
+			name: "nothing deprecated",
+			pkg: Package{
+				Metadata: PackageMetadata{Name: "foo"},
+				Channels: []Channel{{Metadata: ChannelMetadata{Name: "stable"}, Bundles: testBundles("foo", "1.0.0-0")}},
+			},
+		},
+		{
+			name: "package",
+			pkg: Package{
+				Metadata: PackageMetadata{Name: "foo", Deprecation: &Deprecation{Message: "use bar"}},
+				Channels: []Channel{{Metadata: ChannelMetadata{Name: "stable"}, Bundles: testBundles("foo", "1.0.0-0")}},
+			},
+			wantEntries: []fbcDeprecationEntry{
+				{Reference: fbcDeprecationReference{Schema: declcfg.SchemaPackage}, Message: "use bar"},
+			},
+		},
+		{
+			name: "package, channels and bundles",
+			pkg: Package{
+				Metadata: PackageMetadata{Name: "foo", Deprecation: &Deprecation{Message: "use bar"}},
+				Channels: []Channel{
+					{
+						Metadata: ChannelMetadata{Name: "alpha", Deprecation: &Deprecation{Message: "use stable"}},
+						Bundles: []Bundle{
+							deprecatedBundle("foo", "1.0.0-0", "upgrade to 1.1.0"),
+							testBundle("foo", "1.1.0-0"),
+						},
+					},
+					{
+						Metadata: ChannelMetadata{Name: "stable"},
+						Bundles: []Bundle{
+							deprecatedBundle("foo", "1.0.0-0", "upgrade to 1.1.0"),
+							deprecatedBundle("foo", "0.9.0-1", "insecure"),
+							testBundle("foo", "1.1.0-0"),
+						},
+					},
+				},
+			},
+			wantEntries: []fbcDeprecationEntry{
+				{Reference: fbcDeprecationReference{Schema: declcfg.SchemaPackage}, Message: "use bar"},
+				{Reference: fbcDeprecationReference{Schema: declcfg.SchemaChannel, Name: "alpha"}, Message: "use stable"},
+				{Reference: fbcDeprecationReference{Schema: declcfg.SchemaBundle, Name: "foo.v0.9.0-1"}, Message: "insecure"},
+				{Reference: fbcDeprecationReference{Schema: declcfg.SchemaBundle, Name: "foo.v1.0.0-0"}, Message: "upgrade to 1.1.0"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := tc.pkg.ToFBC(context.Background(), "example.com/repo")
+			if err != nil {
+				t.Fatalf("ToFBC: %v", err)
+			}
+
+			var entries []fbcDeprecationEntry
+			for _, m := range cfg.Others {
+				if m.Schema != SchemaDeprecations {
+					continue
+				}
+				if m.Package != tc.pkg.Metadata.Name {
+					t.Errorf("expected %s for package %q, got %q", SchemaDeprecations, tc.pkg.Metadata.Name, m.Package)
+				}
+				var d fbcDeprecations
+				if err := json.Unmarshal(m.Blob, &d); err != nil {
+					t.Fatal(err)
+				}
+				entries = append(entries, d.Entries...)
+			}
+			if !reflect.DeepEqual(entries, tc.wantEntries) {
+				t.Errorf("expected entries %+v, got %+v", tc.wantEntries, entries)
+			}
+
+			// Load bundles without their deprecations, so that they can only
+			// come from the olm.deprecations object.
+			loaded := tc.pkg
+			loaded.Channels = nil
+			for _, ch := range tc.pkg.Channels {
+				ch.Bundles = append([]Bundle(nil), ch.Bundles...)
+				for i := range ch.Bundles {
+					ch.Bundles[i].Metadata.Deprecation = nil
+				}
+				loaded.Channels = append(loaded.Channels, ch)
+			}
+			c := importFBC(t, loaded, cfg)
+			if len(c.Packages) != 1 {
+				t.Fatalf("expected 1 package, got %d", len(c.Packages))
+			}
+			want, got := tc.pkg.DeprecationWarnings(), c.Packages[0].DeprecationWarnings()
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected warnings %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestFBCDeprecationsFor(t *testing.T) {
+	meta := func(pkg, blob string) declcfg.Meta {
+		return declcfg.Meta{Schema: SchemaDeprecations, Package: pkg, Blob: json.RawMessage(blob)}
+	}
+	for _, tc := range []struct {
+		name    string
+		others  []declcfg.Meta
+		want    map[fbcDeprecationReference]*Deprecation
+		wantErr string
+	}{
+		{
+			name: "none",
+			want: map[fbcDeprecationReference]*Deprecation{},
+		},
+		{
+			name: "other packages and schemas ignored",
+			others: []declcfg.Meta{
+				meta("bar", `{"schema":"olm.deprecations","package":"bar","entries":[{"reference":{"schema":"olm.package"},"message":"bar"}]}`),
+				{Schema: "example.other", Package: "foo", Blob: json.RawMessage(`{}`)},
+				meta("foo", `{"schema":"olm.deprecations","package":"foo","entries":[{"reference":{"schema":"olm.channel","name":"alpha"},"message":"alpha"}]}`),
+			},
+			want: map[fbcDeprecationReference]*Deprecation{
+				{Schema: declcfg.SchemaChannel, Name: "alpha"}: {Message: "alpha"},
+			},
+		},
+		{
+			name: "entries of several objects are merged",
+			others: []declcfg.Meta{
+				meta("foo", `{"schema":"olm.deprecations","package":"foo","entries":[{"reference":{"schema":"olm.package"},"message":"foo"}]}`),
+				meta("foo", `{"schema":"olm.deprecations","package":"foo","entries":[{"reference":{"schema":"olm.bundle","name":"foo.v1.0.0"},"message":"old"}]}`),
+			},
+			want: map[fbcDeprecationReference]*Deprecation{
+				{Schema: declcfg.SchemaPackage}:                    {Message: "foo"},
+				{Schema: declcfg.SchemaBundle, Name: "foo.v1.0.0"}: {Message: "old"},
+			},
+		},
+		{
+			name:    "invalid",
+			others:  []declcfg.Meta{meta("foo", `{"entries":{}}`)},
+			wantErr: `parse olm.deprecations for package "foo"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := fbcDeprecationsFor(tc.others, "foo")
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
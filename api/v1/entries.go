@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// selectBundles returns the bundles matched by selectors, in selector order,
+// without those matched by exclude. A selector is one of:
+//
+//   - a bundle version, such as "0.10.0", which matches every release of
+//     that version
+//   - a full version, such as "0.10.0-2", which matches only that release
+//   - a semver range, such as ">=0.9.0 <0.11.0", which matches every release
+//     of every version in the range, in version order
+//
+// A selector that is both a bundle version and a full version matches the
+// bundle version.
+func selectBundles(selectors, exclude []string, bundles []Bundle) ([]Bundle, error) {
+	excluded := sets.New[VersionRelease]()
+	for _, selector := range exclude {
+		matched, err := matchSelector(selector, bundles)
+		if err != nil {
+			return nil, fmt.Errorf("exclude: %v", err)
+		}
+		for _, b := range matched {
+			excluded.Insert(b.Metadata.VersionRelease())
+		}
+	}
+
+	var out []Bundle
+	selected := sets.New[VersionRelease]()
+	for _, selector := range selectors {
+		matched, err := matchSelector(selector, bundles)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range matched {
+			if vr := b.Metadata.VersionRelease(); !excluded.Has(vr) && !selected.Has(vr) {
+				selected.Insert(vr)
+				out = append(out, b)
+			}
+		}
+	}
+	return out, nil
+}
+
+func matchSelector(selector string, bundles []Bundle) ([]Bundle, error) {
+	var out []Bundle
+	for _, b := range bundles {
+		if b.Metadata.Version.String() == selector {
+			out = append(out, b)
+		}
+	}
+	if len(out) > 0 {
+		return out, nil
+	}
+	if vr, err := ParseVersionRelease(selector); err == nil {
+		for _, b := range bundles {
+			if b.Metadata.VersionRelease() == vr {
+				return []Bundle{b}, nil
+			}
+		}
+	}
+
+	// A plain version that matched no bundle is an error rather than an
+	// empty range, so that typos are caught.
+	if _, err := semver.Parse(selector); err == nil {
+		return nil, fmt.Errorf("no bundles found with version %q", selector)
+	}
+	inRange, err := semver.ParseRange(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entry %q: not a bundle version, full version or semver range", selector)
+	}
+	for _, b := range bundles {
+		if inRange(b.Metadata.Version) {
+			out = append(out, b)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return bundleLess(out[i], out[j]) })
+	return out, nil
+}
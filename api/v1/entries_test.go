@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectBundles(t *testing.T) {
+	bundles := testBundles("foo",
+		"0.9.0-0", "0.10.0-1", "0.10.0-0", "0.10.0-2", "0.11.0-0", "1.0.0-rc.1-0", "1.0.0-0", "2.0.0-1-0",
+	)
+	for _, tc := range []struct {
+		name      string
+		selectors []string
+		exclude   []string
+		want      []string
+		wantErr   string
+	}{
+		{
+			name:      "bundle version matches every release in bundle order",
+			selectors: []string{"0.10.0"},
+			want:      []string{"0.10.0-1", "0.10.0-0", "0.10.0-2"},
+		},
+		{
+			name:      "full version",
+			selectors: []string{"0.10.0-2"},
+			want:      []string{"0.10.0-2"},
+		},
+		{
+			name:      "range in version order",
+			selectors: []string{">=0.9.0 <1.0.0"},
+			want:      []string{"0.9.0-0", "0.10.0-0", "0.10.0-1", "0.10.0-2", "0.11.0-0", "1.0.0-rc.1-0"},
+		},
+		{
+			name:      "range includes prereleases",
+			selectors: []string{">=0.11.0"},
+			want:      []string{"0.11.0-0", "1.0.0-rc.1-0", "1.0.0-0", "2.0.0-1-0"},
+		},
+		{
+			name:      "selector order",
+			selectors: []string{"1.0.0", "0.9.0", "1.0.0-rc.1"},
+			want:      []string{"1.0.0-0", "0.9.0-0", "1.0.0-rc.1-0"},
+		},
+		{
+			name:      "overlapping selectors select once",
+			selectors: []string{"0.10.0-1", ">=0.10.0 <0.11.0", "0.10.0"},
+			want:      []string{"0.10.0-1", "0.10.0-0", "0.10.0-2"},
+		},
+		{
+			name:      "bundle version preferred over full version",
+			selectors: []string{"2.0.0-1"},
+			want:      []string{"2.0.0-1-0"},
+		},
+		{
+			name:      "exclusions",
+			selectors: []string{">=0.9.0 <1.0.0-0"},
+			exclude:   []string{"0.10.0-1", "0.11.0"},
+			want:      []string{"0.9.0-0", "0.10.0-0", "0.10.0-2"},
+		},
+		{
+			name:      "excluded range",
+			selectors: []string{"0.9.0", "1.0.0"},
+			exclude:   []string{"<1.0.0"},
+			want:      []string{"1.0.0-0"},
+		},
+		{
+			name:      "range matching nothing",
+			selectors: []string{">=3.0.0"},
+		},
+		{
+			name:      "unknown version",
+			selectors: []string{"0.12.0"},
+			wantErr:   `no bundles found with version "0.12.0"`,
+		},
+		{
+			name:      "unknown release",
+			selectors: []string{"0.10.0-3"},
+			wantErr:   `no bundles found with version "0.10.0-3"`,
+		},
+		{
+			name:      "invalid selector",
+			selectors: []string{"latest"},
+			wantErr:   `invalid entry "latest"`,
+		},
+		{
+			name:      "invalid exclusion",
+			selectors: []string{"0.9.0"},
+			exclude:   []string{"0.12.0"},
+			wantErr:   `exclude: no bundles found with version "0.12.0"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := selectBundles(tc.selectors, tc.exclude, bundles)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, b := range selected {
+				got = append(got, b.Metadata.VersionRelease().String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
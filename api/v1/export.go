@@ -0,0 +1,268 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+
+	"github.com/joelanford/olm-oci/pkg/memfs"
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+const (
+	labelBundleMediaType      = "operators.operatorframework.io.bundle.mediatype.v1"
+	labelBundleManifests      = "operators.operatorframework.io.bundle.manifests.v1"
+	labelBundleMetadata       = "operators.operatorframework.io.bundle.metadata.v1"
+	labelBundlePackage        = "operators.operatorframework.io.bundle.package.v1"
+	labelBundleChannels       = "operators.operatorframework.io.bundle.channels.v1"
+	labelBundleDefaultChannel = "operators.operatorframework.io.bundle.channel.default.v1"
+)
+
+type ExportFBCImageOption func(*exportFBCImageOptions)
+
+type exportFBCImageOptions struct {
+	bundleImageRepo string
+}
+
+// WithBundleImageRepository exports every bundle in the catalog as a bundle
+// image in repo, and points the file-based catalog at those images instead
+// of at the bundle artifacts.
+func WithBundleImageRepository(repo string) ExportFBCImageOption {
+	return func(o *exportFBCImageOptions) {
+		o.bundleImageRepo = repo
+	}
+}
+
+// ExportFBCImage renders c as a file-based catalog and pushes it to imageRef
+// as a catalog image that classic OLM can serve. The catalog's metadata is
+// recorded in the image's title, vendor, description and url labels.
+// artifactRepo is the repository that c was pushed to; unless
+// WithBundleImageRepository is used, bundles are referenced as oci://
+// artifacts in that repository.
+func ExportFBCImage(ctx context.Context, c Catalog, artifactRepo, imageRef string, opts ...ExportFBCImageOption) (ocispec.Descriptor, error) {
+	var o exportFBCImageOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg, err := c.ToFBC(ctx, artifactRepo)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("render file-based catalog: %v", err)
+	}
+	if o.bundleImageRepo != "" {
+		images, err := exportCatalogBundleImages(ctx, c, artifactRepo, o.bundleImageRepo)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		for i := range cfg.Bundles {
+			if image, ok := images[cfg.Bundles[i].Image]; ok {
+				cfg.Bundles[i].Image = image
+			}
+		}
+	}
+
+	fsys, err := fbcFS(*cfg)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	labels := map[string]string{labelIndexConfigs: "/" + defaultIndexConfigs}
+	for k, v := range c.Annotations() {
+		labels[k] = v
+	}
+	return remote.PushImage(ctx, imageRef, labels, fsys)
+}
+
+// exportCatalogBundleImages pushes each bundle in c to bundleImageRepo and
+// returns the pushed images keyed by the bundles' oci:// references. The
+// channels label of each image lists the catalog channels it belongs to.
+func exportCatalogBundleImages(ctx context.Context, c Catalog, artifactRepo, bundleImageRepo string) (map[string]string, error) {
+	images := map[string]string{}
+	for _, p := range c.Packages {
+		bundles := map[string]Bundle{}
+		channels := map[string]sets.Set[string]{}
+		for _, ch := range p.Channels {
+			for _, b := range ch.Bundles {
+				if err := b.ensureDigest(ctx); err != nil {
+					return nil, err
+				}
+				ref := fmt.Sprintf("oci://%s@%s", artifactRepo, b.Digest)
+				if _, ok := bundles[ref]; !ok {
+					bundles[ref] = b
+					channels[ref] = sets.New[string]()
+				}
+				channels[ref].Insert(ch.Metadata.Name)
+			}
+		}
+		for ref, b := range bundles {
+			chs := sets.List(channels[ref])
+			defaultChannel := p.Metadata.DefaultChannel
+			if !channels[ref].Has(defaultChannel) {
+				defaultChannel = chs[0]
+			}
+			tag := strings.ReplaceAll(fmt.Sprintf("%s-v%s", b.Metadata.Package, b.Metadata.VersionRelease()), "+", "_")
+			desc, err := ExportBundleImage(ctx, b, fmt.Sprintf("%s:%s", bundleImageRepo, tag), WithBundleChannels(chs, defaultChannel))
+			if err != nil {
+				return nil, fmt.Errorf("export bundle %s %s: %v", b.Metadata.Package, b.Metadata.Version, err)
+			}
+			images[ref] = fmt.Sprintf("%s@%s", bundleImageRepo, desc.Digest)
+		}
+	}
+	return images, nil
+}
+
+type ExportBundleImageOption func(*exportBundleImageOptions)
+
+type exportBundleImageOptions struct {
+	channels       []string
+	defaultChannel string
+}
+
+// WithBundleChannels sets the channels and default channel recorded in the
+// bundle image, overriding those in the bundle's migration hints.
+func WithBundleChannels(channels []string, defaultChannel string) ExportBundleImageOption {
+	return func(o *exportBundleImageOptions) {
+		o.channels = channels
+		o.defaultChannel = defaultChannel
+	}
+}
+
+// ExportBundleImage pushes b to imageRef as a conventional bundle image with
+// a single layer holding the bundle's content. The image's bundle labels and
+// its metadata/annotations.yaml are reconstructed from the bundle's
+// metadata, content media type and channels.
+func ExportBundleImage(ctx context.Context, b Bundle, imageRef string, opts ...ExportBundleImageOption) (ocispec.Descriptor, error) {
+	labels, err := bundleImageLabels(b, opts...)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	fsys, err := bundleImageFS(b, labels)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return remote.PushImage(ctx, imageRef, labels, fsys)
+}
+
+// bundleImageLabels returns the config labels of the bundle image that b is
+// exported as.
+func bundleImageLabels(b Bundle, opts ...ExportBundleImageOption) (map[string]string, error) {
+	o := exportBundleImageOptions{
+		defaultChannel: b.MigrationHints[AnnotationKeyBundleLegacyDefaultChannel],
+	}
+	if channels := b.MigrationHints[AnnotationKeyBundleLegacyChannels]; channels != "" {
+		o.channels = strings.Split(channels, ",")
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if b.ContentMediaType != MediaTypeBundleFormatRegistryV1 && b.ContentMediaType != MediaTypeBundleFormatPlainV0 {
+		return nil, fmt.Errorf("bundles with content media type %q cannot be exported as bundle images", b.ContentMediaType)
+	}
+	if b.Content.FS == nil {
+		return nil, fmt.Errorf("bundle has no content")
+	}
+	if b.ContentMediaType == MediaTypeBundleFormatRegistryV1 && len(o.channels) == 0 {
+		return nil, fmt.Errorf("registry+v1 bundle images require at least one channel")
+	}
+	if o.defaultChannel == "" && len(o.channels) > 0 {
+		o.defaultChannel = o.channels[0]
+	}
+
+	labels := map[string]string{
+		labelBundleMediaType: b.ContentMediaType,
+		labelBundleManifests: "manifests/",
+		labelBundleMetadata:  "metadata/",
+		labelBundlePackage:   b.Metadata.Package,
+	}
+	if len(o.channels) > 0 {
+		labels[labelBundleChannels] = strings.Join(o.channels, ",")
+		labels[labelBundleDefaultChannel] = o.defaultChannel
+	}
+	return labels, nil
+}
+
+// bundleImageFS copies the bundle's content and merges labels and the
+// bundle's version and release into its metadata/annotations.yaml, so that
+// tools that read the annotations file instead of the image labels agree
+// with them.
+func bundleImageFS(b Bundle, labels map[string]string) (fs.FS, error) {
+	fsys := memfs.New()
+	if err := fs.WalkDir(b.Content.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := fs.ReadFile(b.Content.FS, path)
+		if err != nil {
+			return err
+		}
+		fsys.WriteFile(path, data, 0644, time.Time{})
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("read bundle content: %v", err)
+	}
+
+	var annotations annotationsFile
+	if data, err := fs.ReadFile(fsys, bundleImageAnnotationsPath); err == nil {
+		if err := yaml.Unmarshal(data, &annotations); err != nil {
+			return nil, fmt.Errorf("unmarshal %s: %v", bundleImageAnnotationsPath, err)
+		}
+	}
+	if annotations.Annotations == nil {
+		annotations.Annotations = map[string]string{}
+	}
+	for k, v := range labels {
+		annotations.Annotations[k] = v
+	}
+	annotations.Annotations[AnnotationKeyBundleVersion] = b.Metadata.Version.String()
+	annotations.Annotations[AnnotationKeyBundleRelease] = fmt.Sprintf("%d", b.Metadata.Release)
+	data, err := yaml.Marshal(annotations)
+	if err != nil {
+		return nil, err
+	}
+	fsys.WriteFile(bundleImageAnnotationsPath, data, 0644, time.Time{})
+	return fsys, nil
+}
+
+// fbcFS lays out cfg the way "opm" does, with one catalog.json file per
+// package under the configs directory. Objects that belong to no package
+// are written to the configs directory itself.
+func fbcFS(cfg declcfg.DeclarativeConfig) (fs.FS, error) {
+	byPackage := map[string]*declcfg.DeclarativeConfig{}
+	get := func(name string) *declcfg.DeclarativeConfig {
+		if byPackage[name] == nil {
+			byPackage[name] = &declcfg.DeclarativeConfig{}
+		}
+		return byPackage[name]
+	}
+	for _, p := range cfg.Packages {
+		get(p.Name).Packages = append(get(p.Name).Packages, p)
+	}
+	for _, ch := range cfg.Channels {
+		get(ch.Package).Channels = append(get(ch.Package).Channels, ch)
+	}
+	for _, b := range cfg.Bundles {
+		get(b.Package).Bundles = append(get(b.Package).Bundles, b)
+	}
+	for _, m := range cfg.Others {
+		get(m.Package).Others = append(get(m.Package).Others, m)
+	}
+
+	fsys := memfs.New()
+	for name, pkgCfg := range byPackage {
+		var buf bytes.Buffer
+		if err := declcfg.WriteJSON(*pkgCfg, &buf); err != nil {
+			return nil, fmt.Errorf("write file-based catalog for package %q: %v", name, err)
+		}
+		fsys.WriteFile(path.Join(defaultIndexConfigs, name, "catalog.json"), buf.Bytes(), 0644, time.Time{})
+	}
+	return fsys, nil
+}
@@ -0,0 +1,286 @@
+package v1
+
+import (
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"sigs.k8s.io/yaml"
+)
+
+func exportTestBundle(t *testing.T, fsys fstest.MapFS) Bundle {
+	t.Helper()
+	b, err := LoadBundleFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *b
+}
+
+func registryV1ExportTestBundle(t *testing.T, annotations string) Bundle {
+	t.Helper()
+	return exportTestBundle(t, fstest.MapFS{
+		"manifests/foo.clusterserviceversion.yaml": {Data: []byte(testCSV)},
+		"metadata/annotations.yaml":                {Data: []byte("annotations:\n  operators.operatorframework.io.bundle.package.v1: foo\n" + annotations)},
+	})
+}
+
+func plainV0ExportTestBundle(t *testing.T) Bundle {
+	t.Helper()
+	return exportTestBundle(t, fstest.MapFS{
+		"manifests/deployment.yaml": {Data: []byte(testDeployment)},
+		"metadata/annotations.yaml": {Data: []byte("annotations:\n  io.operatorframework.bundle.package: foo\n  io.operatorframework.bundle.version: 1.0.0\n  io.operatorframework.bundle.release: \"2\"\n")},
+	})
+}
+
+func TestBundleImageLabels(t *testing.T) {
+	withHints := func(b Bundle, channels, defaultChannel string) Bundle {
+		b.MigrationHints = map[string]string{AnnotationKeyBundleLegacyChannels: channels}
+		if defaultChannel != "" {
+			b.MigrationHints[AnnotationKeyBundleLegacyDefaultChannel] = defaultChannel
+		}
+		return b
+	}
+	registryV1 := registryV1ExportTestBundle(t, "")
+	plainV0 := plainV0ExportTestBundle(t)
+	helm := Bundle{ContentMediaType: MediaTypeBundleFormatHelmV3, Content: BundleContent{FS: fstest.MapFS{}}}
+	sparse := registryV1
+	sparse.Content = BundleContent{}
+
+	for _, tc := range []struct {
+		name    string
+		bundle  Bundle
+		opts    []ExportBundleImageOption
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:   "registry+v1 channels from migration hints",
+			bundle: withHints(registryV1, "fast,stable", "stable"),
+			want: map[string]string{
+				labelBundleMediaType:      MediaTypeBundleFormatRegistryV1,
+				labelBundleManifests:      "manifests/",
+				labelBundleMetadata:       "metadata/",
+				labelBundlePackage:        "foo",
+				labelBundleChannels:       "fast,stable",
+				labelBundleDefaultChannel: "stable",
+			},
+		},
+		{
+			name:   "default channel defaults to the first channel",
+			bundle: withHints(registryV1, "fast,stable", ""),
+			want: map[string]string{
+				labelBundleMediaType:      MediaTypeBundleFormatRegistryV1,
+				labelBundleManifests:      "manifests/",
+				labelBundleMetadata:       "metadata/",
+				labelBundlePackage:        "foo",
+				labelBundleChannels:       "fast,stable",
+				labelBundleDefaultChannel: "fast",
+			},
+		},
+		{
+			name:   "channels option overrides migration hints",
+			bundle: withHints(registryV1, "fast,stable", "stable"),
+			opts:   []ExportBundleImageOption{WithBundleChannels([]string{"candidate"}, "candidate")},
+			want: map[string]string{
+				labelBundleMediaType:      MediaTypeBundleFormatRegistryV1,
+				labelBundleManifests:      "manifests/",
+				labelBundleMetadata:       "metadata/",
+				labelBundlePackage:        "foo",
+				labelBundleChannels:       "candidate",
+				labelBundleDefaultChannel: "candidate",
+			},
+		},
+		{
+			name:    "registry+v1 without channels",
+			bundle:  registryV1,
+			wantErr: "registry+v1 bundle images require at least one channel",
+		},
+		{
+			name:   "plain+v0 without channels",
+			bundle: plainV0,
+			want: map[string]string{
+				labelBundleMediaType: MediaTypeBundleFormatPlainV0,
+				labelBundleManifests: "manifests/",
+				labelBundleMetadata:  "metadata/",
+				labelBundlePackage:   "foo",
+			},
+		},
+		{
+			name:    "helm+v3",
+			bundle:  helm,
+			wantErr: `content media type "helm+v3" cannot be exported`,
+		},
+		{
+			name:    "no content",
+			bundle:  withHints(sparse, "stable", ""),
+			wantErr: "bundle has no content",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := bundleImageLabels(tc.bundle, tc.opts...)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected labels %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBundleImageFS(t *testing.T) {
+	b := registryV1ExportTestBundle(t, "  com.example.team: foo-team\n")
+	labels := map[string]string{
+		labelBundleMediaType: MediaTypeBundleFormatRegistryV1,
+		labelBundlePackage:   "foo",
+		labelBundleChannels:  "stable",
+	}
+	fsys, err := bundleImageFS(b, labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(fsys, "manifests/foo.clusterserviceversion.yaml"); err != nil {
+		t.Errorf("expected bundle manifests to be copied: %v", err)
+	}
+	data, err := fs.ReadFile(fsys, bundleImageAnnotationsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got annotationsFile
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"com.example.team":         "foo-team",
+		labelBundleMediaType:       MediaTypeBundleFormatRegistryV1,
+		labelBundlePackage:         "foo",
+		labelBundleChannels:        "stable",
+		AnnotationKeyBundleVersion: "1.0.0",
+		AnnotationKeyBundleRelease: "0",
+	}
+	if !reflect.DeepEqual(got.Annotations, want) {
+		t.Errorf("expected annotations %v, got %v", want, got.Annotations)
+	}
+}
+
+func TestExportBundleImageRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		bundle    Bundle
+		opts      []ExportBundleImageOption
+		wantHints map[string]string
+	}{
+		{
+			name:   "registry+v1",
+			bundle: registryV1ExportTestBundle(t, ""),
+			opts:   []ExportBundleImageOption{WithBundleChannels([]string{"fast", "stable"}, "stable")},
+			wantHints: map[string]string{
+				AnnotationKeyBundleLegacyChannels:       "fast,stable",
+				AnnotationKeyBundleLegacyDefaultChannel: "stable",
+			},
+		},
+		{
+			name:   "plain+v0",
+			bundle: plainV0ExportTestBundle(t),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			labels, err := bundleImageLabels(tc.bundle, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fsys, err := bundleImageFS(tc.bundle, labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := loadBundleImageFS(fsys, labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Metadata, tc.bundle.Metadata) {
+				t.Errorf("expected metadata %+v, got %+v", tc.bundle.Metadata, got.Metadata)
+			}
+			if got.ContentMediaType != tc.bundle.ContentMediaType {
+				t.Errorf("expected content media type %q, got %q", tc.bundle.ContentMediaType, got.ContentMediaType)
+			}
+			if !reflect.DeepEqual(got.RelatedImages, tc.bundle.RelatedImages) {
+				t.Errorf("expected related images %v, got %v", tc.bundle.RelatedImages, got.RelatedImages)
+			}
+			if !reflect.DeepEqual(got.MigrationHints, tc.wantHints) {
+				t.Errorf("expected migration hints %v, got %v", tc.wantHints, got.MigrationHints)
+			}
+		})
+	}
+}
+
+func TestFBCFS(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  declcfg.DeclarativeConfig
+		want map[string][]string
+	}{
+		{
+			name: "one file per package",
+			cfg: declcfg.DeclarativeConfig{
+				Packages: []declcfg.Package{
+					{Schema: declcfg.SchemaPackage, Name: "foo", DefaultChannel: "stable"},
+					{Schema: declcfg.SchemaPackage, Name: "bar"},
+				},
+				Channels: []declcfg.Channel{
+					{Schema: declcfg.SchemaChannel, Package: "foo", Name: "stable", Entries: []declcfg.ChannelEntry{{Name: "foo.v1.0.0-0"}}},
+				},
+				Bundles: []declcfg.Bundle{
+					{Schema: declcfg.SchemaBundle, Package: "foo", Name: "foo.v1.0.0-0", Image: "example.com/foo:v1.0.0-0"},
+				},
+			},
+			want: map[string][]string{
+				"configs/bar/catalog.json": {"olm.package bar"},
+				"configs/foo/catalog.json": {"olm.package foo", "olm.channel stable", "olm.bundle foo.v1.0.0-0"},
+			},
+		},
+		{
+			name: "objects without a package",
+			cfg: declcfg.DeclarativeConfig{
+				Packages: []declcfg.Package{{Schema: declcfg.SchemaPackage, Name: "foo"}},
+				Others: []declcfg.Meta{
+					{Schema: "olm.catalog.metadata", Blob: []byte(`{"schema":"olm.catalog.metadata","name":"example"}`)},
+					{Schema: "olm.deprecations", Package: "foo", Blob: []byte(`{"schema":"olm.deprecations","package":"foo","entries":[]}`)},
+				},
+			},
+			want: map[string][]string{
+				"configs/catalog.json":     {"olm.catalog.metadata example"},
+				"configs/foo/catalog.json": {"olm.package foo", "olm.deprecations "},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys, err := fbcFS(tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string][]string{}
+			if err := declcfg.WalkMetasFS(fsys, func(path string, meta *declcfg.Meta, err error) error {
+				if err != nil {
+					return err
+				}
+				got[path] = append(got[path], meta.Schema+" "+meta.Name)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected objects %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,431 @@
+package v1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/blang/semver/v4"
+	operatorsv1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/pkg/registry"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+const MediaTypeBundleFormatPlainV0 = "plain+v0"
+
+// BundleFormat loads bundles whose content is in a particular format. Formats
+// are registered by content media type with RegisterBundleFormat.
+type BundleFormat interface {
+	// Detect reports whether fsys contains a bundle in this format. It is
+	// only called for bundles that do not declare their content media type.
+	Detect(fsys fs.FS) (bool, error)
+
+	// Parse checks that the bundle content in fsys is well-formed and
+	// parses it. Each bundle is parsed once per load.
+	Parse(fsys fs.FS) (ParsedBundle, error)
+}
+
+// ParsedBundle is bundle content parsed by its BundleFormat.
+type ParsedBundle interface {
+	LoadMetadata() (BundleMetadata, error)
+	LoadRelatedImages() (RelatedImages, error)
+	LoadProperties() (Properties, error)
+	LoadConstraints() (Constraints, error)
+}
+
+var bundleFormats = struct {
+	sync.RWMutex
+	mediaTypes []string
+	formats    map[string]BundleFormat
+}{formats: map[string]BundleFormat{}}
+
+func init() {
+	RegisterBundleFormat(MediaTypeBundleFormatPlainV0, plainV0Format{})
+	RegisterBundleFormat(MediaTypeBundleFormatRegistryV1, registryV1Format{})
+	RegisterBundleFormat(MediaTypeBundleFormatHelmV3, helmV3Format{})
+}
+
+// RegisterBundleFormat makes format available for bundle content with the
+// given media type. It panics if a format is already registered for
+// mediaType.
+func RegisterBundleFormat(mediaType string, format BundleFormat) {
+	bundleFormats.Lock()
+	defer bundleFormats.Unlock()
+	if _, ok := bundleFormats.formats[mediaType]; ok {
+		panic(fmt.Sprintf("bundle format %q is already registered", mediaType))
+	}
+	bundleFormats.mediaTypes = append(bundleFormats.mediaTypes, mediaType)
+	bundleFormats.formats[mediaType] = format
+}
+
+// BundleFormatFor returns the format registered for mediaType.
+func BundleFormatFor(mediaType string) (BundleFormat, error) {
+	bundleFormats.RLock()
+	defer bundleFormats.RUnlock()
+	format, ok := bundleFormats.formats[mediaType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported bundle content media type %q", mediaType)
+	}
+	return format, nil
+}
+
+// DetectBundleFormat returns the content media type and format of the bundle
+// in fsys. The media type declared in the bundle's metadata annotations is
+// used if present. Otherwise, registered formats are asked to detect the
+// bundle in the order they were registered.
+func DetectBundleFormat(fsys fs.FS) (string, BundleFormat, error) {
+	if mt, ok := declaredContentMediaType(fsys); ok {
+		format, err := BundleFormatFor(mt)
+		if err != nil {
+			return "", nil, err
+		}
+		return mt, format, nil
+	}
+
+	bundleFormats.RLock()
+	defer bundleFormats.RUnlock()
+	for _, mt := range bundleFormats.mediaTypes {
+		format := bundleFormats.formats[mt]
+		ok, err := format.Detect(fsys)
+		if err != nil {
+			return "", nil, fmt.Errorf("detect %s bundle: %v", mt, err)
+		}
+		if ok {
+			return mt, format, nil
+		}
+	}
+	return "", nil, fmt.Errorf("could not detect bundle content media type")
+}
+
+func declaredContentMediaType(fsys fs.FS) (string, bool) {
+	annotations, err := loadBundleMetadataAnnotations(fsys)
+	if err != nil {
+		return "", false
+	}
+	mt, ok := annotations[AnnotationKeyBundleContentMediaType]
+	return mt, ok
+}
+
+// Warner is implemented by parsed bundles whose format can find problems
+// with bundle content that do not prevent the bundle from being loaded.
+type Warner interface {
+	Warnings() ([]string, error)
+}
+
+// metadataDir loads the properties and constraints that are shared by the
+// built-in formats from the bundle's metadata directory.
+type metadataDir struct {
+	fsys fs.FS
+}
+
+func (m metadataDir) LoadProperties() (Properties, error) {
+	return loadProperties(m.fsys, "metadata/properties.yaml")
+}
+
+func (m metadataDir) LoadConstraints() (Constraints, error) {
+	return loadConstraints(m.fsys, "metadata/constraints.yaml")
+}
+
+// plainV0Format is rukpak's plain+v0 format: a directory of manifests whose
+// package, version and release are set in metadata/annotations.yaml and
+// whose related images are listed in metadata/relatedImages.yaml.
+type plainV0Format struct{}
+
+type plainV0Bundle struct {
+	metadataDir
+	annotations map[string]string
+	declared    RelatedImages
+	objs        []unstructured.Unstructured
+}
+
+func (plainV0Format) Detect(fsys fs.FS) (bool, error) {
+	annotations, err := loadBundleMetadataAnnotations(fsys)
+	if err != nil {
+		return false, nil
+	}
+	_, hasVersion := annotations[AnnotationKeyBundleVersion]
+	return hasVersion, nil
+}
+
+func (plainV0Format) Parse(fsys fs.FS) (ParsedBundle, error) {
+	entries, err := fs.ReadDir(fsys, "manifests")
+	if err != nil {
+		return nil, fmt.Errorf("read manifests directory: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			return nil, fmt.Errorf("manifests directory must not contain subdirectories, found %q", entry.Name())
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("manifests directory is empty")
+	}
+
+	b := plainV0Bundle{metadataDir: metadataDir{fsys: fsys}}
+	if b.annotations, err = loadBundleMetadataAnnotations(fsys); err != nil {
+		return nil, fmt.Errorf("error loading metadata annotations: %w", err)
+	}
+	if b.declared, err = declaredRelatedImages(fsys); err != nil {
+		return nil, err
+	}
+	if b.objs, err = readManifests(fsys, "manifests"); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b plainV0Bundle) LoadMetadata() (BundleMetadata, error) {
+	pkgName, ok := b.annotations[AnnotationKeyBundlePackage]
+	if !ok {
+		return BundleMetadata{}, fmt.Errorf("missing bundle package annotation %q", AnnotationKeyBundlePackage)
+	}
+	v, ok := b.annotations[AnnotationKeyBundleVersion]
+	if !ok {
+		return BundleMetadata{}, fmt.Errorf("missing bundle version annotation %q", AnnotationKeyBundleVersion)
+	}
+	bundleVersion, err := semver.Parse(v)
+	if err != nil {
+		return BundleMetadata{}, fmt.Errorf("invalid bundle version %q: %v", v, err)
+	}
+
+	bundleRelease, err := releaseFromAnnotations(b.annotations)
+	if err != nil {
+		return BundleMetadata{}, err
+	}
+	return BundleMetadata{
+		Package: pkgName,
+		Version: bundleVersion,
+		Release: bundleRelease,
+	}, nil
+}
+
+// releaseFromAnnotations returns the release set by the
+// AnnotationKeyBundleRelease annotation, or 0 if it is not set.
+func releaseFromAnnotations(annotations map[string]string) (uint, error) {
+	r, ok := annotations[AnnotationKeyBundleRelease]
+	if !ok {
+		return 0, nil
+	}
+	release, err := strconv.ParseUint(r, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bundle release %q: %v", r, err)
+	}
+	return uint(release), nil
+}
+
+// LoadRelatedImages returns the images declared in
+// metadata/relatedImages.yaml, followed by any other container images used
+// by the workloads in the bundle's manifests.
+func (b plainV0Bundle) LoadRelatedImages() (RelatedImages, error) {
+	relatedImages := append(RelatedImages(nil), b.declared...)
+	seen := sets.New[string]()
+	for _, ri := range b.declared {
+		seen.Insert(ri.Image)
+	}
+	for _, img := range manifestImages(b.objs) {
+		if !seen.Has(img) {
+			relatedImages = append(relatedImages, RelatedImage{Image: img})
+		}
+	}
+	return relatedImages, nil
+}
+
+// Warnings reports images that are declared in metadata/relatedImages.yaml
+// but not used by any workload in the bundle's manifests, and images that
+// are used but not declared. No warnings are reported for bundles that do
+// not declare related images.
+func (b plainV0Bundle) Warnings() ([]string, error) {
+	if len(b.declared) == 0 {
+		return nil, nil
+	}
+	used := sets.New[string](manifestImages(b.objs)...)
+	declaredSet := sets.New[string]()
+	for _, ri := range b.declared {
+		declaredSet.Insert(ri.Image)
+	}
+
+	var warnings []string
+	for _, img := range sets.List(declaredSet.Difference(used)) {
+		warnings = append(warnings, fmt.Sprintf("related image %q is declared but not used by any workload", img))
+	}
+	for _, img := range sets.List(used.Difference(declaredSet)) {
+		warnings = append(warnings, fmt.Sprintf("image %q is used by a workload but not declared as a related image", img))
+	}
+	return warnings, nil
+}
+
+func declaredRelatedImages(fsys fs.FS) (RelatedImages, error) {
+	riData, err := fs.ReadFile(fsys, "metadata/relatedImages.yaml")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("load related images: %v", err)
+	}
+	var ri struct {
+		RelatedImages RelatedImages `json:"relatedImages"`
+	}
+	if err := yaml.Unmarshal(riData, &ri); err != nil {
+		return nil, fmt.Errorf("unmarshal related images: %v", err)
+	}
+	return ri.RelatedImages, nil
+}
+
+// registryV1Format is OLM's registry+v1 format, whose version and related
+// images are read from the bundle's ClusterServiceVersion.
+type registryV1Format struct{}
+
+type registryV1Bundle struct {
+	metadataDir
+	annotations map[string]string
+	bundle      *registry.Bundle
+}
+
+func (registryV1Format) Detect(fsys fs.FS) (bool, error) {
+	entries, err := fs.ReadDir(fsys, "manifests")
+	if err != nil {
+		return false, nil
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".clusterserviceversion.yaml") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Parse parses the bundle the way operator-registry does: each file in the
+// manifests directory holds one object, only the first CSV is kept, and
+// every API that the CSV provides must be in the bundle.
+func (registryV1Format) Parse(fsys fs.FS) (ParsedBundle, error) {
+	annotations, err := loadBundleMetadataAnnotations(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("error loading metadata annotations: %w", err)
+	}
+	entries, err := fs.ReadDir(fsys, "manifests")
+	if err != nil {
+		return nil, fmt.Errorf("read manifests directory: %v", err)
+	}
+	var (
+		objs     []*unstructured.Unstructured
+		csvFound bool
+	)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join("manifests", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		obj := &unstructured.Unstructured{}
+		if err := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 30).Decode(obj); err != nil || obj.Object == nil {
+			// Like operator-registry, skip files that are not objects.
+			continue
+		}
+		if obj.GetKind() == operatorsv1alpha1.ClusterServiceVersionKind {
+			if csvFound {
+				continue
+			}
+			csvFound = true
+		}
+		objs = append(objs, obj)
+	}
+	if len(objs) == 0 {
+		return nil, fmt.Errorf("no bundle objects found")
+	}
+	if !csvFound {
+		return nil, fmt.Errorf("no csv in bundle")
+	}
+
+	b := registryV1Bundle{metadataDir: metadataDir{fsys: fsys}, annotations: annotations}
+	b.bundle = registry.NewBundle("", &registry.Annotations{
+		PackageName:        annotations[AnnotationKeyBundlePackage],
+		Channels:           annotations[AnnotationKeyBundleLegacyChannels],
+		DefaultChannelName: annotations[AnnotationKeyBundleLegacyDefaultChannel],
+	}, objs...)
+	csv, err := b.bundle.ClusterServiceVersion()
+	if err != nil {
+		return nil, err
+	}
+	b.bundle.Name = csv.GetName()
+	if err := b.bundle.AllProvidedAPIsInBundle(); err != nil {
+		return nil, fmt.Errorf("error checking provided apis in bundle %s: %v", b.bundle.Name, err)
+	}
+	return b, nil
+}
+
+func (b registryV1Bundle) LoadMetadata() (BundleMetadata, error) {
+	verStr, err := b.bundle.Version()
+	if err != nil {
+		return BundleMetadata{}, fmt.Errorf("error getting bundle version: %v", err)
+	}
+	version, err := semver.Parse(verStr)
+	if err != nil {
+		return BundleMetadata{}, fmt.Errorf("invalid bundle version %q: %v", verStr, err)
+	}
+	// The release is not part of the registry+v1 format, so it can only be
+	// set by an annotation alongside the standard bundle annotations.
+	release, err := releaseFromAnnotations(b.annotations)
+	if err != nil {
+		return BundleMetadata{}, err
+	}
+	return BundleMetadata{
+		Package: b.bundle.Package,
+		Version: version,
+		Release: release,
+	}, nil
+}
+
+func (b registryV1Bundle) LoadRelatedImages() (RelatedImages, error) {
+	relatedImages, err := getRegistryBundleRelatedImages(*b.bundle)
+	if err != nil {
+		return nil, fmt.Errorf("error getting related images: %v", err)
+	}
+	return relatedImages, nil
+}
+
+// readManifests decodes every object in the YAML and JSON files in the
+// directory dir of fsys.
+func readManifests(fsys fs.FS, dir string) ([]unstructured.Unstructured, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read manifests directory: %v", err)
+	}
+	var objs []unstructured.Unstructured
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch path.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		dec := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			var obj unstructured.Unstructured
+			if err := dec.Decode(&obj.Object); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf("decode %s: %v", entry.Name(), err)
+			}
+			if len(obj.Object) == 0 {
+				continue
+			}
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
@@ -0,0 +1,165 @@
+package v1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testCSV = `apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: foo.v1.0.0
+spec:
+  version: 1.0.0
+  relatedImages:
+  - name: operand
+    image: example.com/operand:v1
+  install:
+    strategy: deployment
+    spec:
+      deployments:
+      - name: foo
+        spec:
+          template:
+            spec:
+              containers:
+              - name: manager
+                image: example.com/foo:v1
+`
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+spec:
+  template:
+    spec:
+      containers:
+      - name: manager
+        image: example.com/foo:v1
+`
+
+func TestLoadBundleFS(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		fsys              fstest.MapFS
+		wantMediaType     string
+		wantVersion       string
+		wantRelatedImages []string
+		wantWarnings      []string
+		wantErr           string
+	}{
+		{
+			name: "plain+v0",
+			fsys: fstest.MapFS{
+				"manifests/deployment.yaml":   {Data: []byte(testDeployment)},
+				"metadata/annotations.yaml":   {Data: []byte("annotations:\n  io.operatorframework.bundle.package: foo\n  io.operatorframework.bundle.version: 1.0.0\n  io.operatorframework.bundle.release: \"2\"\n")},
+				"metadata/relatedImages.yaml": {Data: []byte("relatedImages:\n- image: example.com/operand:v1\n")},
+			},
+			wantMediaType:     MediaTypeBundleFormatPlainV0,
+			wantVersion:       "1.0.0-2",
+			wantRelatedImages: []string{"example.com/operand:v1", "example.com/foo:v1"},
+			wantWarnings: []string{
+				`related image "example.com/operand:v1" is declared but not used by any workload`,
+				`image "example.com/foo:v1" is used by a workload but not declared as a related image`,
+			},
+		},
+		{
+			name: "plain+v0 with subdirectory",
+			fsys: fstest.MapFS{
+				"manifests/sub/deployment.yaml": {Data: []byte(testDeployment)},
+				"metadata/annotations.yaml":     {Data: []byte("annotations:\n  io.operatorframework.bundle.package: foo\n  io.operatorframework.bundle.version: 1.0.0\n")},
+			},
+			wantErr: `invalid plain+v0 bundle: manifests directory must not contain subdirectories, found "sub"`,
+		},
+		{
+			name: "registry+v1",
+			fsys: fstest.MapFS{
+				"manifests/foo.clusterserviceversion.yaml": {Data: []byte(testCSV)},
+				"manifests/.hidden.yaml":                   {Data: []byte("not: parsed")},
+				"metadata/annotations.yaml":                {Data: []byte("annotations:\n  operators.operatorframework.io.bundle.package.v1: foo\n  operators.operatorframework.io.bundle.channels.v1: stable\n")},
+			},
+			wantMediaType:     MediaTypeBundleFormatRegistryV1,
+			wantVersion:       "1.0.0-0",
+			wantRelatedImages: []string{"example.com/foo:v1", "example.com/operand:v1"},
+		},
+		{
+			name: "registry+v1 without a CSV",
+			fsys: fstest.MapFS{
+				"manifests/foo.clusterserviceversion.yaml": {Data: []byte(testDeployment)},
+				"metadata/annotations.yaml":                {Data: []byte("annotations:\n  operators.operatorframework.io.bundle.package.v1: foo\n")},
+			},
+			wantErr: "invalid registry+v1 bundle: no csv in bundle",
+		},
+		{
+			name: "helm+v3",
+			fsys: fstest.MapFS{
+				"Chart.yaml":                {Data: []byte("apiVersion: v2\nname: foo\nversion: 1.0.0\n")},
+				"values.yaml":               {Data: []byte("image: example.com/foo:v1\n")},
+				"templates/deployment.yaml": {Data: []byte(strings.ReplaceAll(testDeployment, "example.com/foo:v1", "{{ .Values.image }}"))},
+				"templates/.backup.yaml":    {Data: []byte("{{ broken")},
+			},
+			wantMediaType:     MediaTypeBundleFormatHelmV3,
+			wantVersion:       "1.0.0-0",
+			wantRelatedImages: []string{"example.com/foo:v1"},
+		},
+		{
+			name: "helm+v3 with required values",
+			fsys: fstest.MapFS{
+				"Chart.yaml":                {Data: []byte("apiVersion: v2\nname: foo\nversion: 1.0.0\n")},
+				"templates/deployment.yaml": {Data: []byte(strings.ReplaceAll(testDeployment, "example.com/foo:v1", `{{ required "image is required" .Values.image }}`))},
+			},
+			wantMediaType: MediaTypeBundleFormatHelmV3,
+			wantVersion:   "1.0.0-0",
+			wantWarnings: []string{
+				"related images are unknown because the chart cannot be rendered with its default values: render chart: execution error at (foo/templates/deployment.yaml:10:18): image is required",
+			},
+		},
+		{
+			name:    "unknown",
+			fsys:    fstest.MapFS{"README.md": {Data: []byte("hello")}},
+			wantErr: "could not detect bundle content media type",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var warnings []string
+			b, err := LoadBundleFS(tc.fsys, WithWarningHandler(func(msg string) { warnings = append(warnings, msg) }))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.ContentMediaType != tc.wantMediaType {
+				t.Errorf("expected media type %q, got %q", tc.wantMediaType, b.ContentMediaType)
+			}
+			if b.Metadata.Package != "foo" {
+				t.Errorf("expected package %q, got %q", "foo", b.Metadata.Package)
+			}
+			if got := b.Metadata.VersionRelease().String(); got != tc.wantVersion {
+				t.Errorf("expected version %q, got %q", tc.wantVersion, got)
+			}
+			var images []string
+			for _, ri := range b.RelatedImages {
+				images = append(images, ri.Image)
+			}
+			if !reflect.DeepEqual(images, tc.wantRelatedImages) {
+				t.Errorf("expected related images %q, got %q", tc.wantRelatedImages, images)
+			}
+			if !reflect.DeepEqual(warnings, tc.wantWarnings) {
+				t.Errorf("expected warnings %q, got %q", tc.wantWarnings, warnings)
+			}
+			if b.MigrationHints != nil {
+				t.Errorf("expected no migration hints, got %v", b.MigrationHints)
+			}
+			if b.Content.FS == nil {
+				t.Errorf("expected bundle content")
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// PropertyGenerator is implemented by parsed bundles whose format can
+// derive properties from bundle content.
+type PropertyGenerator interface {
+	GenerateProperties() (Properties, error)
+}
+
+// GenerateProperties derives olm.gvk and olm.gvk.required properties from
+// the CSV's owned and required CRDs, and an olm.csv.metadata property, as
+// "opm render" does, which carries the CSV's install modes, minimum
+// Kubernetes version, labels and other descriptive metadata.
+func (b registryV1Bundle) GenerateProperties() (Properties, error) {
+	csv, err := b.bundle.ClusterServiceVersion()
+	if err != nil {
+		return nil, fmt.Errorf("get cluster service version: %v", err)
+	}
+	owned, required, err := csv.GetCustomResourceDefintions()
+	if err != nil {
+		return nil, fmt.Errorf("get custom resource definitions: %v", err)
+	}
+	var spec v1alpha1.ClusterServiceVersionSpec
+	if err := json.Unmarshal(csv.Spec, &spec); err != nil {
+		return nil, fmt.Errorf("decode cluster service version spec: %v", err)
+	}
+
+	var values []interface{}
+	for _, crd := range owned {
+		values = append(values, &property.GVK{Group: crdGroup(crd.Group, crd.Name), Version: crd.Version, Kind: crd.Kind})
+	}
+	for _, crd := range required {
+		values = append(values, &property.GVKRequired{Group: crdGroup(crd.Group, crd.Name), Version: crd.Version, Kind: crd.Kind})
+	}
+	values = append(values, &property.CSVMetadata{
+		Annotations:               csv.GetAnnotations(),
+		APIServiceDefinitions:     spec.APIServiceDefinitions,
+		CustomResourceDefinitions: spec.CustomResourceDefinitions,
+		Description:               spec.Description,
+		DisplayName:               spec.DisplayName,
+		InstallModes:              spec.InstallModes,
+		Keywords:                  spec.Keywords,
+		Labels:                    csv.GetLabels(),
+		Links:                     spec.Links,
+		Maintainers:               spec.Maintainers,
+		Maturity:                  spec.Maturity,
+		MinKubeVersion:            spec.MinKubeVersion,
+		NativeAPIs:                spec.NativeAPIs,
+		Provider:                  spec.Provider,
+	})
+	props, err := buildProperties(values...)
+	if err != nil {
+		return nil, err
+	}
+	sortProperties(props)
+	return props, nil
+}
+
+// GenerateProperties derives an olm.gvk property for each served version of
+// the CRDs in the bundle's manifests directory.
+func (b plainV0Bundle) GenerateProperties() (Properties, error) {
+	var values []interface{}
+	for _, obj := range b.objs {
+		if obj.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		versions, _, _ := unstructured.NestedSlice(obj.Object, "spec", "versions")
+		for _, v := range versions {
+			v, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if served, ok := v["served"].(bool); ok && !served {
+				continue
+			}
+			name, _ := v["name"].(string)
+			values = append(values, &property.GVK{Group: group, Version: name, Kind: kind})
+		}
+	}
+	props, err := buildProperties(values...)
+	if err != nil {
+		return nil, err
+	}
+	sortProperties(props)
+	return props, nil
+}
+
+// crdGroup returns group, or derives it from a CRD name of the form
+// <plural>.<group> if group is empty.
+func crdGroup(group, name string) string {
+	if group != "" {
+		return group
+	}
+	_, group, _ = strings.Cut(name, ".")
+	return group
+}
+
+func buildProperties(values ...interface{}) (Properties, error) {
+	props := make(Properties, 0, len(values))
+	for _, v := range values {
+		p, err := property.Build(v)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, TypeValue{Type: p.Type, Value: p.Value})
+	}
+	return props, nil
+}
+
+func sortProperties(props Properties) {
+	sort.SliceStable(props, func(i, j int) bool {
+		if props[i].Type != props[j].Type {
+			return props[i].Type < props[j].Type
+		}
+		return string(props[i].Value) < string(props[j].Value)
+	})
+}
+
+// mergeProperties returns declared followed by the generated properties that
+// are not already declared.
+func mergeProperties(declared, generated Properties) Properties {
+	seen := map[string]struct{}{}
+	key := func(tv TypeValue) string {
+		// Round-trip the value so that equal values with differently ordered
+		// keys or different whitespace compare equal.
+		var v interface{}
+		if err := json.Unmarshal(tv.Value, &v); err != nil {
+			return tv.Type + "\x00" + string(tv.Value)
+		}
+		data, _ := json.Marshal(v)
+		return tv.Type + "\x00" + string(data)
+	}
+	out := append(Properties{}, declared...)
+	for _, tv := range declared {
+		seen[key(tv)] = struct{}{}
+	}
+	for _, tv := range generated {
+		if _, ok := seen[key(tv)]; ok {
+			continue
+		}
+		seen[key(tv)] = struct{}{}
+		out = append(out, tv)
+	}
+	return out
+}
+
+// manifestImages returns the sorted, unique images of all containers, init
+// containers and ephemeral containers in objs.
+func manifestImages(objs []unstructured.Unstructured) []string {
+	images := sets.New[string]()
+	for _, obj := range objs {
+		collectContainerImages(obj.Object, images)
+	}
+	return sets.List(images)
+}
+
+// collectContainerImages adds the images of all containers found anywhere in
+// obj to images, so that pod templates nested in any workload are found.
+func collectContainerImages(obj interface{}, images sets.Set[string]) {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			switch key {
+			case "containers", "initContainers", "ephemeralContainers":
+				containers, _ := val.([]interface{})
+				for _, c := range containers {
+					if c, ok := c.(map[string]interface{}); ok {
+						if img, ok := c["image"].(string); ok && img != "" {
+							images.Insert(img)
+						}
+					}
+				}
+			default:
+				collectContainerImages(val, images)
+			}
+		}
+	case []interface{}:
+		for _, val := range v {
+			collectContainerImages(val, images)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package v1
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+func TestCollectContainerImages(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{
+			name:     "pod",
+			manifest: "kind: Pod\nspec:\n  containers: [{image: a}]\n  initContainers: [{image: b}]\n  ephemeralContainers: [{image: c}]\n",
+			want:     []string{"a", "b", "c"},
+		},
+		{
+			name:     "cron job",
+			manifest: "kind: CronJob\nspec:\n  jobTemplate:\n    spec:\n      template:\n        spec:\n          containers: [{image: a}, {name: no-image}]\n",
+			want:     []string{"a"},
+		},
+		{
+			name:     "deployments in a cluster service version",
+			manifest: "kind: ClusterServiceVersion\nspec:\n  install:\n    spec:\n      deployments:\n      - spec:\n          template:\n            spec:\n              containers: [{image: a}, {image: a}]\n",
+			want:     []string{"a"},
+		},
+		{
+			name:     "no containers",
+			manifest: "kind: ConfigMap\ndata:\n  image: a\n",
+			want:     []string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var obj map[string]interface{}
+			if err := yaml.Unmarshal([]byte(tc.manifest), &obj); err != nil {
+				t.Fatal(err)
+			}
+			images := sets.New[string]()
+			collectContainerImages(obj, images)
+			if got := sets.List(images); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected images %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+const testCSVWithCRDs = `apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: foo.v1.0.0
+  labels:
+    operatorframework.io/arch.amd64: supported
+spec:
+  version: 1.0.0
+  displayName: Foo Operator
+  minKubeVersion: 1.25.0
+  installModes:
+  - type: AllNamespaces
+    supported: true
+  customresourcedefinitions:
+    owned:
+    - name: foos.example.com
+      version: v1
+      kind: Foo
+    required:
+    - name: bars.other.example.com
+      version: v1beta1
+      kind: Bar
+`
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+spec:
+  group: example.com
+  names:
+    kind: Foo
+    plural: foos
+  versions:
+  - name: v1
+    served: true
+  - name: v1alpha1
+    served: false
+  - name: v2
+    served: true
+`
+
+func TestGenerateProperties(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		format BundleFormat
+		fsys   fstest.MapFS
+		want   Properties
+	}{
+		{
+			name:   "registry+v1",
+			format: registryV1Format{},
+			fsys: fstest.MapFS{
+				"manifests/foo.clusterserviceversion.yaml": {Data: []byte(testCSVWithCRDs)},
+				"manifests/crd.yaml":                       {Data: []byte(testCRD)},
+				"metadata/annotations.yaml":                {Data: []byte("annotations:\n  operators.operatorframework.io.bundle.package.v1: foo\n")},
+			},
+			want: Properties{
+				{Type: "olm.csv.metadata", Value: []byte(`{"apiServiceDefinitions":{},"crdDescriptions":{"owned":[{"name":"foos.example.com","version":"v1","kind":"Foo"}],"required":[{"name":"bars.other.example.com","version":"v1beta1","kind":"Bar"}]},"displayName":"Foo Operator","installModes":[{"type":"AllNamespaces","supported":true}],"labels":{"operatorframework.io/arch.amd64":"supported"},"minKubeVersion":"1.25.0","provider":{}}`)},
+				{Type: "olm.gvk", Value: []byte(`{"group":"example.com","kind":"Foo","version":"v1"}`)},
+				{Type: "olm.gvk.required", Value: []byte(`{"group":"other.example.com","kind":"Bar","version":"v1beta1"}`)},
+			},
+		},
+		{
+			name:   "plain+v0 served CRD versions",
+			format: plainV0Format{},
+			fsys: fstest.MapFS{
+				"manifests/crd.yaml":        {Data: []byte(testCRD)},
+				"manifests/deployment.yaml": {Data: []byte(testDeployment)},
+				"metadata/annotations.yaml": {Data: []byte("annotations:\n  io.operatorframework.bundle.package: foo\n  io.operatorframework.bundle.version: 1.0.0\n")},
+			},
+			want: Properties{
+				{Type: "olm.gvk", Value: []byte(`{"group":"example.com","kind":"Foo","version":"v1"}`)},
+				{Type: "olm.gvk", Value: []byte(`{"group":"example.com","kind":"Foo","version":"v2"}`)},
+			},
+		},
+		{
+			name:   "plain+v0 without CRDs",
+			format: plainV0Format{},
+			fsys: fstest.MapFS{
+				"manifests/deployment.yaml": {Data: []byte(testDeployment)},
+				"metadata/annotations.yaml": {Data: []byte("annotations:\n  io.operatorframework.bundle.package: foo\n  io.operatorframework.bundle.version: 1.0.0\n")},
+			},
+			want: Properties{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed, err := tc.format.Parse(tc.fsys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parsed.(PropertyGenerator).GenerateProperties()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected properties %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
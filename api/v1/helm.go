@@ -0,0 +1,546 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"oras.land/oras-go/v2/content"
+	"sigs.k8s.io/yaml"
+
+	"github.com/joelanford/olm-oci/pkg/memfs"
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+const (
+	MediaTypeBundleFormatHelmV3 = "helm+v3"
+
+	MediaTypeHelmConfig     = "application/vnd.cncf.helm.config.v1+json"
+	MediaTypeHelmChartLayer = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+)
+
+// helmV3Format loads Helm charts, either as a chart directory or as a
+// packaged chart archive. The chart's name and version are the bundle's
+// package and version, its dependencies are required packages and its
+// related images are found by rendering the chart with its default values.
+// Charts that cannot be rendered with their default values, such as charts
+// with required values, have no related images.
+type helmV3Format struct{}
+
+type helmChart struct {
+	ch *chart.Chart
+
+	// dependencies are the chart's dependencies as declared, before
+	// rendering removes the disabled ones.
+	dependencies  []*chart.Dependency
+	relatedImages RelatedImages
+	warnings      []string
+}
+
+func (helmV3Format) Detect(fsys fs.FS) (bool, error) {
+	_, err := fs.Stat(fsys, chartutil.ChartfileName)
+	return err == nil, nil
+}
+
+func (helmV3Format) Parse(fsys fs.FS) (ParsedBundle, error) {
+	ch, err := loadHelmChart(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("load chart: %v", err)
+	}
+	if err := validateHelmChart(ch); err != nil {
+		return nil, err
+	}
+	c := helmChart{ch: ch, dependencies: append([]*chart.Dependency(nil), ch.Metadata.Dependencies...)}
+	if c.relatedImages, err = helmRelatedImages(ch); err != nil {
+		c.warnings = append(c.warnings, fmt.Sprintf("related images are unknown because the chart cannot be rendered with its default values: %v", err))
+	}
+	return c, nil
+}
+
+func (c helmChart) LoadMetadata() (BundleMetadata, error) {
+	return helmBundleMetadata(c.ch)
+}
+
+func (c helmChart) LoadRelatedImages() (RelatedImages, error) {
+	return c.relatedImages, nil
+}
+
+func (c helmChart) Warnings() ([]string, error) {
+	return c.warnings, nil
+}
+
+func (helmChart) LoadProperties() (Properties, error) {
+	return nil, nil
+}
+
+func (c helmChart) LoadConstraints() (Constraints, error) {
+	return helmConstraints(c.dependencies)
+}
+
+// loadHelmChart loads the chart whose files are fsys, skipping the files
+// that the chart's .helmignore excludes, as Helm does for chart directories.
+func loadHelmChart(fsys fs.FS) (*chart.Chart, error) {
+	ignore, err := loadHelmIgnore(fsys)
+	if err != nil {
+		return nil, err
+	}
+	var files []*loader.BufferedFile
+	if err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		if ignore.ignored(name, d.IsDir()) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() && d.Type()&fs.ModeSymlink == 0 {
+			return fmt.Errorf("cannot load irregular file %s as it has file mode type bits set", name)
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", name, err)
+		}
+		files = append(files, &loader.BufferedFile{Name: name, Data: bytes.TrimPrefix(data, utf8BOM)})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return loader.LoadFiles(files)
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// helmIgnore holds the rules of a chart's .helmignore file, followed by
+// Helm's default rule, which ignores hidden files in the templates
+// directory. They are matched as Helm matches them: the first rule that
+// matches decides, a rule with a trailing "/" only matches directories, a
+// rule containing a "/" is matched against the file's path and any other
+// rule against its base name, and a rule starting with "!" ignores the
+// files that it does not match.
+type helmIgnore []helmIgnoreRule
+
+type helmIgnoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	fullPath bool
+}
+
+func loadHelmIgnore(fsys fs.FS) (helmIgnore, error) {
+	var rules helmIgnore
+	data, err := fs.ReadFile(fsys, ".helmignore")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(bytes.TrimPrefix(data, utf8BOM)), "\n") {
+		r, ok, err := parseHelmIgnoreRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid .helmignore rule %q: %v", strings.TrimSpace(line), err)
+		}
+		if ok {
+			rules = append(rules, r)
+		}
+	}
+	r, _, _ := parseHelmIgnoreRule("templates/.?*")
+	return append(rules, r), nil
+}
+
+func parseHelmIgnoreRule(line string) (helmIgnoreRule, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return helmIgnoreRule{}, false, nil
+	}
+	if strings.Contains(line, "**") {
+		return helmIgnoreRule{}, false, errors.New("double-star (**) syntax is not supported")
+	}
+	if _, err := path.Match(line, "abc"); err != nil {
+		return helmIgnoreRule{}, false, err
+	}
+	var r helmIgnoreRule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	r.fullPath = strings.Contains(line, "/")
+	r.pattern = strings.TrimPrefix(line, "/")
+	return r, true, nil
+}
+
+func (hi helmIgnore) ignored(name string, isDir bool) bool {
+	for _, r := range hi {
+		target := name
+		if !r.fullPath {
+			target = path.Base(name)
+		}
+		matched, _ := path.Match(r.pattern, target)
+		if r.negate {
+			if (r.dirOnly && !isDir) || !matched {
+				return true
+			}
+			continue
+		}
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadHelmBundleFromRegistry loads a bundle from a Helm chart stored in an
+// OCI registry, such as one pushed with "helm push". The reference may have
+// an "oci://" prefix.
+func LoadHelmBundleFromRegistry(ctx context.Context, ref string, opts ...LoadBundleOption) (*Bundle, error) {
+	repo, _, desc, err := remote.ResolveNameAndReference(ctx, strings.TrimPrefix(ref, "oci://"))
+	if err != nil {
+		return nil, fmt.Errorf("resolve chart reference: %v", err)
+	}
+	manifestData, err := content.FetchAll(ctx, repo, *desc)
+	if err != nil {
+		return nil, fmt.Errorf("fetch chart manifest: %v", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("decode chart manifest: %v", err)
+	}
+	if manifest.Config.MediaType != MediaTypeHelmConfig {
+		return nil, fmt.Errorf("%s is not a helm chart: unexpected config media type %q", ref, manifest.Config.MediaType)
+	}
+	var chartData []byte
+	for _, layer := range manifest.Layers {
+		if layer.MediaType != MediaTypeHelmChartLayer {
+			continue
+		}
+		if chartData, err = content.FetchAll(ctx, repo, layer); err != nil {
+			return nil, fmt.Errorf("fetch chart content: %v", err)
+		}
+		break
+	}
+	if chartData == nil {
+		return nil, fmt.Errorf("%s is not a helm chart: no layer with media type %q", ref, MediaTypeHelmChartLayer)
+	}
+	contentFS, err := helmArchiveFS(bytes.NewReader(chartData))
+	if err != nil {
+		return nil, err
+	}
+	return loadBundle(contentFS, MediaTypeBundleFormatHelmV3, helmV3Format{}, append(opts, withWarningPrefix(ref))...)
+}
+
+func validateHelmChart(ch *chart.Chart) error {
+	if err := ch.Validate(); err != nil {
+		return err
+	}
+	if ch.Metadata.Type == "library" {
+		return fmt.Errorf("library charts are not installable")
+	}
+	return nil
+}
+
+func helmBundleMetadata(ch *chart.Chart) (BundleMetadata, error) {
+	version, err := semver.ParseTolerant(ch.Metadata.Version)
+	if err != nil {
+		return BundleMetadata{}, fmt.Errorf("invalid chart version %q: %v", ch.Metadata.Version, err)
+	}
+	return BundleMetadata{
+		Package: ch.Metadata.Name,
+		Version: version,
+	}, nil
+}
+
+// helmConstraints converts chart dependencies to olm.package.required
+// constraints. Helm version constraints are converted to the equivalent
+// semver ranges; see helmVersionRange.
+func helmConstraints(dependencies []*chart.Dependency) (Constraints, error) {
+	var constraints Constraints
+	for _, dep := range dependencies {
+		versionRange, err := helmVersionRange(dep.Version)
+		if err != nil {
+			return nil, fmt.Errorf("dependency %q: unsupported version constraint %q: %v", dep.Name, dep.Version, err)
+		}
+		value, err := json.Marshal(property.PackageRequired{
+			PackageName:  dep.Name,
+			VersionRange: versionRange,
+		})
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, TypeValue{
+			Type:  property.TypePackageRequired,
+			Value: value,
+		})
+	}
+	return constraints, nil
+}
+
+// helmVersionRange converts a Helm (Masterminds semver) version constraint to
+// a blang semver range, as used by olm.package.required. Comparisons, tilde
+// and caret ranges, hyphen ranges, wildcards and partial versions are
+// supported, joined with commas or spaces and with "||". Partial versions
+// and wildcards match every version they leave unspecified, so "1.2" and
+// "1.2.x" both become ">=1.2.0 <1.3.0".
+func helmVersionRange(constraint string) (string, error) {
+	var ors []string
+	for _, group := range strings.Split(constraint, "||") {
+		ands, err := helmConstraintGroup(group)
+		if err != nil {
+			return "", err
+		}
+		ors = append(ors, strings.Join(ands, " "))
+	}
+	versionRange := strings.Join(ors, " || ")
+	if _, err := semver.ParseRange(versionRange); err != nil {
+		return "", err
+	}
+	return versionRange, nil
+}
+
+// helmConstraintGroup converts the comparators of one "||"-separated group
+// of a Helm version constraint.
+func helmConstraintGroup(group string) ([]string, error) {
+	fields := strings.Fields(strings.ReplaceAll(group, ",", " "))
+	if len(fields) == 0 {
+		return []string{">=0.0.0"}, nil
+	}
+	var out []string
+	for i := 0; i < len(fields); i++ {
+		// An operator may be separated from its version by spaces.
+		field := fields[i]
+		if strings.TrimLeft(field, "=!<>~^") == "" && i+1 < len(fields) {
+			i++
+			field += fields[i]
+		}
+		if i+2 < len(fields) && fields[i+1] == "-" {
+			from, err := parseHelmVersion(field)
+			if err != nil {
+				return nil, err
+			}
+			to, err := parseHelmVersion(fields[i+2])
+			if err != nil {
+				return nil, err
+			}
+			i += 2
+			lower, err := helmComparator(">=", from)
+			if err != nil {
+				return nil, err
+			}
+			upper, err := helmComparator("<=", to)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, lower...)
+			out = append(out, upper...)
+			continue
+		}
+		version := strings.TrimLeft(field, "=!<>~^")
+		v, err := parseHelmVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		comparators, err := helmComparator(strings.TrimSuffix(field, version), v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, comparators...)
+	}
+	return out, nil
+}
+
+// helmVersion is a version in a Helm version constraint. Only the first n
+// components are specified; the others are wildcards.
+type helmVersion struct {
+	v semver.Version
+	n int
+}
+
+func parseHelmVersion(s string) (helmVersion, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if strings.ContainsAny(s, "-+") {
+		v, err := semver.Parse(s)
+		if err != nil {
+			return helmVersion{}, err
+		}
+		return helmVersion{v: v, n: 3}, nil
+	}
+	var hv helmVersion
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return helmVersion{}, fmt.Errorf("invalid version %q", s)
+	}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		if hv.n != i {
+			return helmVersion{}, fmt.Errorf("invalid version %q", s)
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return helmVersion{}, fmt.Errorf("invalid version %q", s)
+		}
+		switch i {
+		case 0:
+			hv.v.Major = n
+		case 1:
+			hv.v.Minor = n
+		case 2:
+			hv.v.Patch = n
+		}
+		hv.n++
+	}
+	for _, part := range parts[hv.n:] {
+		if part != "x" && part != "X" && part != "*" {
+			return helmVersion{}, fmt.Errorf("invalid version %q", s)
+		}
+	}
+	return hv, nil
+}
+
+// next returns the lowest version above every version that matches the
+// first n components of hv.
+func (hv helmVersion) next(n int) semver.Version {
+	switch n {
+	case 1:
+		return semver.Version{Major: hv.v.Major + 1}
+	case 2:
+		return semver.Version{Major: hv.v.Major, Minor: hv.v.Minor + 1}
+	default:
+		return semver.Version{Major: hv.v.Major, Minor: hv.v.Minor, Patch: hv.v.Patch + 1}
+	}
+}
+
+// helmComparator converts the Helm comparison of v with op to semver range
+// comparators.
+func helmComparator(op string, v helmVersion) ([]string, error) {
+	low := ">=" + v.v.String()
+	if v.n == 0 {
+		switch op {
+		case "", "=", ">=", "=>", "<=", "=<", "~", "~>", "^":
+			return []string{">=0.0.0"}, nil
+		}
+		return nil, fmt.Errorf("operator %q cannot be used with a wildcard version", op)
+	}
+	switch op {
+	case "", "=":
+		if v.n == 3 {
+			return []string{"=" + v.v.String()}, nil
+		}
+		return []string{low, "<" + v.next(v.n).String()}, nil
+	case "!=":
+		if v.n == 3 {
+			return []string{"!=" + v.v.String()}, nil
+		}
+		return nil, fmt.Errorf("operator %q cannot be used with a partial version", op)
+	case ">":
+		if v.n == 3 {
+			return []string{">" + v.v.String()}, nil
+		}
+		return []string{">=" + v.next(v.n).String()}, nil
+	case ">=", "=>":
+		return []string{low}, nil
+	case "<":
+		return []string{"<" + v.v.String()}, nil
+	case "<=", "=<":
+		if v.n == 3 {
+			return []string{"<=" + v.v.String()}, nil
+		}
+		return []string{"<" + v.next(v.n).String()}, nil
+	case "~", "~>":
+		if v.n == 1 {
+			return []string{low, "<" + v.next(1).String()}, nil
+		}
+		return []string{low, "<" + v.next(2).String()}, nil
+	case "^":
+		switch {
+		case v.v.Major > 0 || v.n == 1:
+			return []string{low, "<" + v.next(1).String()}, nil
+		case v.v.Minor > 0 || v.n == 2:
+			return []string{low, "<" + v.next(2).String()}, nil
+		default:
+			return []string{low, "<" + v.next(3).String()}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+// helmRelatedImages renders ch with its default values and returns the
+// images of every container in the rendered manifests. Dependencies that are
+// disabled by default are removed from ch.
+func helmRelatedImages(ch *chart.Chart) (RelatedImages, error) {
+	if err := chartutil.ProcessDependencies(ch, ch.Values); err != nil {
+		return nil, fmt.Errorf("process chart dependencies: %v", err)
+	}
+	values, err := chartutil.ToRenderValues(ch, ch.Values, chartutil.ReleaseOptions{
+		Name:      ch.Metadata.Name,
+		Namespace: "default",
+		IsInstall: true,
+	}, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, fmt.Errorf("compute render values: %v", err)
+	}
+	rendered, err := engine.Render(ch, values)
+	if err != nil {
+		return nil, fmt.Errorf("render chart: %v", err)
+	}
+
+	images := sets.New[string]()
+	for name, manifest := range rendered {
+		if path.Ext(name) != ".yaml" && path.Ext(name) != ".yml" {
+			continue
+		}
+		for _, doc := range releaseutil.SplitManifests(manifest) {
+			var obj map[string]interface{}
+			if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+				return nil, fmt.Errorf("parse rendered manifest %s: %v", name, err)
+			}
+			collectContainerImages(obj, images)
+		}
+	}
+
+	relatedImages := make(RelatedImages, 0, images.Len())
+	for _, img := range sets.List(images) {
+		relatedImages = append(relatedImages, RelatedImage{Image: img})
+	}
+	return relatedImages, nil
+}
+
+// helmArchiveFS returns the files of a packaged chart, relative to the
+// chart's root directory.
+func helmArchiveFS(r io.Reader) (fs.FS, error) {
+	files, err := loader.LoadArchiveFiles(r)
+	if err != nil {
+		return nil, fmt.Errorf("read chart archive: %v", err)
+	}
+	fsys := memfs.New()
+	for _, f := range files {
+		fsys.WriteFile(f.Name, f.Data, 0644, time.Time{})
+	}
+	return fsys, nil
+}
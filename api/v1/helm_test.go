@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestHelmVersionRange(t *testing.T) {
+	for _, tc := range []struct {
+		constraint string
+		want       string
+		wantErr    bool
+	}{
+		{constraint: "", want: ">=0.0.0"},
+		{constraint: "*", want: ">=0.0.0"},
+		{constraint: "1.2.3", want: "=1.2.3"},
+		{constraint: "v1.2.3", want: "=1.2.3"},
+		{constraint: "=1.2.3-alpha.1", want: "=1.2.3-alpha.1"},
+		{constraint: "1.2", want: ">=1.2.0 <1.3.0"},
+		{constraint: "1.2.x", want: ">=1.2.0 <1.3.0"},
+		{constraint: "1", want: ">=1.0.0 <2.0.0"},
+		{constraint: ">= 1.2, < 3.0.0", want: ">=1.2.0 <3.0.0"},
+		{constraint: ">1.2", want: ">=1.3.0"},
+		{constraint: "=<1.2", want: "<1.3.0"},
+		{constraint: "!=1.2.3", want: "!=1.2.3"},
+		{constraint: "~1.2.3", want: ">=1.2.3 <1.3.0"},
+		{constraint: "~1.2.x", want: ">=1.2.0 <1.3.0"},
+		{constraint: "~>1", want: ">=1.0.0 <2.0.0"},
+		{constraint: "^1.2", want: ">=1.2.0 <2.0.0"},
+		{constraint: "^0.2.3", want: ">=0.2.3 <0.3.0"},
+		{constraint: "^0.0.3", want: ">=0.0.3 <0.0.4"},
+		{constraint: "^0.0", want: ">=0.0.0 <0.1.0"},
+		{constraint: "1.2 - 1.4", want: ">=1.2.0 <1.5.0"},
+		{constraint: "1.2.3 - 1.4.5", want: ">=1.2.3 <=1.4.5"},
+		{constraint: "^1.2 || ~2.0.1", want: ">=1.2.0 <2.0.0 || >=2.0.1 <2.1.0"},
+		{constraint: "!=1.2", wantErr: true},
+		{constraint: ">*", wantErr: true},
+		{constraint: "1.x.3", wantErr: true},
+		{constraint: "1.2.3.4", wantErr: true},
+		{constraint: "latest", wantErr: true},
+		{constraint: "%1.2.3", wantErr: true},
+	} {
+		t.Run(tc.constraint, func(t *testing.T) {
+			got, err := helmVersionRange(tc.constraint)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestHelmIgnore(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		helmignore string
+		path       string
+		isDir      bool
+		want       bool
+	}{
+		{name: "default hidden template", path: "templates/.notes.yaml", want: true},
+		{name: "default visible template", path: "templates/deployment.yaml"},
+		{name: "default only applies to templates", path: ".hidden"},
+		{name: "base name", helmignore: "*.swp", path: "templates/x.swp", want: true},
+		{name: "full path", helmignore: "ci/*.yaml", path: "ci/values.yaml", want: true},
+		{name: "full path does not match base name", helmignore: "ci/*.yaml", path: "values.yaml"},
+		{name: "leading slash matches full path", helmignore: "/values.yaml", path: "sub/values.yaml"},
+		{name: "leading slash", helmignore: "/values.yaml", path: "values.yaml", want: true},
+		{name: "directory rule matches directory", helmignore: ".git/", path: ".git", isDir: true, want: true},
+		{name: "directory rule skips files", helmignore: ".git/", path: ".git"},
+		{name: "comments and blank lines", helmignore: "# *.yaml\n\n", path: "values.yaml"},
+		{name: "negation ignores what it does not match", helmignore: "!*.yaml", path: "README.md", want: true},
+		{name: "negation keeps what it matches", helmignore: "!*.yaml", path: "values.yaml"},
+		{name: "first match wins", helmignore: "*.md\n!README.md", path: "README.md", want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			if tc.helmignore != "" {
+				fsys[".helmignore"] = &fstest.MapFile{Data: []byte(tc.helmignore)}
+			}
+			ignore, err := loadHelmIgnore(fsys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ignore.ignored(tc.path, tc.isDir); got != tc.want {
+				t.Errorf("expected ignored %t, got %t", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := loadHelmIgnore(fstest.MapFS{".helmignore": {Data: []byte("charts/**")}}); err == nil {
+		t.Errorf("expected error for a double-star rule")
+	}
+}
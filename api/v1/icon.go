@@ -0,0 +1,401 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	MediaTypeIconPNG  = "image/png"
+	MediaTypeIconJPEG = "image/jpeg"
+	MediaTypeIconGIF  = "image/gif"
+	MediaTypeIconWebP = "image/webp"
+	MediaTypeIconSVG  = "image/svg+xml"
+
+	// MaxIconSize is the largest icon, in bytes, that a package may have.
+	MaxIconSize = 1 << 20
+
+	// MaxIconDimension is the largest width or height, in pixels, of a
+	// raster icon.
+	MaxIconDimension = 1024
+)
+
+// IsIconMediaType reports whether mediaType is a supported icon format.
+func IsIconMediaType(mediaType string) bool {
+	switch mediaType {
+	case MediaTypeIconPNG, MediaTypeIconJPEG, MediaTypeIconGIF, MediaTypeIconWebP, MediaTypeIconSVG:
+		return true
+	}
+	return false
+}
+
+// iconExtension returns the file extension for an icon of mediaType.
+func iconExtension(mediaType string) string {
+	switch mediaType {
+	case MediaTypeIconPNG:
+		return ".png"
+	case MediaTypeIconJPEG:
+		return ".jpg"
+	case MediaTypeIconGIF:
+		return ".gif"
+	case MediaTypeIconWebP:
+		return ".webp"
+	case MediaTypeIconSVG:
+		return ".svg"
+	}
+	return ""
+}
+
+// NewIcon returns an icon for data, whose media type is detected from its
+// content. SVG icons are sanitized. An error is returned if the format is
+// not supported or the icon exceeds the size or dimension limits.
+func NewIcon(data []byte) (*Icon, error) {
+	mediaType, err := detectIconMediaType(data)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType == MediaTypeIconSVG {
+		if data, err = sanitizeSVG(data); err != nil {
+			return nil, fmt.Errorf("sanitize svg: %v", err)
+		}
+	}
+	icon := &Icon{ImageData: data, ImageMediaType: mediaType}
+	if err := icon.Validate(); err != nil {
+		return nil, err
+	}
+	return icon, nil
+}
+
+// IconInfo describes an icon without its data. Width and Height are zero if
+// they cannot be determined, as for SVG icons without explicit dimensions.
+type IconInfo struct {
+	MediaType string `json:"mediaType"`
+	Size      int    `json:"size"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+}
+
+func (i Icon) Info() (IconInfo, error) {
+	info := IconInfo{MediaType: i.ImageMediaType, Size: len(i.ImageData)}
+	var err error
+	switch i.ImageMediaType {
+	case MediaTypeIconPNG, MediaTypeIconJPEG, MediaTypeIconGIF:
+		var cfg image.Config
+		if cfg, _, err = image.DecodeConfig(bytes.NewReader(i.ImageData)); err == nil {
+			info.Width, info.Height = cfg.Width, cfg.Height
+		}
+	case MediaTypeIconWebP:
+		info.Width, info.Height, err = webpDimensions(i.ImageData)
+	case MediaTypeIconSVG:
+		info.Width, info.Height, err = svgDimensions(i.ImageData)
+	default:
+		return IconInfo{}, fmt.Errorf("unsupported icon media type %q", i.ImageMediaType)
+	}
+	if err != nil {
+		return IconInfo{}, fmt.Errorf("decode %s icon: %v", i.ImageMediaType, err)
+	}
+	return info, nil
+}
+
+// Validate checks that the icon's media type is supported and that it is
+// within MaxIconSize and, for raster icons, MaxIconDimension.
+func (i Icon) Validate() error {
+	if len(i.ImageData) > MaxIconSize {
+		return fmt.Errorf("icon is %d bytes, larger than the maximum of %d bytes", len(i.ImageData), MaxIconSize)
+	}
+	info, err := i.Info()
+	if err != nil {
+		return err
+	}
+	if info.MediaType != MediaTypeIconSVG && (info.Width > MaxIconDimension || info.Height > MaxIconDimension) {
+		return fmt.Errorf("icon is %dx%d pixels, larger than the maximum of %dx%d pixels", info.Width, info.Height, MaxIconDimension, MaxIconDimension)
+	}
+	return nil
+}
+
+func detectIconMediaType(data []byte) (string, error) {
+	mediaType := http.DetectContentType(data)
+	if IsIconMediaType(mediaType) {
+		return mediaType, nil
+	}
+	if isSVG(data) {
+		return MediaTypeIconSVG, nil
+	}
+	return "", fmt.Errorf("unsupported icon format %q", mediaType)
+}
+
+// loadIcon loads the icon named by the icon field of metadataFile, relative
+// to dir, or if it is not set, the first of the conventional icon files in
+// dir that exists.
+func loadIcon(dir, metadataFile string) (*Icon, error) {
+	data, err := os.ReadFile(metadataFile)
+	if err != nil {
+		return nil, err
+	}
+	var metadata struct {
+		Icon string `json:"icon"`
+	}
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+
+	file := ""
+	if metadata.Icon != "" {
+		file = filepath.Join(dir, filepath.FromSlash(metadata.Icon))
+	} else {
+		for _, name := range []string{"icon.svg", "icon.png", "icon.jpg", "icon.jpeg", "icon.gif", "icon.webp"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				file = filepath.Join(dir, name)
+				break
+			}
+		}
+		if file == "" {
+			return nil, nil
+		}
+	}
+	iconData, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	icon, err := NewIcon(iconData)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(file), err)
+	}
+	return icon, nil
+}
+
+// webpDimensions reads the canvas size from the header of a lossy, lossless
+// or extended WebP image.
+func webpDimensions(data []byte) (int, int, error) {
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, fmt.Errorf("invalid webp header")
+	}
+	switch string(data[12:16]) {
+	case "VP8 ":
+		w := binary.LittleEndian.Uint16(data[26:28]) & 0x3fff
+		h := binary.LittleEndian.Uint16(data[28:30]) & 0x3fff
+		return int(w), int(h), nil
+	case "VP8L":
+		bits := binary.LittleEndian.Uint32(data[21:25])
+		return int(bits&0x3fff) + 1, int((bits>>14)&0x3fff) + 1, nil
+	case "VP8X":
+		w := uint32(data[24]) | uint32(data[25])<<8 | uint32(data[26])<<16
+		h := uint32(data[27]) | uint32(data[28])<<8 | uint32(data[29])<<16
+		return int(w) + 1, int(h) + 1, nil
+	}
+	return 0, 0, fmt.Errorf("unknown webp chunk %q", data[12:16])
+}
+
+func svgRoot(data []byte) (*xml.StartElement, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return nil, err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return &se, nil
+		}
+	}
+}
+
+func isSVG(data []byte) bool {
+	root, err := svgRoot(data)
+	return err == nil && root.Name.Local == "svg"
+}
+
+// svgDimensions returns the size of an SVG from the width and height
+// attributes of its root element, falling back to its viewBox.
+func svgDimensions(data []byte) (int, int, error) {
+	root, err := svgRoot(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	var width, height, viewBox string
+	for _, attr := range root.Attr {
+		switch attr.Name.Local {
+		case "width":
+			width = attr.Value
+		case "height":
+			height = attr.Value
+		case "viewBox":
+			viewBox = attr.Value
+		}
+	}
+	w, wErr := strconv.ParseFloat(strings.TrimSuffix(width, "px"), 64)
+	h, hErr := strconv.ParseFloat(strings.TrimSuffix(height, "px"), 64)
+	if wErr == nil && hErr == nil {
+		return int(w), int(h), nil
+	}
+	if fields := strings.Fields(strings.ReplaceAll(viewBox, ",", " ")); len(fields) == 4 {
+		w, wErr := strconv.ParseFloat(fields[2], 64)
+		h, hErr := strconv.ParseFloat(fields[3], 64)
+		if wErr == nil && hErr == nil {
+			return int(w), int(h), nil
+		}
+	}
+	return 0, 0, nil
+}
+
+// svgElements are the SVG elements that sanitizeSVG keeps. Other elements,
+// such as script, style, foreignObject and the animation elements, are
+// removed along with their content.
+var svgElements = sets.New[string](
+	"svg", "g", "defs", "symbol", "use", "title", "desc", "a", "switch",
+	"path", "rect", "circle", "ellipse", "line", "polyline", "polygon",
+	"text", "tspan", "textPath",
+	"linearGradient", "radialGradient", "stop", "pattern", "clipPath", "mask", "marker",
+	"filter", "feBlend", "feColorMatrix", "feComponentTransfer", "feComposite",
+	"feDropShadow", "feFlood", "feFuncA", "feFuncB", "feFuncG", "feFuncR",
+	"feGaussianBlur", "feMerge", "feMergeNode", "feMorphology", "feOffset",
+)
+
+// svgAttributes are the attributes without a namespace prefix that
+// sanitizeSVG keeps.
+var svgAttributes = sets.New[string](
+	"id", "class", "style", "version", "viewBox", "preserveAspectRatio", "transform",
+	"x", "y", "x1", "y1", "x2", "y2", "cx", "cy", "r", "rx", "ry", "fx", "fy", "fr",
+	"width", "height", "d", "points", "pathLength", "dx", "dy", "rotate",
+	"fill", "fill-opacity", "fill-rule", "stroke", "stroke-width", "stroke-linecap",
+	"stroke-linejoin", "stroke-miterlimit", "stroke-dasharray", "stroke-dashoffset",
+	"stroke-opacity", "opacity", "color", "display", "visibility", "overflow",
+	"clip-path", "clip-rule", "mask", "filter", "marker-start", "marker-mid", "marker-end",
+	"paint-order", "vector-effect", "shape-rendering", "text-rendering",
+	"color-interpolation", "color-interpolation-filters",
+	"font-family", "font-size", "font-style", "font-weight", "font-variant",
+	"text-anchor", "dominant-baseline", "alignment-baseline", "baseline-shift",
+	"letter-spacing", "word-spacing", "text-decoration", "textLength", "lengthAdjust",
+	"startOffset", "method", "spacing", "side", "lang",
+	"gradientUnits", "gradientTransform", "spreadMethod", "offset", "stop-color", "stop-opacity",
+	"patternUnits", "patternContentUnits", "patternTransform", "clipPathUnits",
+	"maskUnits", "maskContentUnits", "markerUnits", "markerWidth", "markerHeight",
+	"refX", "refY", "orient", "filterUnits", "primitiveUnits",
+	"in", "in2", "result", "mode", "type", "values", "operator", "k1", "k2", "k3", "k4",
+	"stdDeviation", "radius", "flood-color", "flood-opacity", "tableValues",
+	"slope", "intercept", "amplitude", "exponent",
+	"href", "requiredFeatures", "requiredExtensions", "systemLanguage",
+)
+
+// sanitizeSVG keeps only the elements in svgElements and the attributes in
+// svgAttributes of an SVG document, along with namespace declarations and
+// the xlink:href, xml:space and xml:lang attributes. Links must be fragment
+// references or http(s) URLs, and url() references in attribute values must
+// be fragment references, so that an icon cannot run script or load other
+// content. Comments and directives such as DOCTYPE declarations are removed.
+func sanitizeSVG(data []byte) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		out  bytes.Buffer
+		skip int
+	)
+	qname := func(n xml.Name) string {
+		if n.Space == "" {
+			return n.Local
+		}
+		return n.Space + ":" + n.Local
+	}
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skip > 0 || t.Name.Space != "" || !svgElements.Has(t.Name.Local) {
+				skip++
+				continue
+			}
+			out.WriteString("<" + qname(t.Name))
+			for _, attr := range t.Attr {
+				if !svgAttributeAllowed(attr) {
+					continue
+				}
+				out.WriteString(" " + qname(attr.Name) + `="`)
+				if err := xml.EscapeText(&out, []byte(attr.Value)); err != nil {
+					return nil, err
+				}
+				out.WriteString(`"`)
+			}
+			out.WriteString(">")
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			out.WriteString("</" + qname(t.Name) + ">")
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+			if err := xml.EscapeText(&out, t); err != nil {
+				return nil, err
+			}
+		case xml.ProcInst:
+			if skip == 0 && t.Target == "xml" {
+				out.WriteString("<?xml " + string(t.Inst) + "?>")
+			}
+		}
+	}
+	return out.Bytes(), nil
+}
+
+func svgAttributeAllowed(attr xml.Attr) bool {
+	switch {
+	case attr.Name.Space == "xmlns" || attr.Name.Space == "" && attr.Name.Local == "xmlns":
+		return true
+	case attr.Name.Space == "xml":
+		return attr.Name.Local == "space" || attr.Name.Local == "lang"
+	case attr.Name.Space == "xlink":
+		return attr.Name.Local == "href" && safeSVGLink(attr.Value)
+	case attr.Name.Space != "" || !svgAttributes.Has(attr.Name.Local):
+		return false
+	case attr.Name.Local == "href":
+		return safeSVGLink(attr.Value)
+	}
+	return safeSVGURLReferences(attr.Value)
+}
+
+// safeSVGLink reports whether link is a fragment reference or an http(s)
+// URL.
+func safeSVGLink(link string) bool {
+	link = strings.ToLower(strings.TrimSpace(link))
+	return strings.HasPrefix(link, "#") || strings.HasPrefix(link, "https://") || strings.HasPrefix(link, "http://")
+}
+
+// safeSVGURLReferences reports whether every url() reference in value, such
+// as fill="url(#gradient)", is a fragment reference, and value has no other
+// CSS constructs that can load content or run script.
+func safeSVGURLReferences(value string) bool {
+	value = strings.ToLower(value)
+	if strings.Contains(value, "expression(") || strings.Contains(value, "@import") || strings.Contains(value, "javascript:") {
+		return false
+	}
+	for {
+		i := strings.Index(value, "url(")
+		if i < 0 {
+			return true
+		}
+		value = strings.TrimLeft(value[i+len("url("):], " \t\n\r'\"")
+		if !strings.HasPrefix(value, "#") {
+			return false
+		}
+	}
+}
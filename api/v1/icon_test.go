@@ -0,0 +1,285 @@
+package v1
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "clean",
+			in:   `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="16" height="16"><rect width="16" height="16" fill="red"></rect></svg>`,
+			want: `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="16" height="16"><rect width="16" height="16" fill="red"></rect></svg>`,
+		},
+		{
+			name: "script elements",
+			in:   `<svg><script>alert(1)</script><SCRIPT type="text/javascript"><g>nested</g></SCRIPT><circle r="1"/></svg>`,
+			want: `<svg><circle r="1"></circle></svg>`,
+		},
+		{
+			name: "event handlers",
+			in:   `<svg onload="alert(1)"><rect OnClick="alert(2)" width="1"/></svg>`,
+			want: `<svg><rect width="1"></rect></svg>`,
+		},
+		{
+			name: "javascript links",
+			in:   `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a href=" JavaScript:alert(1)"><use xlink:href="javascript:alert(2)"/></a><a href="https://example.com">ok</a></svg>`,
+			want: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a><use></use></a><a href="https://example.com">ok</a></svg>`,
+		},
+		{
+			name: "comments and directives",
+			in:   `<!DOCTYPE svg [<!ENTITY x "y">]><svg><!-- hidden --><?foo bar?><title>a &amp; b</title></svg>`,
+			want: `<svg><title>a &amp; b</title></svg>`,
+		},
+		{
+			name: "escaped attribute values",
+			in:   `<svg><text font-family="&quot;Sans&quot; &lt;x&gt;">1</text></svg>`,
+			want: `<svg><text font-family="&#34;Sans&#34; &lt;x&gt;">1</text></svg>`,
+		},
+		{
+			name: "animation elements",
+			in:   `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a href="#x"><animate attributeName="href" to="javascript:alert(1)"/><set attributeName="href" to="javascript:alert(2)"/></a><animateTransform attributeName="transform"/></svg>`,
+			want: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a href="#x"></a></svg>`,
+		},
+		{
+			name: "foreign objects and style elements",
+			in:   `<svg><foreignObject><iframe src="https://example.com"></iframe></foreignObject><style>@import url(https://example.com/x.css);</style><rect width="1"/></svg>`,
+			want: `<svg><rect width="1"></rect></svg>`,
+		},
+		{
+			name: "data links",
+			in:   `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a href="data:text/html,x"><use xlink:href=" DATA:image/svg+xml;base64,PHN2Zz4="/></a><use href="#shape"/></svg>`,
+			want: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a><use></use></a><use href="#shape"></use></svg>`,
+		},
+		{
+			name: "url references",
+			in:   `<svg><rect fill="url(#gradient)" stroke="url( 'https://example.com/x.svg#p' )" style="fill: url(&quot;#g&quot;)"/><circle style="background: url(https://example.com/x.png)" r="1"/></svg>`,
+			want: `<svg><rect fill="url(#gradient)" style="fill: url(&#34;#g&#34;)"></rect><circle r="1"></circle></svg>`,
+		},
+		{
+			name: "unknown elements and attributes",
+			in:   `<svg xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" xml:space="preserve"><inkscape:grid/><image href="https://example.com/x.png"/><g inkscape:label="layer" data-x="1" xml:lang="en"><path d="M0 0"/></g></svg>`,
+			want: `<svg xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" xml:space="preserve"><g xml:lang="en"><path d="M0 0"></path></g></svg>`,
+		},
+		{
+			name:    "malformed",
+			in:      `<svg><rect width="1></svg>`,
+			wantErr: "XML syntax error",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := sanitizeSVG([]byte(tc.in))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+// testWebP returns a WebP image whose first chunk is chunk with payload.
+func testWebP(chunk string, payload ...byte) []byte {
+	data := append([]byte("RIFF\x00\x00\x00\x00WEBP"+chunk+"\x00\x00\x00\x00"), payload...)
+	for len(data) < 30 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+// testPNG returns a PNG image of the given size.
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWebPDimensions(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		data       []byte
+		wantWidth  int
+		wantHeight int
+		wantErr    string
+	}{
+		{
+			name: "lossy",
+			// frame tag, start code, 14-bit width and height with scale bits
+			data:       testWebP("VP8 ", 0, 0, 0, 0x9d, 0x01, 0x2a, 0x40, 0xc1, 0x20, 0x00),
+			wantWidth:  320,
+			wantHeight: 32,
+		},
+		{
+			name: "lossless",
+			// signature, then width-1 and height-1 in 14 bits each
+			data:       testWebP("VP8L", 0x2f, 0x3f, 0xc0, 0x0f, 0x00),
+			wantWidth:  64,
+			wantHeight: 64,
+		},
+		{
+			name: "extended",
+			// flags, then width-1 and height-1 in 24 bits each
+			data:       testWebP("VP8X", 0, 0, 0, 0, 0xff, 0x03, 0x00, 0x7f, 0x00, 0x00),
+			wantWidth:  1024,
+			wantHeight: 128,
+		},
+		{
+			name:    "unknown chunk",
+			data:    testWebP("ABCD"),
+			wantErr: `unknown webp chunk "ABCD"`,
+		},
+		{
+			name:    "truncated",
+			data:    []byte("RIFF\x00\x00\x00\x00WEBPVP8 "),
+			wantErr: "invalid webp header",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w, h, err := webpDimensions(tc.data)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w != tc.wantWidth || h != tc.wantHeight {
+				t.Errorf("expected %dx%d, got %dx%d", tc.wantWidth, tc.wantHeight, w, h)
+			}
+		})
+	}
+}
+
+func TestNewIcon(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		data          []byte
+		wantMediaType string
+		wantErr       string
+	}{
+		{name: "png", data: testPNG(t, 16, 16), wantMediaType: MediaTypeIconPNG},
+		{name: "webp", data: testWebP("VP8L", 0x2f, 0x3f, 0xc0, 0x0f, 0x00), wantMediaType: MediaTypeIconWebP},
+		{name: "svg", data: []byte(`<?xml version="1.0"?><svg width="16" height="16"></svg>`), wantMediaType: MediaTypeIconSVG},
+		{name: "svg with leading comment", data: []byte(`<!-- icon --><svg viewBox="0 0 4096 4096"></svg>`), wantMediaType: MediaTypeIconSVG},
+		{name: "not an svg document", data: []byte(`<html></html>`), wantErr: "unsupported icon format"},
+		{name: "text", data: []byte("icon"), wantErr: `unsupported icon format "text/plain; charset=utf-8"`},
+		{name: "raster at the dimension limit", data: testPNG(t, MaxIconDimension, 1), wantMediaType: MediaTypeIconPNG},
+		{name: "raster over the dimension limit", data: testPNG(t, 1, MaxIconDimension+1), wantErr: "icon is 1x1025 pixels, larger than the maximum of 1024x1024 pixels"},
+		{name: "webp over the dimension limit", data: testWebP("VP8X", 0, 0, 0, 0, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00), wantErr: "icon is 2049x1 pixels"},
+		{name: "svg larger than the raster dimension limit", data: []byte(`<svg width="4096" height="4096"></svg>`), wantMediaType: MediaTypeIconSVG},
+		{name: "over the size limit", data: []byte(`<svg>` + strings.Repeat(" ", MaxIconSize) + `</svg>`), wantErr: "larger than the maximum of 1048576 bytes"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			icon, err := NewIcon(tc.data)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if icon.ImageMediaType != tc.wantMediaType {
+				t.Errorf("expected media type %q, got %q", tc.wantMediaType, icon.ImageMediaType)
+			}
+		})
+	}
+}
+
+func TestLoadIcon(t *testing.T) {
+	svg := []byte(`<svg width="16" height="16"></svg>`)
+	for _, tc := range []struct {
+		name     string
+		metadata string
+		files    map[string][]byte
+		want     []byte
+		wantErr  string
+	}{
+		{
+			name:     "icon field",
+			metadata: "icon: images/logo.svg\n",
+			files:    map[string][]byte{"images/logo.svg": svg, "icon.png": testPNG(t, 1, 1)},
+			want:     svg,
+		},
+		{
+			name:     "missing icon field file",
+			metadata: "icon: logo.svg\n",
+			files:    map[string][]byte{"icon.svg": svg},
+			wantErr:  "no such file or directory",
+		},
+		{
+			name:     "invalid icon field file",
+			metadata: "icon: logo.svg\n",
+			files:    map[string][]byte{"logo.svg": []byte("icon")},
+			wantErr:  "logo.svg: unsupported icon format",
+		},
+		{
+			name:     "conventional file",
+			metadata: "name: foo\n",
+			files:    map[string][]byte{"icon.svg": svg},
+			want:     svg,
+		},
+		{
+			name:     "no icon",
+			metadata: "name: foo\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			metadataFile := filepath.Join(dir, "package.yaml")
+			if err := os.WriteFile(metadataFile, []byte(tc.metadata), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for name, data := range tc.files {
+				name = filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(name, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			icon, err := loadIcon(dir, metadataFile)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []byte
+			if icon != nil {
+				got = icon.ImageData
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("expected icon %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
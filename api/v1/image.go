@@ -0,0 +1,108 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/joelanford/olm-oci/pkg/memfs"
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+const (
+	bundleLabelPrefix          = "operators.operatorframework.io.bundle."
+	bundleImageAnnotationsPath = "metadata/annotations.yaml"
+)
+
+// LoadBundleImage loads a bundle from a legacy bundle container image, such
+// as a registry+v1 bundle image. The image's manifests/ and metadata/
+// directories are unpacked in memory and loaded by LoadBundleFS. The image's
+// operators.operatorframework.io.bundle.* config labels fill in any keys
+// missing from metadata/annotations.yaml (or the whole file, if the image
+// has none), and must agree with the keys that are present. The image's
+// legacy channel annotations are kept as the bundle's MigrationHints.
+func LoadBundleImage(ctx context.Context, imageRef string, opts ...LoadBundleOption) (*Bundle, error) {
+	img, err := remote.FetchImage(ctx, imageRef, func(_ map[string]string, name string) bool {
+		return name == "manifests" || name == "metadata" ||
+			strings.HasPrefix(name, "manifests/") || strings.HasPrefix(name, "metadata/")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetch bundle image: %v", err)
+	}
+	return loadBundleImageFS(img.FS, img.Labels, opts...)
+}
+
+// loadBundleImageFS loads the bundle in the unpacked content fsys of a
+// bundle image with the given config labels.
+func loadBundleImageFS(fsys fs.FS, labels map[string]string, opts ...LoadBundleOption) (*Bundle, error) {
+	contentFS, err := mergeBundleImageLabels(fsys, labels)
+	if err != nil {
+		return nil, fmt.Errorf("merge bundle image labels: %v", err)
+	}
+	b, err := LoadBundleFS(contentFS, opts...)
+	if err != nil {
+		return nil, err
+	}
+	annotations, _ := loadBundleMetadataAnnotations(contentFS)
+	b.MigrationHints = migrationHints(annotations)
+	return b, nil
+}
+
+// mergeBundleImageLabels returns fsys with the bundle labels merged into its
+// metadata/annotations.yaml. It returns an error if a label disagrees with
+// the annotation of the same key.
+func mergeBundleImageLabels(fsys fs.FS, labels map[string]string) (fs.FS, error) {
+	var annotations annotationsFile
+	data, err := fs.ReadFile(fsys, bundleImageAnnotationsPath)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(data, &annotations); err != nil {
+			return nil, fmt.Errorf("unmarshal %s: %v", bundleImageAnnotationsPath, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("read %s: %v", bundleImageAnnotationsPath, err)
+	}
+	if annotations.Annotations == nil {
+		annotations.Annotations = map[string]string{}
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		if strings.HasPrefix(k, bundleLabelPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	changed := false
+	for _, k := range keys {
+		v, ok := annotations.Annotations[k]
+		if !ok {
+			annotations.Annotations[k] = labels[k]
+			changed = true
+			continue
+		}
+		if v != labels[k] {
+			return nil, fmt.Errorf("label %s=%q does not match %s value %q", k, labels[k], bundleImageAnnotationsPath, v)
+		}
+	}
+	if !changed {
+		return fsys, nil
+	}
+
+	data, err = yaml.Marshal(annotations)
+	if err != nil {
+		return nil, err
+	}
+	out, err := memfs.Copy(fsys)
+	if err != nil {
+		return nil, err
+	}
+	out.WriteFile(bundleImageAnnotationsPath, data, 0644, time.Time{})
+	return out, nil
+}
@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"sigs.k8s.io/yaml"
+)
+
+func TestMergeBundleImageLabels(t *testing.T) {
+	labels := map[string]string{
+		labelBundleMediaType: MediaTypeBundleFormatRegistryV1,
+		labelBundlePackage:   "foo",
+		"com.example.other":  "ignored",
+	}
+	for _, tc := range []struct {
+		name        string
+		annotations string
+		want        map[string]string
+		wantErr     string
+	}{
+		{
+			name: "no annotations file",
+			want: map[string]string{
+				labelBundleMediaType: MediaTypeBundleFormatRegistryV1,
+				labelBundlePackage:   "foo",
+			},
+		},
+		{
+			name:        "labels fill in missing keys",
+			annotations: "annotations:\n  " + labelBundlePackage + ": foo\n  " + labelBundleChannels + ": stable\n",
+			want: map[string]string{
+				labelBundleMediaType: MediaTypeBundleFormatRegistryV1,
+				labelBundlePackage:   "foo",
+				labelBundleChannels:  "stable",
+			},
+		},
+		{
+			name:        "label disagrees with annotation",
+			annotations: "annotations:\n  " + labelBundlePackage + ": bar\n",
+			wantErr:     "does not match",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"manifests/csv.yaml": {Data: []byte("{}")}}
+			if tc.annotations != "" {
+				fsys[bundleImageAnnotationsPath] = &fstest.MapFile{Data: []byte(tc.annotations)}
+			}
+			out, err := mergeBundleImageLabels(fsys, labels)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := fs.ReadFile(out, bundleImageAnnotationsPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got annotationsFile
+			if err := yaml.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Annotations, tc.want) {
+				t.Errorf("expected annotations %v, got %v", tc.want, got.Annotations)
+			}
+			if _, err := fs.Stat(out, "manifests/csv.yaml"); err != nil {
+				t.Errorf("expected manifests to be kept: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+const (
+	labelIndexConfigs  = "operators.operatorframework.io.index.configs.v1"
+	labelIndexDatabase = "operators.operatorframework.io.index.database.v1"
+
+	defaultIndexConfigs  = "configs"
+	defaultIndexDatabase = "database/index.db"
+)
+
+// LoadIndexImage loads a catalog from a legacy index image, which is either
+// a SQLite index built with "opm index" or an image containing a file-based
+// catalog. SQLite indexes are only supported by builds with the sqlite build
+// tag. Every bundle image referenced by the index is imported with
+// LoadBundleImage. It is an error if a bundle cannot be imported, unless
+// WithSkipUnloadableBundles is set.
+func LoadIndexImage(ctx context.Context, indexRef string, opts ...LoadBundleOption) (*Catalog, error) {
+	o := loadBundleOptions{warn: func(string) {}}
+	for _, opt := range append(opts, withWarningPrefix(indexRef)) {
+		opt(&o)
+	}
+
+	// Only the catalog that the image's labels point at is unpacked.
+	img, err := remote.FetchImage(ctx, indexRef, func(labels map[string]string, name string) bool {
+		configsDir, dbPath := indexPaths(labels)
+		return name == configsDir || strings.HasPrefix(name, configsDir+"/") || name == dbPath
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetch index image: %v", err)
+	}
+
+	cfg, err := loadIndexDeclarativeConfig(ctx, img)
+	if err != nil {
+		return nil, err
+	}
+	return catalogFromDeclarativeConfig(ctx, cfg, func(ctx context.Context, image string) (*Bundle, error) {
+		return LoadBundleImage(ctx, image, opts...)
+	}, o.skipUnloadableBundles, o.warn)
+}
+
+// fbcIcon returns the icon for data from a file-based catalog, validated and
+// sanitized like the icons of catalog directories. Invalid icons are
+// reported to warn and dropped.
+func fbcIcon(data []byte, owner string, warn func(msg string)) *Icon {
+	icon, err := NewIcon(data)
+	if err != nil {
+		warn(fmt.Sprintf("dropping icon of %s: %v", owner, err))
+		return nil
+	}
+	return icon
+}
+
+// indexPaths returns the paths of the file-based catalog directory and the
+// SQLite database of an index image with the given labels.
+func indexPaths(labels map[string]string) (configsDir, dbPath string) {
+	configsDir = strings.Trim(labels[labelIndexConfigs], "/")
+	if configsDir == "" {
+		configsDir = defaultIndexConfigs
+	}
+	dbPath = strings.Trim(labels[labelIndexDatabase], "/")
+	if dbPath == "" {
+		dbPath = defaultIndexDatabase
+	}
+	return configsDir, dbPath
+}
+
+func loadIndexDeclarativeConfig(ctx context.Context, img *remote.Image) (*declcfg.DeclarativeConfig, error) {
+	configsDir, dbPath := indexPaths(img.Labels)
+	if s, err := fs.Stat(img.FS, configsDir); err == nil && s.IsDir() {
+		configsFS, err := fs.Sub(img.FS, configsDir)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := declcfg.LoadFS(ctx, configsFS)
+		if err != nil {
+			return nil, fmt.Errorf("load file-based catalog: %v", err)
+		}
+		return cfg, nil
+	}
+
+	dbData, err := fs.ReadFile(img.FS, dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("index image has neither a file-based catalog at /%s nor a database at /%s", configsDir, dbPath)
+	}
+	return loadSQLiteDeclarativeConfig(ctx, dbData)
+}
+
+// catalogFromDeclarativeConfig converts cfg to a catalog, loading each
+// bundle's content with loadBundle. If skipUnloadable is set, bundles that
+// cannot be loaded are reported to warn and left out of their channels,
+// along with their upgrade edges. The replaces and skips of every channel
+// become the package's upgrade edges and skips, and each channel's
+// differences from them become its upgrade edge overrides. A skipRange
+// becomes the package's skip range for its bundle only if every channel
+// entry of the bundle has the same skipRange. Otherwise the edges that each
+// channel's skipRange matches are kept as that channel's overrides.
+func catalogFromDeclarativeConfig(ctx context.Context, cfg *declcfg.DeclarativeConfig, loadBundle func(ctx context.Context, image string) (*Bundle, error), skipUnloadable bool, warn func(msg string)) (*Catalog, error) {
+	bundles, skipped, err := loadDeclarativeConfigBundles(ctx, cfg.Bundles, loadBundle, skipUnloadable, warn)
+	if err != nil {
+		return nil, err
+	}
+
+	channelsByPackage := map[string][]declcfg.Channel{}
+	for _, ch := range cfg.Channels {
+		channelsByPackage[ch.Package] = append(channelsByPackage[ch.Package], ch)
+	}
+
+	var c Catalog
+	if c.Metadata, c.Icon, err = fbcCatalogMetadataFor(cfg.Others); err != nil {
+		return nil, err
+	}
+	if c.Icon != nil {
+		c.Icon = fbcIcon(c.Icon.ImageData, "catalog", warn)
+	}
+	for _, fbcPkg := range cfg.Packages {
+		p := Package{
+			Metadata: PackageMetadata{
+				Name:           fbcPkg.Name,
+				DefaultChannel: fbcPkg.DefaultChannel,
+			},
+			Description:  Description(fbcPkg.Description),
+			Properties:   typeValuesFromProperties(fbcPkg.Properties),
+			UpgradeEdges: UpgradeEdges{},
+			UpgradeSkips: UpgradeSkips{},
+		}
+		if fbcPkg.Icon != nil {
+			p.Icon = fbcIcon(fbcPkg.Icon.Data, fmt.Sprintf("package %q", fbcPkg.Name), warn)
+		}
+
+		deprecations, err := fbcDeprecationsFor(cfg.Others, fbcPkg.Name)
+		if err != nil {
+			return nil, err
+		}
+		p.Metadata.Deprecation = deprecations[fbcDeprecationReference{Schema: declcfg.SchemaPackage}]
+
+		pkgBundles := bundles[fbcPkg.Name]
+		for name, b := range pkgBundles {
+			b.Metadata.Deprecation = deprecations[fbcDeprecationReference{Schema: declcfg.SchemaBundle, Name: name}]
+		}
+		versionRelease := func(name string) (VersionRelease, bool) {
+			b, ok := pkgBundles[name]
+			if !ok {
+				return VersionRelease{}, false
+			}
+			return b.Metadata.VersionRelease(), true
+		}
+
+		channels := channelsByPackage[fbcPkg.Name]
+		sort.Slice(channels, func(i, j int) bool { return channels[i].Name < channels[j].Name })
+		skipRanges := map[VersionRelease]string{}
+		conflictingSkipRanges := sets.New[VersionRelease]()
+		channelEdges := make([]UpgradeEdges, 0, len(channels))
+		for _, fbcCh := range channels {
+			ch := Channel{
+				Metadata: ChannelMetadata{
+					Name:        fbcCh.Name,
+					Deprecation: deprecations[fbcDeprecationReference{Schema: declcfg.SchemaChannel, Name: fbcCh.Name}],
+				},
+				Properties: typeValuesFromProperties(fbcCh.Properties),
+			}
+			for _, entry := range fbcCh.Entries {
+				b, ok := pkgBundles[entry.Name]
+				if !ok && skipped[fbcPkg.Name].Has(entry.Name) {
+					continue
+				}
+				if !ok {
+					return nil, fmt.Errorf("channel %q of package %q references unknown bundle %q", fbcCh.Name, fbcPkg.Name, entry.Name)
+				}
+				ch.Bundles = append(ch.Bundles, *b)
+
+				to, _ := versionRelease(entry.Name)
+				if from, ok := versionRelease(entry.Replaces); ok {
+					p.UpgradeEdges[from] = appendUnique(p.UpgradeEdges[from], to)
+				}
+				if len(entry.Skips) > 0 {
+					s := p.UpgradeSkips[to]
+					for _, from := range entry.Skips {
+						if vr, ok := versionRelease(from); ok {
+							s.Versions = appendUnique(s.Versions, vr)
+						} else {
+							s.Names = appendUnique(s.Names, from)
+						}
+					}
+					p.UpgradeSkips[to] = s
+				}
+				if r, ok := skipRanges[to]; ok && r != entry.SkipRange {
+					conflictingSkipRanges.Insert(to)
+				}
+				skipRanges[to] = entry.SkipRange
+			}
+			edges, err := declarativeConfigChannelEdges(fbcCh, pkgBundles)
+			if err != nil {
+				return nil, fmt.Errorf("channel %q of package %q: %v", fbcCh.Name, fbcPkg.Name, err)
+			}
+			channelEdges = append(channelEdges, edges)
+			sort.Slice(ch.Bundles, func(i, j int) bool {
+				return bundleLess(ch.Bundles[i], ch.Bundles[j])
+			})
+			p.Channels = append(p.Channels, ch)
+		}
+		for to, r := range skipRanges {
+			if r == "" || conflictingSkipRanges.Has(to) {
+				continue
+			}
+			s := p.UpgradeSkips[to]
+			s.Range = r
+			p.UpgradeSkips[to] = s
+		}
+		for from := range p.UpgradeEdges {
+			sortDescending(p.UpgradeEdges[from])
+		}
+		for to, s := range p.UpgradeSkips {
+			sortDescending(s.Versions)
+			sort.Strings(s.Names)
+			p.UpgradeSkips[to] = s
+		}
+		for i, ch := range p.Channels {
+			packageEdges := p.ChannelUpgradeEdges(ch)
+			p.Channels[i].UpgradeEdgeOverrides = ChannelUpgradeEdges{
+				Add:    subtractUpgradeEdges(channelEdges[i], packageEdges),
+				Remove: subtractUpgradeEdges(packageEdges, channelEdges[i]),
+			}
+		}
+		c.Packages = append(c.Packages, p)
+	}
+	return &c, nil
+}
+
+// declarativeConfigChannelEdges returns every upgrade edge between the
+// bundles of ch: its replaces, skips and skip ranges.
+func declarativeConfigChannelEdges(ch declcfg.Channel, bundles map[string]*Bundle) (UpgradeEdges, error) {
+	inChannel := map[string]*Bundle{}
+	for _, entry := range ch.Entries {
+		if b, ok := bundles[entry.Name]; ok {
+			inChannel[entry.Name] = b
+		}
+	}
+	edges := UpgradeEdges{}
+	for _, entry := range ch.Entries {
+		b, ok := inChannel[entry.Name]
+		if !ok {
+			continue
+		}
+		to := b.Metadata.VersionRelease()
+		add := func(fromName string) {
+			if from, ok := inChannel[fromName]; ok && from.Metadata.VersionRelease() != to {
+				vr := from.Metadata.VersionRelease()
+				edges[vr] = appendUnique(edges[vr], to)
+			}
+		}
+		add(entry.Replaces)
+		for _, skip := range entry.Skips {
+			add(skip)
+		}
+		if entry.SkipRange == "" {
+			continue
+		}
+		inRange, err := semver.ParseRange(entry.SkipRange)
+		if err != nil {
+			return nil, fmt.Errorf("invalid skipRange %q of bundle %q: %v", entry.SkipRange, entry.Name, err)
+		}
+		for name, from := range inChannel {
+			if inRange(from.Metadata.Version) {
+				add(name)
+			}
+		}
+	}
+	for from := range edges {
+		sortDescending(edges[from])
+	}
+	return edges, nil
+}
+
+// loadDeclarativeConfigBundles loads every bundle in fbcBundles, returning
+// them by package and bundle name. The properties and constraints declared
+// in the catalog are merged with those of the loaded bundle. It is an error
+// if a bundle cannot be loaded, unless skipUnloadable is set, in which case
+// such bundles are reported to warn and returned as skipped instead, and it
+// is only an error if no bundle can be loaded.
+func loadDeclarativeConfigBundles(ctx context.Context, fbcBundles []declcfg.Bundle, loadBundle func(ctx context.Context, image string) (*Bundle, error), skipUnloadable bool, warn func(msg string)) (map[string]map[string]*Bundle, map[string]sets.Set[string], error) {
+	loaded := make([]*Bundle, len(fbcBundles))
+	errs := make([]error, len(fbcBundles))
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(runtime.NumCPU())
+	for i, fbcBundle := range fbcBundles {
+		i, fbcBundle := i, fbcBundle
+		eg.Go(func() error {
+			b, err := loadBundle(egCtx, fbcBundle.Image)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				errs[i] = fmt.Errorf("import bundle %q from %s: %v", fbcBundle.Name, fbcBundle.Image, err)
+				return nil
+			}
+			if err := mergeDeclarativeConfigBundle(b, fbcBundle); err != nil {
+				errs[i] = fmt.Errorf("import bundle %q: %v", fbcBundle.Name, err)
+				return nil
+			}
+			loaded[i] = b
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, nil, err
+	}
+
+	out := map[string]map[string]*Bundle{}
+	skipped := map[string]sets.Set[string]{}
+	var skippedNames []string
+	for i, fbcBundle := range fbcBundles {
+		if errs[i] != nil {
+			if !skipUnloadable {
+				return nil, nil, errs[i]
+			}
+			warn(fmt.Sprintf("skipping bundle: %v", errs[i]))
+			if skipped[fbcBundle.Package] == nil {
+				skipped[fbcBundle.Package] = sets.New[string]()
+			}
+			skipped[fbcBundle.Package].Insert(fbcBundle.Name)
+			skippedNames = append(skippedNames, fbcBundle.Name)
+			continue
+		}
+		if out[fbcBundle.Package] == nil {
+			out[fbcBundle.Package] = map[string]*Bundle{}
+		}
+		out[fbcBundle.Package][fbcBundle.Name] = loaded[i]
+	}
+	if len(skippedNames) > 0 {
+		if len(skippedNames) == len(fbcBundles) {
+			return nil, nil, fmt.Errorf("none of the %d bundles could be imported", len(fbcBundles))
+		}
+		warn(fmt.Sprintf("skipped %d of %d bundles that could not be imported: %s", len(skippedNames), len(fbcBundles), strings.Join(skippedNames, ", ")))
+	}
+	return out, skipped, nil
+}
+
+func mergeDeclarativeConfigBundle(b *Bundle, fbcBundle declcfg.Bundle) error {
+	props, err := property.Parse(fbcBundle.Properties)
+	if err != nil {
+		return fmt.Errorf("parse properties: %v", err)
+	}
+	if len(props.Packages) == 1 && b.Metadata.Package == "" {
+		b.Metadata.Package = props.Packages[0].PackageName
+		if b.Metadata.Version, err = semver.Parse(props.Packages[0].Version); err != nil {
+			return fmt.Errorf("invalid bundle version %q: %v", props.Packages[0].Version, err)
+		}
+	}
+
+	var declaredProps, declaredConstraints Properties
+	for _, p := range fbcBundle.Properties {
+		switch p.Type {
+		case property.TypePackage, property.TypeBundleObject, property.TypeCSVMetadata, property.TypeChannel, "olm.bundle.mediatype":
+		case property.TypePackageRequired, property.TypeGVKRequired:
+			declaredConstraints = append(declaredConstraints, TypeValue{Type: p.Type, Value: p.Value})
+		default:
+			declaredProps = append(declaredProps, TypeValue{Type: p.Type, Value: p.Value})
+		}
+	}
+	b.Properties = mergeProperties(b.Properties, declaredProps)
+	b.Constraints = Constraints(mergeProperties(Properties(b.Constraints), declaredConstraints))
+	return nil
+}
+
+func typeValuesFromProperties(in []property.Property) Properties {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(Properties, len(in))
+	for i, p := range in {
+		out[i] = TypeValue{Type: p.Type, Value: p.Value}
+	}
+	return out
+}
+
+func appendUnique[T comparable](s []T, v T) []T {
+	for _, existing := range s {
+		if existing == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
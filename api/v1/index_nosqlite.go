@@ -0,0 +1,16 @@
+//go:build !sqlite
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// loadSQLiteDeclarativeConfig reports that SQLite indexes are not supported.
+// Reading them requires cgo, so it is only built with the sqlite build tag.
+func loadSQLiteDeclarativeConfig(_ context.Context, _ []byte) (*declcfg.DeclarativeConfig, error) {
+	return nil, fmt.Errorf("SQLite index images are not supported by this build of olmoci; rebuild it with -tags sqlite")
+}
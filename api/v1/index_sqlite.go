@@ -0,0 +1,37 @@
+//go:build sqlite
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/pkg/sqlite"
+)
+
+func loadSQLiteDeclarativeConfig(ctx context.Context, dbData []byte) (*declcfg.DeclarativeConfig, error) {
+	tmpDir, err := os.MkdirTemp("", "olmoci-index-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	dbFile := filepath.Join(tmpDir, "index.db")
+	if err := os.WriteFile(dbFile, dbData, 0600); err != nil {
+		return nil, err
+	}
+
+	db, err := sqlite.Open(dbFile)
+	if err != nil {
+		return nil, fmt.Errorf("open index database: %v", err)
+	}
+	defer db.Close()
+	m, err := sqlite.ToModel(ctx, sqlite.NewSQLLiteQuerierFromDb(db))
+	if err != nil {
+		return nil, fmt.Errorf("read index database: %v", err)
+	}
+	cfg := declcfg.ConvertFromModel(m)
+	return &cfg, nil
+}
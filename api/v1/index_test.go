@@ -0,0 +1,446 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+func testBundle(pkg, vr string) Bundle {
+	v, err := ParseVersionRelease(vr)
+	if err != nil {
+		panic(err)
+	}
+	return Bundle{
+		Metadata: BundleMetadata{Package: pkg, Version: v.Version(), Release: v.Release()},
+		Digest:   digest.FromString(pkg + vr),
+	}
+}
+
+func testBundles(pkg string, vrs ...string) []Bundle {
+	bundles := make([]Bundle, 0, len(vrs))
+	for _, vr := range vrs {
+		bundles = append(bundles, testBundle(pkg, vr))
+	}
+	return bundles
+}
+
+func vrs(ss ...string) []VersionRelease {
+	out := make([]VersionRelease, 0, len(ss))
+	for _, s := range ss {
+		out = append(out, mustVR(s))
+	}
+	return out
+}
+
+func mustVR(s string) VersionRelease {
+	vr, err := ParseVersionRelease(s)
+	if err != nil {
+		panic(err)
+	}
+	return vr
+}
+
+// importFBC converts cfg back to a catalog, resolving bundle images to the
+// bundles of p.
+func importFBC(t *testing.T, p Package, cfg *declcfg.DeclarativeConfig) *Catalog {
+	t.Helper()
+	byImage := map[string]Bundle{}
+	for _, ch := range p.Channels {
+		for _, b := range ch.Bundles {
+			byImage[fmt.Sprintf("oci://example.com/repo@%s", b.Digest)] = b
+		}
+	}
+	c, err := catalogFromDeclarativeConfig(context.Background(), cfg, func(_ context.Context, image string) (*Bundle, error) {
+		b, ok := byImage[image]
+		if !ok {
+			return nil, fmt.Errorf("unknown image %q", image)
+		}
+		return &b, nil
+	}, false, func(msg string) { t.Errorf("unexpected warning: %s", msg) })
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	return c
+}
+
+func TestChannelUpgradeEdgesRoundTripFBC(t *testing.T) {
+	bundles := testBundles("foo", "1.0.0-0", "1.1.0-0", "1.2.0-0")
+	p := Package{
+		Metadata: PackageMetadata{Name: "foo"},
+		UpgradeEdges: UpgradeEdges{
+			mustVR("1.0.0-0"): vrs("1.1.0-0"),
+			mustVR("1.1.0-0"): vrs("1.2.0-0"),
+		},
+		Channels: []Channel{
+			{
+				Metadata: ChannelMetadata{Name: "a"},
+				Bundles:  bundles,
+				UpgradeEdgeOverrides: ChannelUpgradeEdges{
+					Add: UpgradeEdges{mustVR("1.0.0-0"): vrs("1.2.0-0")},
+				},
+			},
+			{
+				Metadata: ChannelMetadata{Name: "b"},
+				Bundles:  bundles,
+				UpgradeEdgeOverrides: ChannelUpgradeEdges{
+					Remove: UpgradeEdges{mustVR("1.1.0-0"): vrs("1.2.0-0")},
+				},
+			},
+		},
+	}
+	cfg, err := p.ToFBC(context.Background(), "example.com/repo")
+	if err != nil {
+		t.Fatalf("ToFBC: %v", err)
+	}
+	c := importFBC(t, p, cfg)
+	if len(c.Packages) != 1 {
+		t.Fatalf("expected 1 package, got %d", len(c.Packages))
+	}
+	imported := c.Packages[0]
+	for i, ch := range p.Channels {
+		want := p.ChannelUpgradeEdges(ch)
+		got := imported.ChannelUpgradeEdges(imported.Channels[i])
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("channel %q: expected edges %v, got %v", ch.Metadata.Name, want, got)
+		}
+	}
+}
+
+func TestCatalogFromDeclarativeConfigSkipRanges(t *testing.T) {
+	bundles := testBundles("foo", "1.0.0-0", "1.1.0-0", "1.2.0-0")
+	entries := func(skipRange string) []declcfg.ChannelEntry {
+		return []declcfg.ChannelEntry{
+			{Name: "foo.v1.0.0-0"},
+			{Name: "foo.v1.1.0-0", Replaces: "foo.v1.0.0-0"},
+			{Name: "foo.v1.2.0-0", Replaces: "foo.v1.1.0-0", SkipRange: skipRange},
+		}
+	}
+	fbcBundles := make([]declcfg.Bundle, 0, len(bundles))
+	for _, b := range bundles {
+		fbcBundles = append(fbcBundles, declcfg.Bundle{
+			Schema:  declcfg.SchemaBundle,
+			Package: "foo",
+			Name:    "foo.v" + b.Metadata.VersionRelease().String(),
+			Image:   fmt.Sprintf("oci://example.com/repo@%s", b.Digest),
+		})
+	}
+	p := Package{Channels: []Channel{{Bundles: bundles}}}
+
+	for _, tc := range []struct {
+		name       string
+		rangeA     string
+		rangeB     string
+		wantRange  string
+		wantAddedA UpgradeEdges
+	}{
+		{
+			name:      "same range in every channel",
+			rangeA:    ">=1.0.0 <1.2.0",
+			rangeB:    ">=1.0.0 <1.2.0",
+			wantRange: ">=1.0.0 <1.2.0",
+		},
+		{
+			name:       "range in one channel",
+			rangeA:     ">=1.0.0 <1.2.0",
+			wantAddedA: UpgradeEdges{mustVR("1.0.0-0"): vrs("1.2.0-0")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &declcfg.DeclarativeConfig{
+				Packages: []declcfg.Package{{Schema: declcfg.SchemaPackage, Name: "foo"}},
+				Channels: []declcfg.Channel{
+					{Schema: declcfg.SchemaChannel, Package: "foo", Name: "a", Entries: entries(tc.rangeA)},
+					{Schema: declcfg.SchemaChannel, Package: "foo", Name: "b", Entries: entries(tc.rangeB)},
+				},
+				Bundles: fbcBundles,
+			}
+			imported := importFBC(t, p, cfg).Packages[0]
+			if got := imported.UpgradeSkips[mustVR("1.2.0-0")].Range; got != tc.wantRange {
+				t.Errorf("expected package skip range %q, got %q", tc.wantRange, got)
+			}
+			if got := imported.Channels[0].UpgradeEdgeOverrides.Add; !reflect.DeepEqual(got, tc.wantAddedA) {
+				t.Errorf("expected channel a to add %v, got %v", tc.wantAddedA, got)
+			}
+			if got := imported.Channels[1].UpgradeEdgeOverrides; !got.isZero() {
+				t.Errorf("expected no overrides in channel b, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestDeclarativeConfigChannelEdgesInvalidSkipRange(t *testing.T) {
+	b := testBundle("foo", "1.0.0-0")
+	_, err := declarativeConfigChannelEdges(declcfg.Channel{
+		Entries: []declcfg.ChannelEntry{{Name: "foo.v1.0.0-0", SkipRange: "not a range"}},
+	}, map[string]*Bundle{"foo.v1.0.0-0": &b})
+	if err == nil || !strings.Contains(err.Error(), "invalid skipRange") {
+		t.Fatalf("expected invalid skipRange error, got %v", err)
+	}
+}
+
+func TestCatalogFromDeclarativeConfigOrdersChannelBundlesByRelease(t *testing.T) {
+	bundles := testBundles("foo", "1.0.0-1", "1.0.0-0")
+	cfg := &declcfg.DeclarativeConfig{
+		Packages: []declcfg.Package{{Schema: declcfg.SchemaPackage, Name: "foo"}},
+		Channels: []declcfg.Channel{{
+			Schema:  declcfg.SchemaChannel,
+			Package: "foo",
+			Name:    "a",
+			Entries: []declcfg.ChannelEntry{
+				{Name: "foo.v1.0.0-1", Replaces: "foo.v1.0.0-0"},
+				{Name: "foo.v1.0.0-0"},
+			},
+		}},
+	}
+	for _, b := range bundles {
+		cfg.Bundles = append(cfg.Bundles, declcfg.Bundle{
+			Schema:  declcfg.SchemaBundle,
+			Package: "foo",
+			Name:    "foo.v" + b.Metadata.VersionRelease().String(),
+			Image:   fmt.Sprintf("oci://example.com/repo@%s", b.Digest),
+		})
+	}
+	p := Package{Channels: []Channel{{Bundles: bundles}}}
+	ch := importFBC(t, p, cfg).Packages[0].Channels[0]
+	var got []VersionRelease
+	for _, b := range ch.Bundles {
+		got = append(got, b.Metadata.VersionRelease())
+	}
+	if want := vrs("1.0.0-0", "1.0.0-1"); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected bundles %v, got %v", want, got)
+	}
+}
+
+func TestCatalogFromDeclarativeConfigUnloadableBundles(t *testing.T) {
+	cfg := &declcfg.DeclarativeConfig{
+		Packages: []declcfg.Package{{Schema: declcfg.SchemaPackage, Name: "foo"}},
+		Channels: []declcfg.Channel{{
+			Schema:  declcfg.SchemaChannel,
+			Package: "foo",
+			Name:    "a",
+			Entries: []declcfg.ChannelEntry{
+				{Name: "foo.v1.0.0-0"},
+				{Name: "foo.v1.1.0-0", Replaces: "foo.v1.0.0-0"},
+				{Name: "foo.v1.2.0-0", Replaces: "foo.v1.1.0-0", Skips: []string{"foo.v1.0.0-0"}},
+			},
+		}},
+	}
+	for _, vr := range []string{"1.0.0-0", "1.1.0-0", "1.2.0-0"} {
+		cfg.Bundles = append(cfg.Bundles, declcfg.Bundle{
+			Schema:  declcfg.SchemaBundle,
+			Package: "foo",
+			Name:    "foo.v" + vr,
+			Image:   "example.com/foo:v" + vr,
+		})
+	}
+
+	for _, tc := range []struct {
+		name           string
+		unloadable     []string
+		skipUnloadable bool
+		wantBundles    []VersionRelease
+		wantWarnings   int
+		wantErr        string
+	}{
+		{
+			name:        "all bundles load",
+			wantBundles: vrs("1.0.0-0", "1.1.0-0", "1.2.0-0"),
+		},
+		{
+			name:       "one bundle fails",
+			unloadable: []string{"example.com/foo:v1.1.0-0"},
+			wantErr:    `import bundle "foo.v1.1.0-0" from example.com/foo:v1.1.0-0: pull failed`,
+		},
+		{
+			name:           "one bundle is skipped",
+			unloadable:     []string{"example.com/foo:v1.1.0-0"},
+			skipUnloadable: true,
+			wantBundles:    vrs("1.0.0-0", "1.2.0-0"),
+			wantWarnings:   2,
+		},
+		{
+			name:           "every bundle is skipped",
+			unloadable:     []string{"example.com/foo:v1.0.0-0", "example.com/foo:v1.1.0-0", "example.com/foo:v1.2.0-0"},
+			skipUnloadable: true,
+			wantErr:        "none of the 3 bundles",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var warnings []string
+			c, err := catalogFromDeclarativeConfig(context.Background(), cfg, func(_ context.Context, image string) (*Bundle, error) {
+				for _, u := range tc.unloadable {
+					if image == u {
+						return nil, fmt.Errorf("pull failed")
+					}
+				}
+				b := testBundle("foo", strings.TrimPrefix(image, "example.com/foo:v"))
+				return &b, nil
+			}, tc.skipUnloadable, func(msg string) { warnings = append(warnings, msg) })
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(warnings) != tc.wantWarnings {
+				t.Errorf("expected %d warnings, got %q", tc.wantWarnings, warnings)
+			}
+			var got []VersionRelease
+			for _, b := range c.Packages[0].Channels[0].Bundles {
+				got = append(got, b.Metadata.VersionRelease())
+			}
+			if !reflect.DeepEqual(got, tc.wantBundles) {
+				t.Errorf("expected bundles %v, got %v", tc.wantBundles, got)
+			}
+		})
+	}
+}
+
+func TestLoadIndexDeclarativeConfig(t *testing.T) {
+	const pkg = `{"schema":"olm.package","name":"foo"}`
+	for _, tc := range []struct {
+		name     string
+		labels   map[string]string
+		fsys     fstest.MapFS
+		wantPkgs []string
+		wantErr  string
+	}{
+		{
+			name:     "default configs directory",
+			fsys:     fstest.MapFS{"configs/foo/catalog.json": {Data: []byte(pkg)}},
+			wantPkgs: []string{"foo"},
+		},
+		{
+			name:     "configs directory from label",
+			labels:   map[string]string{labelIndexConfigs: "/catalog"},
+			fsys:     fstest.MapFS{"catalog/foo/catalog.json": {Data: []byte(pkg)}},
+			wantPkgs: []string{"foo"},
+		},
+		{
+			name:    "no catalog",
+			labels:  map[string]string{labelIndexConfigs: "/catalog", labelIndexDatabase: "/db/index.db"},
+			fsys:    fstest.MapFS{"configs/foo/catalog.json": {Data: []byte(pkg)}},
+			wantErr: "index image has neither a file-based catalog at /catalog nor a database at /db/index.db",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := loadIndexDeclarativeConfig(context.Background(), &remote.Image{Labels: tc.labels, FS: tc.fsys})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range cfg.Packages {
+				got = append(got, p.Name)
+			}
+			if !reflect.DeepEqual(got, tc.wantPkgs) {
+				t.Errorf("expected packages %v, got %v", tc.wantPkgs, got)
+			}
+		})
+	}
+}
+
+func TestCatalogFromDeclarativeConfigIcons(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		icon         *declcfg.Icon
+		want         *Icon
+		wantWarnings []string
+	}{
+		{
+			name: "svg icon is sanitized",
+			icon: &declcfg.Icon{Data: []byte(`<svg onload="alert(1)"><script>alert(2)</script><rect width="1"/></svg>`), MediaType: MediaTypeIconSVG},
+			want: &Icon{ImageData: []byte(`<svg><rect width="1"></rect></svg>`), ImageMediaType: MediaTypeIconSVG},
+		},
+		{
+			name: "media type is detected",
+			icon: &declcfg.Icon{Data: []byte(`<svg width="1" height="1"></svg>`), MediaType: MediaTypeIconPNG},
+			want: &Icon{ImageData: []byte(`<svg width="1" height="1"></svg>`), ImageMediaType: MediaTypeIconSVG},
+		},
+		{
+			name:         "invalid icon is dropped",
+			icon:         &declcfg.Icon{Data: []byte("not an icon"), MediaType: MediaTypeIconPNG},
+			wantWarnings: []string{`dropping icon of package "foo": unsupported icon format "text/plain; charset=utf-8"`},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &declcfg.DeclarativeConfig{
+				Packages: []declcfg.Package{{Schema: declcfg.SchemaPackage, Name: "foo", Icon: tc.icon}},
+			}
+			var warnings []string
+			c, err := catalogFromDeclarativeConfig(context.Background(), cfg, nil, false, func(msg string) { warnings = append(warnings, msg) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Packages[0].Icon; !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected icon %+v, got %+v", tc.want, got)
+			}
+			if !reflect.DeepEqual(warnings, tc.wantWarnings) {
+				t.Errorf("expected warnings %q, got %q", tc.wantWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestUpgradeSkipsRoundTripFBC(t *testing.T) {
+	stable := testBundles("foo", "1.0.0-0", "1.2.0-0")
+	fast := testBundles("foo", "1.1.0-0", "1.2.0-0")
+	p := Package{
+		Metadata:     PackageMetadata{Name: "foo"},
+		UpgradeEdges: UpgradeEdges{},
+		UpgradeSkips: UpgradeSkips{
+			// 1.0.0-0 is only in stable, and foo.v0.9.0 is not a bundle of
+			// the package.
+			mustVR("1.2.0-0"): {Versions: vrs("1.1.0-0", "1.0.0-0"), Names: []string{"foo.v0.9.0"}},
+		},
+		Channels: []Channel{
+			{Metadata: ChannelMetadata{Name: "fast"}, Bundles: fast},
+			{Metadata: ChannelMetadata{Name: "stable"}, Bundles: stable},
+		},
+	}
+	cfg, err := p.ToFBC(context.Background(), "example.com/repo")
+	if err != nil {
+		t.Fatalf("ToFBC: %v", err)
+	}
+	wantSkips := map[string][]string{
+		"fast":   {"foo.v0.9.0", "foo.v1.0.0-0", "foo.v1.1.0-0"},
+		"stable": {"foo.v0.9.0", "foo.v1.0.0-0", "foo.v1.1.0-0"},
+	}
+	for _, ch := range cfg.Channels {
+		for _, e := range ch.Entries {
+			if e.Name != "foo.v1.2.0-0" {
+				continue
+			}
+			if !reflect.DeepEqual(e.Skips, wantSkips[ch.Name]) {
+				t.Errorf("channel %q: expected skips %v, got %v", ch.Name, wantSkips[ch.Name], e.Skips)
+			}
+		}
+	}
+
+	c := importFBC(t, p, cfg)
+	if got := c.Packages[0].UpgradeSkips; !reflect.DeepEqual(got, p.UpgradeSkips) {
+		t.Errorf("expected upgrade skips %v, got %v", p.UpgradeSkips, got)
+	}
+	for i, ch := range c.Packages[0].Channels {
+		if want := p.ChannelUpgradeEdges(p.Channels[i]); !reflect.DeepEqual(c.Packages[0].ChannelUpgradeEdges(ch), want) {
+			t.Errorf("channel %q: expected edges %v, got %v", ch.Metadata.Name, want, c.Packages[0].ChannelUpgradeEdges(ch))
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"sigs.k8s.io/yaml"
+
+	"github.com/joelanford/olm-oci/pkg/client"
+)
+
+const MediaTypePackageIndex = "application/vnd.cncf.operatorframework.olm.catalog.package-index.v1+yaml"
+
+var _ client.IndexedArtifact = &Catalog{}
+
+// PackageIndex summarizes the packages of a catalog, keyed by package name,
+// so that a single package can be found without fetching every package
+// manifest in the catalog.
+type PackageIndex map[string]PackageIndexEntry
+
+// PackageIndexEntry locates a package's manifest and summarizes its
+// contents.
+type PackageIndexEntry struct {
+	Digest        digest.Digest             `json:"digest"`
+	Size          int64                     `json:"size"`
+	ChannelHeads  map[string]VersionRelease `json:"channelHeads,omitempty"`
+	LatestVersion *VersionRelease           `json:"latestVersion,omitempty"`
+	ProvidedGVKs  []property.GVK            `json:"providedGVKs,omitempty"`
+}
+
+func (pi PackageIndex) MediaType() string {
+	return MediaTypePackageIndex
+}
+
+func (pi PackageIndex) Annotations() map[string]string {
+	return blobAnnotations("index.yaml", BlobRolePackageIndex)
+}
+
+func (pi PackageIndex) Data() (io.ReadCloser, error) {
+	data, err := yaml.Marshal(pi)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Descriptor returns the descriptor of the package manifest.
+func (e PackageIndexEntry) Descriptor() ocispec.Descriptor {
+	return ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeArtifactManifest,
+		Digest:    e.Digest,
+		Size:      e.Size,
+	}
+}
+
+func (c *Catalog) IndexBlobs(subArtifacts []ocispec.Descriptor) ([]client.Blob, error) {
+	index := PackageIndex{}
+	for i, p := range c.Packages {
+		entry, err := p.indexEntry(subArtifacts[i])
+		if err != nil {
+			return nil, fmt.Errorf("index package %q: %v", p.Metadata.Name, err)
+		}
+		index[p.Metadata.Name] = *entry
+	}
+	return []client.Blob{index}, nil
+}
+
+func (p Package) indexEntry(desc ocispec.Descriptor) (*PackageIndexEntry, error) {
+	entry := PackageIndexEntry{
+		Digest:        desc.Digest,
+		Size:          desc.Size,
+		LatestVersion: p.latestVersion(),
+	}
+
+	gvks := map[property.GVK]struct{}{}
+	for _, ch := range p.Channels {
+		if head := p.channelHead(ch); head != nil {
+			if entry.ChannelHeads == nil {
+				entry.ChannelHeads = map[string]VersionRelease{}
+			}
+			entry.ChannelHeads[ch.Metadata.Name] = head.Metadata.VersionRelease()
+		}
+		for _, b := range ch.Bundles {
+			for _, tv := range b.Properties {
+				if tv.Type != property.TypeGVK {
+					continue
+				}
+				var gvk property.GVK
+				if err := json.Unmarshal(tv.Value, &gvk); err != nil {
+					return nil, fmt.Errorf("parse %s property of bundle %s: %v", property.TypeGVK, b.Metadata.VersionRelease(), err)
+				}
+				gvks[gvk] = struct{}{}
+			}
+		}
+	}
+	for gvk := range gvks {
+		entry.ProvidedGVKs = append(entry.ProvidedGVKs, gvk)
+	}
+	sort.Slice(entry.ProvidedGVKs, func(i, j int) bool {
+		a, b := entry.ProvidedGVKs[i], entry.ProvidedGVKs[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Version < b.Version
+	})
+	return &entry, nil
+}
+
+// latestVersion returns the highest version of the bundles in p's channels,
+// or nil if p has no bundles.
+func (p Package) latestVersion() *VersionRelease {
+	var latest *VersionRelease
+	for _, ch := range p.Channels {
+		for _, b := range ch.Bundles {
+			if vr := b.Metadata.VersionRelease(); latest == nil || latest.Compare(vr) < 0 {
+				latest = &vr
+			}
+		}
+	}
+	return latest
+}
+
+// channelHead returns the bundle in ch that cannot be upgraded to another
+// bundle in ch. If several bundles qualify, the one with the highest version
+// wins.
+func (p Package) channelHead(ch Channel) *Bundle {
+	edges := p.ChannelUpgradeEdges(ch)
+	var head *Bundle
+	for i, b := range ch.Bundles {
+		if len(edges[b.Metadata.VersionRelease()]) > 0 {
+			continue
+		}
+		if head == nil || bundleLess(*head, b) {
+			head = &ch.Bundles[i]
+		}
+	}
+	return head
+}
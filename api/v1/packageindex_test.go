@@ -0,0 +1,199 @@
+package v1
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"sigs.k8s.io/yaml"
+)
+
+func gvkBundle(pkg, vr string, gvks ...property.GVK) Bundle {
+	b := testBundle(pkg, vr)
+	for _, gvk := range gvks {
+		p := property.MustBuildGVK(gvk.Group, gvk.Version, gvk.Kind)
+		b.Properties = append(b.Properties, TypeValue{Type: p.Type, Value: p.Value})
+	}
+	return b
+}
+
+func TestPackageIndexEntry(t *testing.T) {
+	fooV1 := property.GVK{Group: "example.com", Version: "v1", Kind: "Foo"}
+	fooV2 := property.GVK{Group: "example.com", Version: "v2", Kind: "Foo"}
+	bar := property.GVK{Group: "example.com", Version: "v1", Kind: "Bar"}
+	baz := property.GVK{Group: "apps.example.com", Version: "v1", Kind: "Baz"}
+	desc := ocispec.Descriptor{Digest: digest.FromString("foo"), Size: 42}
+	latest := mustVR("0.3.0-1")
+
+	for _, tc := range []struct {
+		name    string
+		pkg     Package
+		want    PackageIndexEntry
+		wantErr string
+	}{
+		{
+			name: "no channels",
+			pkg:  Package{Metadata: PackageMetadata{Name: "foo"}},
+			want: PackageIndexEntry{Digest: desc.Digest, Size: desc.Size},
+		},
+		{
+			name: "heads, latest version and provided GVKs",
+			pkg: Package{
+				Metadata: PackageMetadata{Name: "foo"},
+				UpgradeEdges: UpgradeEdges{
+					mustVR("0.1.0-0"): vrs("0.2.0-0"),
+					mustVR("0.2.0-0"): vrs("0.3.0-1"),
+				},
+				Channels: []Channel{
+					{
+						Metadata: ChannelMetadata{Name: "stable"},
+						Bundles: []Bundle{
+							gvkBundle("foo", "0.1.0-0", fooV1),
+							gvkBundle("foo", "0.2.0-0", fooV1, bar),
+						},
+					},
+					{
+						Metadata: ChannelMetadata{Name: "fast"},
+						Bundles: []Bundle{
+							gvkBundle("foo", "0.2.0-0", fooV1, bar),
+							gvkBundle("foo", "0.3.0-1", fooV2, bar, baz),
+						},
+					},
+				},
+			},
+			want: PackageIndexEntry{
+				Digest:        desc.Digest,
+				Size:          desc.Size,
+				ChannelHeads:  map[string]VersionRelease{"stable": mustVR("0.2.0-0"), "fast": mustVR("0.3.0-1")},
+				LatestVersion: &latest,
+				ProvidedGVKs:  []property.GVK{baz, bar, fooV1, fooV2},
+			},
+		},
+		{
+			name: "highest of several heads",
+			pkg: Package{
+				Metadata: PackageMetadata{Name: "foo"},
+				Channels: []Channel{{
+					Metadata: ChannelMetadata{Name: "stable"},
+					Bundles:  testBundles("foo", "0.3.0-1", "0.1.0-0", "0.2.0-0"),
+				}},
+			},
+			want: PackageIndexEntry{
+				Digest:        desc.Digest,
+				Size:          desc.Size,
+				ChannelHeads:  map[string]VersionRelease{"stable": mustVR("0.3.0-1")},
+				LatestVersion: &latest,
+			},
+		},
+		{
+			name: "head determined by upgrade edges",
+			pkg: Package{
+				Metadata: PackageMetadata{Name: "foo"},
+				UpgradeEdges: UpgradeEdges{
+					mustVR("0.3.0-1"): vrs("0.2.0-0"),
+				},
+				Channels: []Channel{{
+					Metadata: ChannelMetadata{Name: "stable"},
+					Bundles:  testBundles("foo", "0.2.0-0", "0.3.0-1"),
+				}},
+			},
+			want: PackageIndexEntry{
+				Digest:        desc.Digest,
+				Size:          desc.Size,
+				ChannelHeads:  map[string]VersionRelease{"stable": mustVR("0.2.0-0")},
+				LatestVersion: &latest,
+			},
+		},
+		{
+			name: "invalid GVK property",
+			pkg: Package{
+				Metadata: PackageMetadata{Name: "foo"},
+				Channels: []Channel{{
+					Metadata: ChannelMetadata{Name: "stable"},
+					Bundles: []Bundle{func() Bundle {
+						b := testBundle("foo", "0.1.0-0")
+						b.Properties = Properties{{Type: property.TypeGVK, Value: []byte(`"example.com/v1/Foo"`)}}
+						return b
+					}()},
+				}},
+			},
+			wantErr: "parse olm.gvk property of bundle 0.1.0-0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.pkg.indexEntry(desc)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("expected %+v, got %+v", tc.want, *got)
+			}
+		})
+	}
+}
+
+func TestCatalogIndexBlobs(t *testing.T) {
+	c := Catalog{Packages: []Package{
+		{
+			Metadata: PackageMetadata{Name: "foo"},
+			Channels: []Channel{{Metadata: ChannelMetadata{Name: "stable"}, Bundles: testBundles("foo", "1.0.0-0")}},
+		},
+		{
+			Metadata: PackageMetadata{Name: "bar"},
+		},
+	}}
+	descs := []ocispec.Descriptor{
+		{Digest: digest.FromString("foo"), Size: 1},
+		{Digest: digest.FromString("bar"), Size: 2},
+	}
+	blobs, err := c.IndexBlobs(descs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 1 {
+		t.Fatalf("expected one index blob, got %d", len(blobs))
+	}
+	if blobs[0].MediaType() != MediaTypePackageIndex {
+		t.Errorf("expected media type %q, got %q", MediaTypePackageIndex, blobs[0].MediaType())
+	}
+	rc, err := blobs[0].Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got PackageIndex
+	if err := yaml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	latest := mustVR("1.0.0-0")
+	want := PackageIndex{
+		"foo": {
+			Digest:        descs[0].Digest,
+			Size:          1,
+			ChannelHeads:  map[string]VersionRelease{"stable": latest},
+			LatestVersion: &latest,
+		},
+		"bar": {Digest: descs[1].Digest, Size: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if desc := got["foo"].Descriptor(); desc.MediaType != ocispec.MediaTypeArtifactManifest || desc.Digest != descs[0].Digest || desc.Size != 1 {
+		t.Errorf("unexpected package descriptor %+v", desc)
+	}
+}
@@ -12,21 +12,20 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 
 	"github.com/blang/semver/v4"
 	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/operator-framework/operator-registry/alpha/declcfg"
 	"github.com/operator-framework/operator-registry/alpha/property"
-	"github.com/operator-framework/operator-registry/pkg/image"
 	"github.com/operator-framework/operator-registry/pkg/registry"
-	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"oras.land/oras-go/v2/content/memory"
 	"sigs.k8s.io/yaml"
 
 	"github.com/joelanford/olm-oci/pkg/client"
+	"github.com/joelanford/olm-oci/pkg/progress"
 	"github.com/joelanford/olm-oci/pkg/tar"
 )
 
@@ -37,10 +36,27 @@ const (
 	AnnotationKeyBundleRelease          = "io.operatorframework.bundle.release"
 	AnnotationKeyBundleContentMediaType = "io.operatorframework.bundle.content.mediatype"
 
-	MediaTypeCatalog = "application/vnd.cncf.operatorframework.olm.catalog.v1"
+	// AnnotationKeyBundleDeprecation, if set in a bundle's
+	// metadata/annotations.yaml, deprecates the bundle with its value as
+	// the message.
+	AnnotationKeyBundleDeprecation = "io.operatorframework.bundle.deprecation"
+
+	// AnnotationKeyBundleCustomAnnotations lists the keys, separated by
+	// commas, of the annotations on a bundle manifest that were passed
+	// through from the bundle's metadata/annotations.yaml.
+	AnnotationKeyBundleCustomAnnotations = "io.operatorframework.bundle.custom-annotations"
+
+	// Legacy registry+v1 channel annotations are kept on bundles as hints
+	// for migrating channel membership to channel artifacts.
+	AnnotationKeyBundleLegacyChannels       = "io.operatorframework.bundle.legacy.channels"
+	AnnotationKeyBundleLegacyDefaultChannel = "io.operatorframework.bundle.legacy.channel.default"
+
+	MediaTypeCatalog         = "application/vnd.cncf.operatorframework.olm.catalog.v1"
+	MediaTypeCatalogMetadata = "application/vnd.cncf.operatorframework.olm.catalog.metadata.v1+yaml"
 
 	MediaTypePackage         = "application/vnd.cncf.operatorframework.olm.package.v1"
 	MediaTypePackageMetadata = "application/vnd.cncf.operatorframework.olm.package.metadata.v1+yaml"
+	MediaTypeDescription     = "text/markdown"
 	MediaTypeUpgradeEdges    = "application/vnd.cncf.operatorframework.olm.upgrade-edges.v1+yaml"
 
 	MediaTypeChannel         = "application/vnd.cncf.operatorframework.olm.channel.v1"
@@ -62,25 +78,107 @@ var (
 	_ client.Artifact = &Channel{}
 	_ client.Artifact = &Bundle{}
 
+	_ client.Blob = &CatalogMetadata{}
 	_ client.Blob = &PackageMetadata{}
 	_ client.Blob = Description("")
 	_ client.Blob = &Icon{}
 	_ client.Blob = UpgradeEdges{}
+	_ client.Blob = UpgradeSkips{}
+	_ client.Blob = ChannelUpgradeEdges{}
 	_ client.Blob = &ChannelMetadata{}
 	_ client.Blob = &Properties{}
 	_ client.Blob = &Constraints{}
+
+	_ client.AnnotatedBlob = &CatalogMetadata{}
+	_ client.AnnotatedBlob = PackageIndex{}
+	_ client.AnnotatedBlob = &PackageMetadata{}
+	_ client.AnnotatedBlob = Description("")
+	_ client.AnnotatedBlob = &Icon{}
+	_ client.AnnotatedBlob = UpgradeEdges{}
+	_ client.AnnotatedBlob = UpgradeSkips{}
+	_ client.AnnotatedBlob = ChannelUpgradeEdges{}
+	_ client.AnnotatedBlob = &ChannelMetadata{}
+	_ client.AnnotatedBlob = &BundleMetadata{}
+	_ client.AnnotatedBlob = RelatedImages{}
+	_ client.AnnotatedBlob = BundleContent{}
+	_ client.AnnotatedBlob = &Properties{}
+	_ client.AnnotatedBlob = &Constraints{}
 )
 
 type Catalog struct {
+	Metadata CatalogMetadata
+	Icon     *Icon
 	Packages []Package
 }
 
+// LoadCatalog loads a catalog from catalogDir, whose catalog.yaml holds the
+// catalog's metadata and whose subdirectories containing a package.yaml are
+// loaded as packages.
+func LoadCatalog(catalogDir string, opts ...LoadBundleOption) (*Catalog, error) {
+	var (
+		c   Catalog
+		err error
+	)
+	metadataFile := filepath.Join(catalogDir, "catalog.yaml")
+	c.Metadata, err = LoadCatalogMetadata(metadataFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading metadata: %w", err)
+	}
+	c.Icon, err = loadIcon(catalogDir, metadataFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading icon: %w", err)
+	}
+
+	entries, err := os.ReadDir(catalogDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		packageDir := filepath.Join(catalogDir, entry.Name())
+		if _, err := os.Stat(filepath.Join(packageDir, "package.yaml")); !entry.IsDir() || err != nil {
+			continue
+		}
+		p, err := LoadPackage(packageDir, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("error loading package %q: %w", entry.Name(), err)
+		}
+		c.Packages = append(c.Packages, *p)
+	}
+	return &c, nil
+}
+
+// LoadCatalogMetadata loads catalog metadata from a catalog.yaml file.
+func LoadCatalogMetadata(metadataFile string) (CatalogMetadata, error) {
+	data, err := os.ReadFile(metadataFile)
+	if err != nil {
+		return CatalogMetadata{}, err
+	}
+	var metadata CatalogMetadata
+	err = yaml.Unmarshal(data, &metadata)
+	return metadata, err
+}
+
 func (c *Catalog) ArtifactType() string {
 	return MediaTypeCatalog
 }
 
 func (c *Catalog) Annotations() map[string]string {
-	return nil
+	var annotations map[string]string
+	for k, v := range map[string]string{
+		ocispec.AnnotationTitle:       c.Metadata.DisplayName,
+		ocispec.AnnotationVendor:      c.Metadata.Publisher,
+		ocispec.AnnotationDescription: c.Metadata.Description,
+		ocispec.AnnotationURL:         c.Metadata.Homepage,
+	} {
+		if v == "" {
+			continue
+		}
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[k] = v
+	}
+	return annotations
 }
 
 func (c *Catalog) SubArtifacts() []client.Artifact {
@@ -92,7 +190,39 @@ func (c *Catalog) SubArtifacts() []client.Artifact {
 }
 
 func (c *Catalog) Blobs() []client.Blob {
-	return nil
+	var blobs []client.Blob
+	if c.Metadata != (CatalogMetadata{}) {
+		blobs = append(blobs, c.Metadata)
+	}
+	if c.Icon != nil {
+		blobs = append(blobs, c.Icon)
+	}
+	return blobs
+}
+
+// CatalogMetadata describes who publishes a catalog and what it contains.
+type CatalogMetadata struct {
+	DisplayName string `json:"displayName,omitempty"`
+	Publisher   string `json:"publisher,omitempty"`
+	Description string `json:"description,omitempty"`
+	Homepage    string `json:"homepage,omitempty"`
+	Support     string `json:"support,omitempty"`
+}
+
+func (cm CatalogMetadata) MediaType() string {
+	return MediaTypeCatalogMetadata
+}
+
+func (cm CatalogMetadata) Annotations() map[string]string {
+	return blobAnnotations("metadata.yaml", BlobRoleMetadata)
+}
+
+func (cm CatalogMetadata) Data() (io.ReadCloser, error) {
+	data, err := yaml.Marshal(cm)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
 }
 
 type Package struct {
@@ -100,12 +230,13 @@ type Package struct {
 	Description  Description
 	Icon         *Icon
 	UpgradeEdges UpgradeEdges
+	UpgradeSkips UpgradeSkips
 	Properties   Properties
 
 	Channels []Channel
 }
 
-func LoadPackage(packageDir string) (*Package, error) {
+func LoadPackage(packageDir string, opts ...LoadBundleOption) (*Package, error) {
 	var (
 		pkg Package
 		err error
@@ -119,21 +250,21 @@ func LoadPackage(packageDir string) (*Package, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error loading description: %w", err)
 	}
-	pkg.Icon, err = loadIcon(packageDir)
+	pkg.Icon, err = loadIcon(packageDir, filepath.Join(packageDir, "package.yaml"))
 	if err != nil {
 		return nil, fmt.Errorf("error loading icon: %w", err)
 	}
 
-	bundles, err := loadBundles(packageDir)
+	bundles, err := loadBundles(packageDir, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("error loading bundles: %w", err)
 	}
 
-	pkg.UpgradeEdges, err = loadUpgradeEdges(packageDir, bundles)
+	pkg.UpgradeEdges, pkg.UpgradeSkips, err = loadUpgradeEdges(packageDir, bundles)
 	if err != nil {
 		return nil, fmt.Errorf("error loading upgrade edges: %w", err)
 	}
-	pkg.Properties, err = loadProperties(filepath.Join(packageDir, "properties.yaml"))
+	pkg.Properties, err = loadProperties(os.DirFS(packageDir), "properties.yaml")
 	if err != nil {
 		return nil, fmt.Errorf("error loading properties: %w", err)
 	}
@@ -141,10 +272,29 @@ func LoadPackage(packageDir string) (*Package, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error loading channels: %w", err)
 	}
+	if err := validateDefaultChannel(pkg.Metadata.DefaultChannel, pkg.Channels); err != nil {
+		return nil, err
+	}
 
 	return &pkg, nil
 }
 
+// validateDefaultChannel checks that defaultChannel, if set, is one of
+// channels, which are the package's defined and generated channels.
+func validateDefaultChannel(defaultChannel string, channels []Channel) error {
+	if defaultChannel == "" {
+		return nil
+	}
+	names := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		if ch.Metadata.Name == defaultChannel {
+			return nil
+		}
+		names = append(names, ch.Metadata.Name)
+	}
+	return fmt.Errorf("default channel %q is not one of the package's channels %q", defaultChannel, names)
+}
+
 func loadPackageMetadata(metadataFile string) (PackageMetadata, error) {
 	data, err := os.ReadFile(metadataFile)
 	if err != nil {
@@ -163,83 +313,53 @@ func loadDescription(file string) (Description, error) {
 	return Description(data), nil
 }
 
-func loadIcon(packageDir string) (*Icon, error) {
-	var (
-		file      string
-		mediaType string
-	)
-	svgFile := filepath.Join(packageDir, "icon.svg")
-	pngFile := filepath.Join(packageDir, "icon.png")
-	if _, err := os.Stat(svgFile); err == nil {
-		file, mediaType = svgFile, "image/svg+xml"
-	} else if _, err := os.Stat(pngFile); err == nil {
-		file, mediaType = pngFile, "image/png"
-	} else {
-		return nil, nil
-	}
-	data, err := os.ReadFile(file)
-	if err != nil {
-		return nil, err
-	}
-	return &Icon{ImageData: data, ImageMediaType: mediaType}, nil
-}
-
-func loadUpgradeEdges(packageDir string, bundles []Bundle) (UpgradeEdges, error) {
+// loadUpgradeEdges loads the package's upgrade-edges.yaml. Its upgradeEdges
+// map a bundle version to the versions it can be upgraded to, its skips map
+// a bundle version to the versions it can be upgraded to directly from, and
+// its skipRanges map a bundle version to a semver range of versions it can
+// be upgraded to directly from.
+func loadUpgradeEdges(packageDir string, bundles []Bundle) (UpgradeEdges, UpgradeSkips, error) {
 	data, err := os.ReadFile(filepath.Join(packageDir, "upgrade-edges.yaml"))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	var ue struct {
-		UpgradeEdges UpgradeEdges `json:"upgradeEdges"`
+		UpgradeEdges map[string][]string `json:"upgradeEdges"`
+		Skips        map[string][]string `json:"skips"`
+		SkipRanges   map[string]string   `json:"skipRanges"`
 	}
 	if err = yaml.Unmarshal(data, &ue); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	byVersionBundles := map[string][]Bundle{}
-	for _, bundle := range bundles {
-		byVersionBundles[bundle.Metadata.Version.String()] = append(byVersionBundles[bundle.Metadata.Version.String()], bundle)
-	}
-	for version, releases := range byVersionBundles {
-		sort.Slice(releases, func(i, j int) bool {
-			return releases[i].Metadata.Release < releases[j].Metadata.Release
-		})
-		byVersionBundles[version] = releases
-	}
-
-	fullVersion := func(release Bundle) string {
-		return fmt.Sprintf("%s-%d", release.Metadata.Version, release.Metadata.Release)
-	}
-	fullVersions := func(releases []Bundle) []string {
-		fullVersions := make([]string, len(releases))
-		for i, release := range releases {
-			fullVersions[i] = fullVersion(release)
-		}
-		return fullVersions
-	}
-
-	byVersion := map[string][]string{}
-	for version, releases := range byVersionBundles {
-		byVersion[version] = fullVersions(releases)
-	}
+	byVersion := versionReleasesByVersion(bundles)
 
-	finalUpgradeEdges := map[string][]string{}
+	finalUpgradeEdges := UpgradeEdges{}
 	for fromVersion, toVersions := range ue.UpgradeEdges {
 		for i, fromRelease := range byVersion[fromVersion] {
-			finalUpgradeEdges[fromRelease] = byVersion[fromVersion][i+1:]
+			finalUpgradeEdges[fromRelease] = append([]VersionRelease(nil), byVersion[fromVersion][i+1:]...)
 			for _, toVersion := range toVersions {
-				finalUpgradeEdges[fromRelease] = append(finalUpgradeEdges[fromRelease], byVersion[toVersion][len(byVersion[toVersion])-1])
+				toReleases, ok := byVersion[toVersion]
+				if !ok {
+					return nil, nil, fmt.Errorf("upgradeEdges: no bundles found with version %q", toVersion)
+				}
+				finalUpgradeEdges[fromRelease] = append(finalUpgradeEdges[fromRelease], toReleases[len(toReleases)-1])
 			}
-			sort.Sort(sort.Reverse(sort.StringSlice(finalUpgradeEdges[fromRelease])))
+			sortDescending(finalUpgradeEdges[fromRelease])
 		}
 	}
-	return finalUpgradeEdges, err
+
+	skips, err := loadUpgradeSkips(ue.Skips, ue.SkipRanges, byVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	return finalUpgradeEdges, skips, nil
 }
 
-func loadProperties(propertiesFile string) (Properties, error) {
-	data, err := os.ReadFile(propertiesFile)
+func loadProperties(fsys fs.FS, name string) (Properties, error) {
+	data, err := fs.ReadFile(fsys, name)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return nil, nil
 		}
 		return nil, err
@@ -253,10 +373,10 @@ func loadProperties(propertiesFile string) (Properties, error) {
 	return p.Properties, nil
 }
 
-func loadConstraints(constraintsFile string) (Constraints, error) {
-	data, err := os.ReadFile(constraintsFile)
+func loadConstraints(fsys fs.FS, name string) (Constraints, error) {
+	data, err := fs.ReadFile(fsys, name)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return nil, nil
 		}
 		return nil, err
@@ -268,7 +388,7 @@ func loadConstraints(constraintsFile string) (Constraints, error) {
 	return c.Constraints, err
 }
 
-func loadBundles(packageDir string) ([]Bundle, error) {
+func loadBundles(packageDir string, opts ...LoadBundleOption) ([]Bundle, error) {
 	bundlesDir := filepath.Join(packageDir, "bundles")
 	entries, err := os.ReadDir(bundlesDir)
 	if err != nil {
@@ -280,7 +400,7 @@ func loadBundles(packageDir string) ([]Bundle, error) {
 			continue
 		}
 		bundleDir := filepath.Join(bundlesDir, entry.Name())
-		bundle, err := LoadBundle(bundleDir)
+		bundle, err := LoadBundle(bundleDir, opts...)
 		if err != nil {
 			return nil, err
 		}
@@ -289,10 +409,17 @@ func loadBundles(packageDir string) ([]Bundle, error) {
 	return bundles, nil
 }
 
+// loadChannels loads the channels defined in the package's channels
+// directory and those generated from its channel-template.yaml.
 func loadChannels(packageDir string, bundles []Bundle) ([]Channel, error) {
+	generated, err := loadGeneratedChannels(packageDir, bundles)
+	if err != nil {
+		return nil, fmt.Errorf("error generating channels: %w", err)
+	}
+
 	channelsDir := filepath.Join(packageDir, "channels")
 	entries, err := os.ReadDir(channelsDir)
-	if err != nil {
+	if err != nil && (len(generated) == 0 || !errors.Is(err, os.ErrNotExist)) {
 		return nil, err
 	}
 	var channels []Channel
@@ -307,32 +434,175 @@ func loadChannels(packageDir string, bundles []Bundle) ([]Channel, error) {
 		}
 		channels = append(channels, *channel)
 	}
+
+	if len(generated) > 0 {
+		names := sets.New[string]()
+		for _, ch := range channels {
+			names.Insert(ch.Metadata.Name)
+		}
+		for _, ch := range generated {
+			if names.Has(ch.Metadata.Name) {
+				return nil, fmt.Errorf("channel %q is both generated and defined in %s", ch.Metadata.Name, channelsDir)
+			}
+		}
+		channels = append(channels, generated...)
+		sort.Slice(channels, func(i, j int) bool { return channels[i].Metadata.Name < channels[j].Metadata.Name })
+	}
 	return channels, nil
 }
 
-func LoadBundle(bundleDir string) (*Bundle, error) {
-	var bundle Bundle
+// LoadBundleOption configures how bundles are loaded.
+type LoadBundleOption func(*loadBundleOptions)
+
+type loadBundleOptions struct {
+	generateProperties    bool
+	warn                  func(msg string)
+	annotationPassthrough []string
+	skipUnloadableBundles bool
+}
+
+// WithGeneratedProperties derives properties from the bundle's content, for
+// formats that implement PropertyGenerator, and merges them with the
+// bundle's declared properties.
+func WithGeneratedProperties() LoadBundleOption {
+	return func(o *loadBundleOptions) {
+		o.generateProperties = true
+	}
+}
+
+// WithWarningHandler sets the function that receives warnings about bundle
+// content from formats that implement Warner. By default, warnings are
+// discarded.
+func WithWarningHandler(warn func(msg string)) LoadBundleOption {
+	return func(o *loadBundleOptions) {
+		o.warn = warn
+	}
+}
+
+// WithAnnotationPassthrough adds annotations from the bundle's
+// metadata/annotations.yaml that match patterns to the bundle's manifest.
+// A pattern is an annotation key, or a key prefix followed by "*", such as
+// "com.example.*". Annotations matching "org.opencontainers.image.*" are
+// always passed through. Reserved "io.operatorframework.*" annotations and
+// the title and version that the bundle's manifest is annotated with never
+// are.
+func WithAnnotationPassthrough(patterns ...string) LoadBundleOption {
+	return func(o *loadBundleOptions) {
+		o.annotationPassthrough = append(o.annotationPassthrough, patterns...)
+	}
+}
+
+// WithSkipUnloadableBundles makes LoadIndexImage leave out the bundles that
+// cannot be imported, and report them to the warning handler, instead of
+// failing.
+func WithSkipUnloadableBundles() LoadBundleOption {
+	return func(o *loadBundleOptions) {
+		o.skipUnloadableBundles = true
+	}
+}
+
+// LoadBundle loads the bundle in bundlePath, which is a bundle directory or
+// a packaged Helm chart.
+func LoadBundle(bundlePath string, opts ...LoadBundleOption) (*Bundle, error) {
+	fsys, err := bundlePathFS(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	return LoadBundleFS(fsys, append(opts, withWarningPrefix(bundlePath))...)
+}
+
+// withWarningPrefix prefixes the warnings passed to the warning handler set
+// by the options before it.
+func withWarningPrefix(prefix string) LoadBundleOption {
+	return func(o *loadBundleOptions) {
+		warn := o.warn
+		o.warn = func(msg string) { warn(prefix + ": " + msg) }
+	}
+}
+
+// bundlePathFS returns the content of the bundle directory or packaged Helm
+// chart at bundlePath. Packaged Helm charts are the only bundle archives.
+func bundlePathFS(bundlePath string) (fs.FS, error) {
+	s, err := os.Stat(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	if s.IsDir() {
+		return os.DirFS(bundlePath), nil
+	}
+	if !strings.HasSuffix(bundlePath, ".tgz") && !strings.HasSuffix(bundlePath, ".tar.gz") {
+		return nil, fmt.Errorf("%s is not a bundle directory or a packaged Helm chart", bundlePath)
+	}
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return helmArchiveFS(f)
+}
+
+// LoadBundleFS loads the bundle whose content is fsys.
+func LoadBundleFS(fsys fs.FS, opts ...LoadBundleOption) (*Bundle, error) {
+	mt, format, err := DetectBundleFormat(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return loadBundle(fsys, mt, format, opts...)
+}
+
+func loadBundle(fsys fs.FS, mt string, format BundleFormat, opts ...LoadBundleOption) (*Bundle, error) {
+	o := loadBundleOptions{
+		warn:                  func(string) {},
+		annotationPassthrough: []string{"org.opencontainers.image.*"},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	metadataAnnotations, err := loadBundleMetadataAnnotations(os.DirFS(bundleDir))
+	parsed, err := format.Parse(fsys)
 	if err != nil {
-		return nil, fmt.Errorf("error loading metadata annotations: %w", err)
+		return nil, fmt.Errorf("invalid %s bundle: %w", mt, err)
 	}
-	mt, ok := metadataAnnotations[AnnotationKeyBundleContentMediaType]
-	if !ok {
-		return nil, fmt.Errorf("could not detect bundle content media type")
+
+	if w, ok := parsed.(Warner); ok {
+		warnings, err := w.Warnings()
+		if err != nil {
+			return nil, fmt.Errorf("error checking bundle: %w", err)
+		}
+		for _, msg := range warnings {
+			o.warn(msg)
+		}
 	}
-	bundle.ContentMediaType = mt
-	bundle.Content = BundleContent{FS: os.DirFS(bundleDir)}
 
-	bundle.Metadata, bundle.RelatedImages, err = loadBundleMetadataAndRelatedImages(bundle.ContentMediaType, bundleDir, metadataAnnotations)
+	// Formats that do not use metadata/annotations.yaml have no
+	// annotations or deprecation.
+	annotations, _ := loadBundleMetadataAnnotations(fsys)
+	bundle := Bundle{
+		ContentMediaType:  mt,
+		CustomAnnotations: customAnnotations(annotations, o.annotationPassthrough),
+		Content:           BundleContent{FS: fsys},
+	}
+	bundle.Metadata, err = parsed.LoadMetadata()
 	if err != nil {
 		return nil, fmt.Errorf("error loading metadata: %w", err)
 	}
-	bundle.Properties, err = loadProperties(filepath.Join(bundleDir, "metadata", "properties.yaml"))
+	bundle.Metadata.Deprecation = bundleDeprecation(annotations)
+	bundle.RelatedImages, err = parsed.LoadRelatedImages()
+	if err != nil {
+		return nil, fmt.Errorf("error loading related images: %w", err)
+	}
+	bundle.Properties, err = parsed.LoadProperties()
 	if err != nil {
 		return nil, fmt.Errorf("error loading properties: %w", err)
 	}
-	bundle.Constraints, err = loadConstraints(filepath.Join(bundleDir, "metadata", "constraints.yaml"))
+	if gen, ok := parsed.(PropertyGenerator); ok && o.generateProperties {
+		generated, err := gen.GenerateProperties()
+		if err != nil {
+			return nil, fmt.Errorf("error generating properties: %w", err)
+		}
+		bundle.Properties = mergeProperties(bundle.Properties, generated)
+	}
+	bundle.Constraints, err = parsed.LoadConstraints()
 	if err != nil {
 		return nil, fmt.Errorf("error loading constraints: %w", err)
 	}
@@ -364,6 +634,12 @@ func loadBundleMetadataAnnotations(root fs.FS) (map[string]string, error) {
 	if mediatype, ok := annotations.Annotations["operators.operatorframework.io.bundle.mediatype.v1"]; ok {
 		annotations.Annotations[AnnotationKeyBundleContentMediaType] = mediatype
 	}
+	if channels, ok := annotations.Annotations["operators.operatorframework.io.bundle.channels.v1"]; ok {
+		annotations.Annotations[AnnotationKeyBundleLegacyChannels] = channels
+	}
+	if defaultChannel, ok := annotations.Annotations["operators.operatorframework.io.bundle.channel.default.v1"]; ok {
+		annotations.Annotations[AnnotationKeyBundleLegacyDefaultChannel] = defaultChannel
+	}
 	delete(annotations.Annotations, "operators.operatorframework.io.bundle.channel.default.v1")
 	delete(annotations.Annotations, "operators.operatorframework.io.bundle.channels.v1")
 	delete(annotations.Annotations, "operators.operatorframework.io.bundle.manifests.v1")
@@ -374,50 +650,6 @@ func loadBundleMetadataAnnotations(root fs.FS) (map[string]string, error) {
 	return annotations.Annotations, nil
 }
 
-func loadBundleMetadataAndRelatedImages(mediaType string, bundleDir string, metadataAnnotations map[string]string) (BundleMetadata, RelatedImages, error) {
-	pkgName, ok := metadataAnnotations[AnnotationKeyBundlePackage]
-	if !ok {
-		return BundleMetadata{}, RelatedImages{}, fmt.Errorf("missing bundle package annotation %q", AnnotationKeyBundlePackage)
-	}
-	if mediaType == MediaTypeBundleFormatRegistryV1 {
-		return loadBundleMetadataAndRelatedImagesRegistryV1(bundleDir, pkgName)
-	}
-
-	v, ok := metadataAnnotations[AnnotationKeyBundleVersion]
-	if !ok {
-		return BundleMetadata{}, RelatedImages{}, fmt.Errorf("missing bundle version annotation %q", AnnotationKeyBundleVersion)
-	}
-	bundleVersion, err := semver.Parse(v)
-	if err != nil {
-		return BundleMetadata{}, RelatedImages{}, fmt.Errorf("invalid bundle version %q: %v", v, err)
-	}
-
-	var bundleRelease uint64
-	r, ok := metadataAnnotations[AnnotationKeyBundleRelease]
-	if ok {
-		bundleRelease, err = strconv.ParseUint(r, 10, 64)
-		if err != nil {
-			return BundleMetadata{}, RelatedImages{}, fmt.Errorf("invalid bundle release %q: %v", r, err)
-		}
-	}
-
-	riData, err := os.ReadFile(filepath.Join(bundleDir, "metadata", "relatedImages.yaml"))
-	if err != nil && !errors.Is(err, os.ErrNotExist) {
-		return BundleMetadata{}, RelatedImages{}, fmt.Errorf("load related images: %v", err)
-	}
-	var ri struct {
-		RelatedImages RelatedImages `json:"relatedImages"`
-	}
-	if err := yaml.Unmarshal(riData, &ri); err != nil {
-		return BundleMetadata{}, RelatedImages{}, fmt.Errorf("unmarshal related images: %v", err)
-	}
-	return BundleMetadata{
-		Package: pkgName,
-		Version: bundleVersion,
-		Release: uint(bundleRelease),
-	}, ri.RelatedImages, nil
-}
-
 func getRegistryBundleRelatedImages(b registry.Bundle) (RelatedImages, error) {
 	csv, err := b.ClusterServiceVersion()
 	if err != nil {
@@ -466,32 +698,6 @@ func getRegistryBundleRelatedImages(b registry.Bundle) (RelatedImages, error) {
 	return relatedImages, nil
 }
 
-func loadBundleMetadataAndRelatedImagesRegistryV1(bundleDir string, pkgName string) (BundleMetadata, RelatedImages, error) {
-	logrus.SetOutput(io.Discard)
-	ii, err := registry.NewImageInput(image.SimpleReference("placeholder"), bundleDir)
-	if err != nil {
-		return BundleMetadata{}, RelatedImages{}, fmt.Errorf("error creating image input: %v", err)
-	}
-
-	relatedImages, err := getRegistryBundleRelatedImages(*ii.Bundle)
-	if err != nil {
-		return BundleMetadata{}, RelatedImages{}, fmt.Errorf("error getting related images: %v", err)
-	}
-	verStr, err := ii.Bundle.Version()
-	if err != nil {
-		return BundleMetadata{}, RelatedImages{}, fmt.Errorf("error getting bundle version: %v", err)
-	}
-	version, err := semver.Parse(verStr)
-	if err != nil {
-		return BundleMetadata{}, RelatedImages{}, fmt.Errorf("invalid bundle version %q: %v", verStr, err)
-	}
-
-	return BundleMetadata{
-		Package: pkgName,
-		Version: version,
-	}, relatedImages, nil
-}
-
 func LoadChannel(channelDir string, bundles []Bundle) (*Channel, error) {
 	var (
 		channel Channel
@@ -506,14 +712,20 @@ func LoadChannel(channelDir string, bundles []Bundle) (*Channel, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error loading entries: %w", err)
 	}
-	channel.Properties, err = loadProperties(filepath.Join(channelDir, "properties.yaml"))
+	channel.Properties, err = loadProperties(os.DirFS(channelDir), "properties.yaml")
 	if err != nil {
 		return nil, fmt.Errorf("error loading properties: %w", err)
 	}
+	channel.UpgradeEdgeOverrides, err = loadChannelUpgradeEdges(channelDir, bundles)
+	if err != nil {
+		return nil, fmt.Errorf("error loading upgrade edges: %w", err)
+	}
 
 	return &channel, nil
 }
 
+// loadEntries loads the bundles selected by the channel's entries.yaml. See
+// selectBundles for the syntax of its entries and exclude lists.
 func loadEntries(channelDir string, bundles []Bundle) ([]Bundle, error) {
 	data, err := os.ReadFile(filepath.Join(channelDir, "entries.yaml"))
 	if err != nil {
@@ -521,25 +733,12 @@ func loadEntries(channelDir string, bundles []Bundle) ([]Bundle, error) {
 	}
 	var entries struct {
 		Entries []string `json:"entries"`
+		Exclude []string `json:"exclude"`
 	}
 	if err := yaml.Unmarshal(data, &entries); err != nil {
 		return nil, err
 	}
-
-	byVersion := map[string][]Bundle{}
-	for _, bundle := range bundles {
-		byVersion[bundle.Metadata.Version.String()] = append(byVersion[bundle.Metadata.Version.String()], bundle)
-	}
-
-	var out []Bundle
-	for _, entry := range entries.Entries {
-		bundlesByVersion, ok := byVersion[entry]
-		if !ok {
-			return nil, fmt.Errorf("no bundles found with version %q", entry)
-		}
-		out = append(out, bundlesByVersion...)
-	}
-	return out, nil
+	return selectBundles(entries.Entries, entries.Exclude, bundles)
 }
 
 func loadChannelMetadata(metadataFile string) (ChannelMetadata, error) {
@@ -557,7 +756,23 @@ func (p Package) ArtifactType() string {
 }
 
 func (p Package) Annotations() map[string]string {
-	return map[string]string{AnnotationKeyName: p.Metadata.Name}
+	annotations := map[string]string{
+		AnnotationKeyName:       p.Metadata.Name,
+		ocispec.AnnotationTitle: p.Metadata.Name,
+	}
+	if p.Metadata.DisplayName != "" {
+		annotations[ocispec.AnnotationTitle] = p.Metadata.DisplayName
+	}
+	if len(p.Metadata.URLs) > 0 {
+		annotations[ocispec.AnnotationURL] = p.Metadata.URLs[0]
+	}
+	if p.Metadata.Licenses != "" {
+		annotations[ocispec.AnnotationLicenses] = p.Metadata.Licenses
+	}
+	if v := p.latestVersion(); v != nil {
+		annotations[ocispec.AnnotationVersion] = v.String()
+	}
+	return annotations
 }
 
 func (p Package) SubArtifacts() []client.Artifact {
@@ -579,6 +794,9 @@ func (p Package) Blobs() []client.Blob {
 	if len(p.UpgradeEdges) > 0 {
 		blobs = append(blobs, p.UpgradeEdges)
 	}
+	if len(p.UpgradeSkips) > 0 {
+		blobs = append(blobs, p.UpgradeSkips)
+	}
 	if len(p.Properties) > 0 {
 		blobs = append(blobs, p.Properties)
 	}
@@ -586,11 +804,16 @@ func (p Package) Blobs() []client.Blob {
 }
 
 type PackageMetadata struct {
-	Name        string       `json:"name"`
-	DisplayName string       `json:"displayName,omitempty"`
-	Keywords    []string     `json:"keywords,omitempty"`
-	URLs        []string     `json:"urls,omitempty"`
-	Maintainers []Maintainer `json:"maintainers,omitempty"`
+	Name           string       `json:"name"`
+	DisplayName    string       `json:"displayName,omitempty"`
+	DefaultChannel string       `json:"defaultChannel,omitempty"`
+	Keywords       []string     `json:"keywords,omitempty"`
+	URLs           []string     `json:"urls,omitempty"`
+	Maintainers    []Maintainer `json:"maintainers,omitempty"`
+	Deprecation    *Deprecation `json:"deprecation,omitempty"`
+
+	// Licenses is an SPDX license expression, such as "Apache-2.0".
+	Licenses string `json:"licenses,omitempty"`
 }
 
 type Maintainer struct {
@@ -602,6 +825,10 @@ func (pm PackageMetadata) MediaType() string {
 	return MediaTypePackageMetadata
 }
 
+func (pm PackageMetadata) Annotations() map[string]string {
+	return blobAnnotations("metadata.yaml", BlobRoleMetadata)
+}
+
 func (pm PackageMetadata) Data() (io.ReadCloser, error) {
 	data, err := yaml.Marshal(pm)
 	if err != nil {
@@ -613,7 +840,11 @@ func (pm PackageMetadata) Data() (io.ReadCloser, error) {
 type Description string
 
 func (d Description) MediaType() string {
-	return "text/markdown"
+	return MediaTypeDescription
+}
+
+func (d Description) Annotations() map[string]string {
+	return blobAnnotations("README.md", BlobRoleDescription)
 }
 
 func (d Description) Data() (io.ReadCloser, error) {
@@ -629,16 +860,25 @@ func (i Icon) MediaType() string {
 	return i.ImageMediaType
 }
 
+func (i Icon) Annotations() map[string]string {
+	return blobAnnotations("icon"+iconExtension(i.ImageMediaType), BlobRoleIcon)
+}
+
 func (i Icon) Data() (io.ReadCloser, error) {
 	return io.NopCloser(bytes.NewReader(i.ImageData)), nil
 }
 
-type UpgradeEdges map[string][]string
+// UpgradeEdges maps a bundle to the bundles it can be upgraded to.
+type UpgradeEdges map[VersionRelease][]VersionRelease
 
 func (ue UpgradeEdges) MediaType() string {
 	return MediaTypeUpgradeEdges
 }
 
+func (ue UpgradeEdges) Annotations() map[string]string {
+	return blobAnnotations("upgrade-edges.yaml", BlobRoleUpgradeEdges)
+}
+
 func (ue UpgradeEdges) Data() (io.ReadCloser, error) {
 	data, err := yaml.Marshal(ue)
 	if err != nil {
@@ -648,8 +888,9 @@ func (ue UpgradeEdges) Data() (io.ReadCloser, error) {
 }
 
 type Channel struct {
-	Metadata   ChannelMetadata
-	Properties Properties
+	Metadata             ChannelMetadata
+	Properties           Properties
+	UpgradeEdgeOverrides ChannelUpgradeEdges
 
 	Bundles []Bundle
 }
@@ -659,7 +900,10 @@ func (c Channel) ArtifactType() string {
 }
 
 func (c Channel) Annotations() map[string]string {
-	return map[string]string{AnnotationKeyName: c.Metadata.Name}
+	return map[string]string{
+		AnnotationKeyName:       c.Metadata.Name,
+		ocispec.AnnotationTitle: c.Metadata.Name,
+	}
 }
 
 func (c Channel) SubArtifacts() []client.Artifact {
@@ -675,17 +919,25 @@ func (c Channel) Blobs() []client.Blob {
 	if len(c.Properties) > 0 {
 		blobs = append(blobs, c.Properties)
 	}
+	if !c.UpgradeEdgeOverrides.isZero() {
+		blobs = append(blobs, c.UpgradeEdgeOverrides)
+	}
 	return blobs
 }
 
 type ChannelMetadata struct {
-	Name string `json:"name"`
+	Name        string       `json:"name"`
+	Deprecation *Deprecation `json:"deprecation,omitempty"`
 }
 
 func (cm ChannelMetadata) MediaType() string {
 	return MediaTypeChannelMetadata
 }
 
+func (cm ChannelMetadata) Annotations() map[string]string {
+	return blobAnnotations("metadata.yaml", BlobRoleMetadata)
+}
+
 func (cm ChannelMetadata) Data() (io.ReadCloser, error) {
 	data, err := yaml.Marshal(cm)
 	if err != nil {
@@ -703,6 +955,14 @@ type Bundle struct {
 	ContentMediaType string
 	Content          BundleContent
 
+	// MigrationHints holds annotations carried over from legacy bundle
+	// formats, such as AnnotationKeyBundleLegacyChannels.
+	MigrationHints map[string]string
+
+	// CustomAnnotations holds annotations passed through from the bundle's
+	// metadata/annotations.yaml. See WithAnnotationPassthrough.
+	CustomAnnotations map[string]string
+
 	Digest digest.Digest
 }
 
@@ -711,11 +971,71 @@ func (b Bundle) ArtifactType() string {
 }
 
 func (b Bundle) Annotations() map[string]string {
-	return map[string]string{
-		AnnotationKeyBundleVersion:          b.Metadata.Version.String(),
-		AnnotationKeyBundleRelease:          fmt.Sprintf("%d", b.Metadata.Release),
-		AnnotationKeyBundleContentMediaType: b.ContentMediaType,
+	annotations := map[string]string{}
+	if len(b.CustomAnnotations) > 0 {
+		keys := make([]string, 0, len(b.CustomAnnotations))
+		for k, v := range b.CustomAnnotations {
+			annotations[k] = v
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		annotations[AnnotationKeyBundleCustomAnnotations] = strings.Join(keys, ",")
+	}
+	annotations[ocispec.AnnotationTitle] = b.Metadata.Package
+	annotations[ocispec.AnnotationVersion] = b.Metadata.VersionRelease().String()
+	for k, v := range b.MigrationHints {
+		annotations[k] = v
+	}
+	annotations[AnnotationKeyBundlePackage] = b.Metadata.Package
+	annotations[AnnotationKeyBundleVersion] = b.Metadata.Version.String()
+	annotations[AnnotationKeyBundleRelease] = fmt.Sprintf("%d", b.Metadata.Release)
+	annotations[AnnotationKeyBundleContentMediaType] = b.ContentMediaType
+	return annotations
+}
+
+// customAnnotations returns the annotations from the bundle's
+// metadata/annotations.yaml that match patterns. Annotations that
+// Bundle.Annotations generates are never passed through.
+func customAnnotations(annotations map[string]string, patterns []string) map[string]string {
+	var custom map[string]string
+	for k, v := range annotations {
+		if isGeneratedBundleAnnotation(k) || !matchesAnnotationPattern(k, patterns) {
+			continue
+		}
+		if custom == nil {
+			custom = map[string]string{}
+		}
+		custom[k] = v
 	}
+	return custom
+}
+
+func isGeneratedBundleAnnotation(key string) bool {
+	return strings.HasPrefix(key, "io.operatorframework.") || key == ocispec.AnnotationTitle || key == ocispec.AnnotationVersion
+}
+
+func matchesAnnotationPattern(key string, patterns []string) bool {
+	for _, p := range patterns {
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(key, strings.TrimSuffix(p, "*")) || key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// migrationHints returns the legacy annotations from the bundle's
+// metadata/annotations.yaml.
+func migrationHints(annotations map[string]string) map[string]string {
+	var hints map[string]string
+	for _, k := range []string{AnnotationKeyBundleLegacyChannels, AnnotationKeyBundleLegacyDefaultChannel} {
+		if v, ok := annotations[k]; ok {
+			if hints == nil {
+				hints = map[string]string{}
+			}
+			hints[k] = v
+		}
+	}
+	return hints
 }
 
 func (b Bundle) SubArtifacts() []client.Artifact {
@@ -741,12 +1061,18 @@ type BundleMetadata struct {
 	Package string         `json:"package"`
 	Version semver.Version `json:"version"`
 	Release uint           `json:"release"`
+
+	Deprecation *Deprecation `json:"deprecation,omitempty"`
 }
 
 func (bm BundleMetadata) MediaType() string {
 	return MediaTypeBundleMetadata
 }
 
+func (bm BundleMetadata) Annotations() map[string]string {
+	return blobAnnotations("metadata.yaml", BlobRoleMetadata)
+}
+
 func (bm BundleMetadata) Data() (io.ReadCloser, error) {
 	data, err := yaml.Marshal(bm)
 	if err != nil {
@@ -766,6 +1092,10 @@ func (ri RelatedImages) MediaType() string {
 	return MediaTypeRelatedImages
 }
 
+func (ri RelatedImages) Annotations() map[string]string {
+	return blobAnnotations("related-images.yaml", BlobRoleRelatedImages)
+}
+
 func (ri RelatedImages) Data() (io.ReadCloser, error) {
 	data, err := yaml.Marshal(ri)
 	if err != nil {
@@ -782,6 +1112,10 @@ func (bc BundleContent) MediaType() string {
 	return MediaTypeBundleContent
 }
 
+func (bc BundleContent) Annotations() map[string]string {
+	return blobAnnotations("content.tar.gz", BlobRoleContent)
+}
+
 func (bc BundleContent) Data() (io.ReadCloser, error) {
 	buf := bytes.NewBuffer(nil)
 	gzw := gzip.NewWriter(buf)
@@ -797,6 +1131,10 @@ type Properties TypeValues
 func (p Properties) MediaType() string {
 	return MediaTypeProperties
 }
+
+func (p Properties) Annotations() map[string]string {
+	return blobAnnotations("properties.yaml", BlobRoleProperties)
+}
 func (p Properties) Data() (io.ReadCloser, error) {
 	return TypeValues(p).Data()
 }
@@ -806,6 +1144,10 @@ type Constraints TypeValues
 func (c Constraints) MediaType() string {
 	return MediaTypeConstraints
 }
+
+func (c Constraints) Annotations() map[string]string {
+	return blobAnnotations("constraints.yaml", BlobRoleConstraints)
+}
 func (c Constraints) Data() (io.ReadCloser, error) {
 	return TypeValues(c).Data()
 }
@@ -836,8 +1178,18 @@ func convertTypeValues(in []TypeValue) []property.Property {
 	return out
 }
 
+// ToFBC renders c as a file-based catalog whose bundles are oci:// artifacts
+// in repo. The catalog's metadata and icon are rendered as a
+// SchemaCatalogMetadata object.
 func (c Catalog) ToFBC(ctx context.Context, repo string) (*declcfg.DeclarativeConfig, error) {
 	out := &declcfg.DeclarativeConfig{}
+	meta, err := c.metadataToFBC()
+	if err != nil {
+		return nil, err
+	}
+	if meta != nil {
+		out.Others = append(out.Others, *meta)
+	}
 	for _, p := range c.Packages {
 		p, err := p.ToFBC(ctx, repo)
 		if err != nil {
@@ -853,10 +1205,11 @@ func (c Catalog) ToFBC(ctx context.Context, repo string) (*declcfg.DeclarativeCo
 
 func (p Package) ToFBC(ctx context.Context, repo string) (*declcfg.DeclarativeConfig, error) {
 	pkg := declcfg.Package{
-		Schema:      declcfg.SchemaPackage,
-		Name:        p.Metadata.Name,
-		Description: string(p.Description),
-		Properties:  convertTypeValues(p.Properties),
+		Schema:         declcfg.SchemaPackage,
+		Name:           p.Metadata.Name,
+		DefaultChannel: p.Metadata.DefaultChannel,
+		Description:    string(p.Description),
+		Properties:     convertTypeValues(p.Properties),
 	}
 	if p.Icon != nil {
 		pkg.Icon = &declcfg.Icon{
@@ -865,42 +1218,64 @@ func (p Package) ToFBC(ctx context.Context, repo string) (*declcfg.DeclarativeCo
 		}
 	}
 
-	fullVersion := func(b Bundle) string {
-		return fmt.Sprintf("%s-%d", b.Metadata.Version, b.Metadata.Release)
-	}
 	bundleName := func(b Bundle) string {
-		return fmt.Sprintf("%s.v%s", p.Metadata.Name, fullVersion(b))
+		return fmt.Sprintf("%s.v%s", p.Metadata.Name, b.Metadata.VersionRelease())
 	}
 
 	channels := make([]declcfg.Channel, 0, len(p.Channels))
-	bundleMap := map[string]declcfg.Bundle{}
+	bundleMap := map[VersionRelease]declcfg.Bundle{}
 	for _, ch := range p.Channels {
-		inChannel := sets.New[string]()
-		lookup := make(map[string]Bundle)
+		lookup := make(map[VersionRelease]Bundle)
 		for _, b := range ch.Bundles {
-			inChannel.Insert(fullVersion(b))
-			lookup[fullVersion(b)] = b
+			lookup[b.Metadata.VersionRelease()] = b
+		}
+
+		// An FBC entry replaces at most one bundle, so the latest bundle
+		// that upgrades to it is replaced and any others are skipped.
+		replacedBy := map[VersionRelease][]Bundle{}
+		for from, tos := range p.channelReplaces(ch) {
+			for _, to := range tos {
+				replacedBy[to] = append(replacedBy[to], lookup[from])
+			}
 		}
+		skippedBy := p.channelSkips(ch)
 
 		entries := make([]declcfg.ChannelEntry, 0, len(ch.Bundles))
 		for _, b := range ch.Bundles {
-			from := fullVersion(b)
-			if len(p.UpgradeEdges) == 0 {
-				entries = append(entries, declcfg.ChannelEntry{
-					Name: bundleName(b),
-				})
-				continue
+			to := b.Metadata.VersionRelease()
+			entry := declcfg.ChannelEntry{
+				Name:      bundleName(b),
+				SkipRange: p.UpgradeSkips[to].Range,
 			}
-			tos := p.UpgradeEdges[from]
-			for _, to := range tos {
-				if !inChannel.Has(to) {
+			froms := replacedBy[to]
+			sort.Slice(froms, func(i, j int) bool { return bundleLess(froms[j], froms[i]) })
+			for i, from := range froms {
+				if i == 0 {
+					entry.Replaces = bundleName(from)
+					continue
+				}
+				entry.Skips = appendUnique(entry.Skips, bundleName(from))
+			}
+			for _, from := range skippedBy[to] {
+				if name := bundleName(lookup[from]); name != entry.Replaces {
+					entry.Skips = appendUnique(entry.Skips, name)
+				}
+			}
+			// Explicit skips of bundles outside of the channel are kept,
+			// as "opm" does, so that they survive a round trip.
+			for _, from := range p.UpgradeSkips[to].Versions {
+				if _, ok := lookup[from]; ok || from == to || ch.UpgradeEdgeOverrides.removes(from, to) {
 					continue
 				}
-				entries = append(entries, declcfg.ChannelEntry{
-					Name:     bundleName(lookup[to]),
-					Replaces: bundleName(lookup[from]),
-				})
+				entry.Skips = appendUnique(entry.Skips, fmt.Sprintf("%s.v%s", p.Metadata.Name, from))
 			}
+			for _, name := range p.UpgradeSkips[to].Names {
+				if name != entry.Replaces {
+					entry.Skips = appendUnique(entry.Skips, name)
+				}
+			}
+			sort.Strings(entry.Skips)
+			entries = append(entries, entry)
 		}
 
 		channels = append(channels, declcfg.Channel{
@@ -942,7 +1317,7 @@ func (p Package) ToFBC(ctx context.Context, repo string) (*declcfg.DeclarativeCo
 				},
 			)
 
-			bundleMap[fullVersion(b)] = declcfg.Bundle{
+			bundleMap[b.Metadata.VersionRelease()] = declcfg.Bundle{
 				Schema:     declcfg.SchemaBundle,
 				Package:    p.Metadata.Name,
 				Name:       bundleName(b),
@@ -952,35 +1327,46 @@ func (p Package) ToFBC(ctx context.Context, repo string) (*declcfg.DeclarativeCo
 		}
 	}
 
+	vrs := make([]VersionRelease, 0, len(bundleMap))
+	for vr := range bundleMap {
+		vrs = append(vrs, vr)
+	}
+	sort.Slice(vrs, func(i, j int) bool { return vrs[i].Compare(vrs[j]) < 0 })
 	bundles := make([]declcfg.Bundle, 0, len(bundleMap))
-	for _, b := range bundleMap {
-		bundles = append(bundles, b)
+	for _, vr := range vrs {
+		bundles = append(bundles, bundleMap[vr])
+	}
+
+	var others []declcfg.Meta
+	deprecations, err := p.deprecationsToFBC(bundleName)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering deprecations: %w", err)
+	}
+	if deprecations != nil {
+		others = append(others, *deprecations)
 	}
-	sort.Slice(bundles, func(i, j int) bool {
-		return bundles[i].Name < bundles[j].Name
-	})
 
 	return &declcfg.DeclarativeConfig{
 		Packages: []declcfg.Package{pkg},
 		Channels: channels,
 		Bundles:  bundles,
+		Others:   others,
 	}, nil
 }
 
 func (b *Bundle) ensureDigest(ctx context.Context) error {
+	if b.Digest != "" {
+		// trust what's already here, such as the digest of a pulled bundle
+		return nil
+	}
 	if b.Content.FS == nil {
-		if b.Digest != "" {
-			// trust what's already here
-			return nil
-		}
 		return fmt.Errorf("cannot compute digest for sparse bundle")
 	}
 	st := memory.New()
-	desc, err := client.Push(ctx, b, st)
+	desc, err := client.Push(ctx, b, st, progress.Discard)
 	if err != nil {
 		return err
 	}
 	b.Digest = desc.Digest
 	return nil
-
 }
@@ -0,0 +1,226 @@
+package v1
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestMatchesAnnotationPattern(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		key      string
+		patterns []string
+		want     bool
+	}{
+		{name: "no patterns", key: "example.com/team", want: false},
+		{name: "exact key", key: "example.com/team", patterns: []string{"example.com/team"}, want: true},
+		{name: "other key", key: "example.com/owner", patterns: []string{"example.com/team"}, want: false},
+		{name: "key prefix without wildcard", key: "example.com/team-lead", patterns: []string{"example.com/team"}, want: false},
+		{name: "wildcard prefix", key: "example.com/team", patterns: []string{"example.com/*"}, want: true},
+		{name: "wildcard matches the bare prefix", key: "example.com/", patterns: []string{"example.com/*"}, want: true},
+		{name: "wildcard of another prefix", key: "example.org/team", patterns: []string{"example.com/*"}, want: false},
+		{name: "wildcard only", key: "example.com/team", patterns: []string{"*"}, want: true},
+		{name: "any of several patterns", key: "example.org/team", patterns: []string{"example.com/*", "example.org/team"}, want: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := matchesAnnotationPattern(tc.key, tc.patterns); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCustomAnnotations(t *testing.T) {
+	annotations := map[string]string{
+		"example.com/team":                           "olm",
+		"example.com/owner":                          "joe",
+		"example.org/team":                           "other",
+		AnnotationKeyBundleLegacyChannels:            "stable",
+		"io.operatorframework.bundle.package":        "foo",
+		"operators.operatorframework.io.test.config": "tests/",
+		ocispec.AnnotationTitle:                      "foo",
+		ocispec.AnnotationVersion:                    "1.0.0",
+	}
+	for _, tc := range []struct {
+		name     string
+		patterns []string
+		want     map[string]string
+	}{
+		{name: "no patterns"},
+		{name: "exact key", patterns: []string{"example.com/team"}, want: map[string]string{"example.com/team": "olm"}},
+		{name: "prefix", patterns: []string{"example.com/*"}, want: map[string]string{"example.com/team": "olm", "example.com/owner": "joe"}},
+		{name: "no match", patterns: []string{"example.net/*"}},
+		{
+			name:     "generated annotations are never passed through",
+			patterns: []string{"*"},
+			want: map[string]string{
+				"example.com/team":                           "olm",
+				"example.com/owner":                          "joe",
+				"example.org/team":                           "other",
+				"operators.operatorframework.io.test.config": "tests/",
+			},
+		},
+		{name: "generated annotation by exact key", patterns: []string{ocispec.AnnotationTitle, AnnotationKeyBundleLegacyChannels}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := customAnnotations(annotations, tc.patterns); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestArtifactAnnotations(t *testing.T) {
+	bundle := Bundle{
+		Metadata:         BundleMetadata{Package: "foo", Version: semver.MustParse("1.2.0"), Release: 1},
+		ContentMediaType: MediaTypeBundleFormatPlainV0,
+	}
+	generated := map[string]string{
+		ocispec.AnnotationTitle:             "foo",
+		ocispec.AnnotationVersion:           "1.2.0-1",
+		AnnotationKeyBundlePackage:          "foo",
+		AnnotationKeyBundleVersion:          "1.2.0",
+		AnnotationKeyBundleRelease:          "1",
+		AnnotationKeyBundleContentMediaType: MediaTypeBundleFormatPlainV0,
+	}
+	withGenerated := func(extra map[string]string) map[string]string {
+		out := map[string]string{}
+		for k, v := range generated {
+			out[k] = v
+		}
+		for k, v := range extra {
+			out[k] = v
+		}
+		return out
+	}
+	older := bundle
+	older.Metadata.Version = semver.MustParse("1.0.0")
+
+	for _, tc := range []struct {
+		name     string
+		artifact interface{ Annotations() map[string]string }
+		want     map[string]string
+	}{
+		{name: "empty catalog", artifact: &Catalog{}},
+		{
+			name: "catalog",
+			artifact: &Catalog{Metadata: CatalogMetadata{
+				DisplayName: "Foo Catalog",
+				Publisher:   "Foo Inc.",
+				Description: "Operators from Foo Inc.",
+				Homepage:    "https://example.com",
+				Support:     "support@example.com",
+			}},
+			want: map[string]string{
+				ocispec.AnnotationTitle:       "Foo Catalog",
+				ocispec.AnnotationVendor:      "Foo Inc.",
+				ocispec.AnnotationDescription: "Operators from Foo Inc.",
+				ocispec.AnnotationURL:         "https://example.com",
+			},
+		},
+		{
+			name:     "package without bundles",
+			artifact: Package{Metadata: PackageMetadata{Name: "foo"}},
+			want:     map[string]string{AnnotationKeyName: "foo", ocispec.AnnotationTitle: "foo"},
+		},
+		{
+			name: "package",
+			artifact: Package{
+				Metadata: PackageMetadata{
+					Name:        "foo",
+					DisplayName: "Foo Operator",
+					URLs:        []string{"https://example.com/foo", "https://example.com/docs"},
+					Licenses:    "Apache-2.0",
+				},
+				Channels: []Channel{
+					{Metadata: ChannelMetadata{Name: "stable"}, Bundles: []Bundle{older}},
+					{Metadata: ChannelMetadata{Name: "fast"}, Bundles: []Bundle{older, bundle}},
+				},
+			},
+			want: map[string]string{
+				AnnotationKeyName:          "foo",
+				ocispec.AnnotationTitle:    "Foo Operator",
+				ocispec.AnnotationURL:      "https://example.com/foo",
+				ocispec.AnnotationLicenses: "Apache-2.0",
+				ocispec.AnnotationVersion:  "1.2.0-1",
+			},
+		},
+		{
+			name:     "channel",
+			artifact: Channel{Metadata: ChannelMetadata{Name: "stable"}},
+			want:     map[string]string{AnnotationKeyName: "stable", ocispec.AnnotationTitle: "stable"},
+		},
+		{name: "bundle", artifact: bundle, want: generated},
+		{
+			name: "bundle with migration hints",
+			artifact: func() Bundle {
+				b := bundle
+				b.MigrationHints = map[string]string{AnnotationKeyBundleLegacyChannels: "stable,fast"}
+				return b
+			}(),
+			want: withGenerated(map[string]string{AnnotationKeyBundleLegacyChannels: "stable,fast"}),
+		},
+		{
+			name: "bundle with custom annotations",
+			artifact: func() Bundle {
+				b := bundle
+				b.CustomAnnotations = map[string]string{"example.com/team": "olm", "example.com/owner": "joe"}
+				return b
+			}(),
+			want: withGenerated(map[string]string{
+				"example.com/team":                   "olm",
+				"example.com/owner":                  "joe",
+				AnnotationKeyBundleCustomAnnotations: "example.com/owner,example.com/team",
+			}),
+		},
+		{
+			name: "bundle custom annotations do not override generated ones",
+			artifact: func() Bundle {
+				b := bundle
+				b.CustomAnnotations = map[string]string{ocispec.AnnotationTitle: "bar"}
+				return b
+			}(),
+			want: withGenerated(map[string]string{AnnotationKeyBundleCustomAnnotations: ocispec.AnnotationTitle}),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.artifact.Annotations(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBlobAnnotations(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		blob      interface{ Annotations() map[string]string }
+		wantTitle string
+		wantRole  string
+	}{
+		{name: "catalog metadata", blob: CatalogMetadata{}, wantTitle: "metadata.yaml", wantRole: BlobRoleMetadata},
+		{name: "package metadata", blob: PackageMetadata{}, wantTitle: "metadata.yaml", wantRole: BlobRoleMetadata},
+		{name: "channel metadata", blob: ChannelMetadata{}, wantTitle: "metadata.yaml", wantRole: BlobRoleMetadata},
+		{name: "bundle metadata", blob: BundleMetadata{}, wantTitle: "metadata.yaml", wantRole: BlobRoleMetadata},
+		{name: "description", blob: Description(""), wantTitle: "README.md", wantRole: BlobRoleDescription},
+		{name: "svg icon", blob: Icon{ImageMediaType: "image/svg+xml"}, wantTitle: "icon.svg", wantRole: BlobRoleIcon},
+		{name: "upgrade edges", blob: UpgradeEdges{}, wantTitle: "upgrade-edges.yaml", wantRole: BlobRoleUpgradeEdges},
+		{name: "channel upgrade edges", blob: ChannelUpgradeEdges{}, wantTitle: "upgrade-edges.yaml", wantRole: BlobRoleUpgradeEdges},
+		{name: "upgrade skips", blob: UpgradeSkips{}, wantTitle: "upgrade-skips.yaml", wantRole: BlobRoleUpgradeSkips},
+		{name: "package index", blob: PackageIndex{}, wantTitle: "index.yaml", wantRole: BlobRolePackageIndex},
+		{name: "related images", blob: RelatedImages{}, wantTitle: "related-images.yaml", wantRole: BlobRoleRelatedImages},
+		{name: "bundle content", blob: BundleContent{}, wantTitle: "content.tar.gz", wantRole: BlobRoleContent},
+		{name: "properties", blob: Properties{}, wantTitle: "properties.yaml", wantRole: BlobRoleProperties},
+		{name: "constraints", blob: Constraints{}, wantTitle: "constraints.yaml", wantRole: BlobRoleConstraints},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want := map[string]string{ocispec.AnnotationTitle: tc.wantTitle, AnnotationKeyBlobRole: tc.wantRole}
+			if got := tc.blob.Annotations(); !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package v1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	MediaTypeUpgradeSkips        = "application/vnd.cncf.operatorframework.olm.upgrade-skips.v1+yaml"
+	MediaTypeChannelUpgradeEdges = "application/vnd.cncf.operatorframework.olm.channel.upgrade-edges.v1+yaml"
+)
+
+// UpgradeSkips maps a bundle to the bundles that can be upgraded directly
+// to it, skipping any bundles in between.
+type UpgradeSkips map[VersionRelease]Skips
+
+// Skips lists the bundles skipped by a bundle, and a semver range of bundle
+// versions that it also skips. Names lists skipped bundles that are not
+// bundles of the package, by their names in the file-based catalog that
+// they were imported from.
+type Skips struct {
+	Versions []VersionRelease `json:"versions,omitempty"`
+	Names    []string         `json:"names,omitempty"`
+	Range    string           `json:"range,omitempty"`
+}
+
+func (us UpgradeSkips) MediaType() string {
+	return MediaTypeUpgradeSkips
+}
+
+func (us UpgradeSkips) Annotations() map[string]string {
+	return blobAnnotations("upgrade-skips.yaml", BlobRoleUpgradeSkips)
+}
+
+func (us UpgradeSkips) Data() (io.ReadCloser, error) {
+	data, err := yaml.Marshal(us)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// ChannelUpgradeEdges overrides the package's upgrade edges in a single
+// channel. Add holds edges that only exist in the channel and Remove holds
+// package edges, of any kind, that do not exist in the channel. A file-based
+// catalog cannot narrow a skip range, so removed edges that fall within one
+// are still present in the rendered channel.
+type ChannelUpgradeEdges struct {
+	Add    UpgradeEdges `json:"add,omitempty"`
+	Remove UpgradeEdges `json:"remove,omitempty"`
+}
+
+func (cue ChannelUpgradeEdges) MediaType() string {
+	return MediaTypeChannelUpgradeEdges
+}
+
+func (cue ChannelUpgradeEdges) Annotations() map[string]string {
+	return blobAnnotations("upgrade-edges.yaml", BlobRoleUpgradeEdges)
+}
+
+func (cue ChannelUpgradeEdges) Data() (io.ReadCloser, error) {
+	data, err := yaml.Marshal(cue)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (cue ChannelUpgradeEdges) isZero() bool {
+	return len(cue.Add) == 0 && len(cue.Remove) == 0
+}
+
+func (cue ChannelUpgradeEdges) removes(from, to VersionRelease) bool {
+	for _, v := range cue.Remove[from] {
+		if v == to {
+			return true
+		}
+	}
+	return false
+}
+
+// subtractUpgradeEdges returns the edges of a that are not in b, or nil if
+// there are none.
+func subtractUpgradeEdges(a, b UpgradeEdges) UpgradeEdges {
+	var out UpgradeEdges
+	for from, tos := range a {
+		for _, to := range tos {
+			if containsVersionRelease(b[from], to) {
+				continue
+			}
+			if out == nil {
+				out = UpgradeEdges{}
+			}
+			out[from] = appendUnique(out[from], to)
+		}
+	}
+	for from := range out {
+		sortDescending(out[from])
+	}
+	return out
+}
+
+func containsVersionRelease(vrs []VersionRelease, vr VersionRelease) bool {
+	for _, v := range vrs {
+		if v == vr {
+			return true
+		}
+	}
+	return false
+}
+
+// versionReleasesByVersion returns the version-releases of bundles, grouped
+// by bundle version and ordered by release.
+func versionReleasesByVersion(bundles []Bundle) map[string][]VersionRelease {
+	byVersionBundles := map[string][]Bundle{}
+	for _, bundle := range bundles {
+		byVersionBundles[bundle.Metadata.Version.String()] = append(byVersionBundles[bundle.Metadata.Version.String()], bundle)
+	}
+	byVersion := map[string][]VersionRelease{}
+	for version, releases := range byVersionBundles {
+		sort.Slice(releases, func(i, j int) bool {
+			return releases[i].Metadata.Release < releases[j].Metadata.Release
+		})
+		for _, release := range releases {
+			byVersion[version] = append(byVersion[version], release.Metadata.VersionRelease())
+		}
+	}
+	return byVersion
+}
+
+// loadUpgradeSkips expands skips, which map a bundle version to the bundle
+// versions it skips, and skipRanges, which map a bundle version to the
+// semver range of versions it skips, to every release of those versions.
+func loadUpgradeSkips(skips map[string][]string, skipRanges map[string]string, byVersion map[string][]VersionRelease) (UpgradeSkips, error) {
+	out := UpgradeSkips{}
+	for toVersion, fromVersions := range skips {
+		tos, ok := byVersion[toVersion]
+		if !ok {
+			return nil, fmt.Errorf("skips: no bundles found with version %q", toVersion)
+		}
+		for _, to := range tos {
+			s := out[to]
+			for _, fromVersion := range fromVersions {
+				froms, ok := byVersion[fromVersion]
+				if !ok {
+					return nil, fmt.Errorf("skips: no bundles found with version %q", fromVersion)
+				}
+				for _, from := range froms {
+					if from != to {
+						s.Versions = appendUnique(s.Versions, from)
+					}
+				}
+			}
+			sortDescending(s.Versions)
+			out[to] = s
+		}
+	}
+	for toVersion, skipRange := range skipRanges {
+		if _, err := semver.ParseRange(skipRange); err != nil {
+			return nil, fmt.Errorf("skipRanges: invalid range %q for version %q: %v", skipRange, toVersion, err)
+		}
+		tos, ok := byVersion[toVersion]
+		if !ok {
+			return nil, fmt.Errorf("skipRanges: no bundles found with version %q", toVersion)
+		}
+		for _, to := range tos {
+			s := out[to]
+			s.Range = skipRange
+			out[to] = s
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// loadChannelUpgradeEdges loads the add and remove overrides from the
+// channel's upgrade-edges.yaml, if it exists. Like the package's upgrade
+// edges, added edges lead from every release of a version to the latest
+// release of each target version. Removed edges apply to every release of
+// both versions.
+func loadChannelUpgradeEdges(channelDir string, bundles []Bundle) (ChannelUpgradeEdges, error) {
+	data, err := os.ReadFile(filepath.Join(channelDir, "upgrade-edges.yaml"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ChannelUpgradeEdges{}, nil
+		}
+		return ChannelUpgradeEdges{}, err
+	}
+	var overrides struct {
+		Add    map[string][]string `json:"add"`
+		Remove map[string][]string `json:"remove"`
+	}
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return ChannelUpgradeEdges{}, err
+	}
+
+	byVersion := versionReleasesByVersion(bundles)
+	releases := func(version string) ([]VersionRelease, error) {
+		if fvs, ok := byVersion[version]; ok {
+			return fvs, nil
+		}
+		return nil, fmt.Errorf("no bundles found with version %q", version)
+	}
+
+	var out ChannelUpgradeEdges
+	for fromVersion, toVersions := range overrides.Add {
+		froms, err := releases(fromVersion)
+		if err != nil {
+			return ChannelUpgradeEdges{}, fmt.Errorf("add: %v", err)
+		}
+		for _, from := range froms {
+			for _, toVersion := range toVersions {
+				tos, err := releases(toVersion)
+				if err != nil {
+					return ChannelUpgradeEdges{}, fmt.Errorf("add: %v", err)
+				}
+				if out.Add == nil {
+					out.Add = UpgradeEdges{}
+				}
+				out.Add[from] = appendUnique(out.Add[from], tos[len(tos)-1])
+			}
+			sortDescending(out.Add[from])
+		}
+	}
+	for fromVersion, toVersions := range overrides.Remove {
+		froms, err := releases(fromVersion)
+		if err != nil {
+			return ChannelUpgradeEdges{}, fmt.Errorf("remove: %v", err)
+		}
+		for _, from := range froms {
+			for _, toVersion := range toVersions {
+				tos, err := releases(toVersion)
+				if err != nil {
+					return ChannelUpgradeEdges{}, fmt.Errorf("remove: %v", err)
+				}
+				if out.Remove == nil {
+					out.Remove = UpgradeEdges{}
+				}
+				for _, to := range tos {
+					out.Remove[from] = appendUnique(out.Remove[from], to)
+				}
+			}
+			sortDescending(out.Remove[from])
+		}
+	}
+	return out, nil
+}
+
+// ChannelUpgradeEdges returns every upgrade edge between the bundles of ch:
+// the package's upgrade edges and skips, including skip ranges, with ch's
+// overrides applied.
+func (p Package) ChannelUpgradeEdges(ch Channel) UpgradeEdges {
+	out := p.channelReplaces(ch)
+	for to, froms := range p.channelSkips(ch) {
+		for _, from := range froms {
+			out[from] = appendUnique(out[from], to)
+		}
+	}
+	for from := range out {
+		sortDescending(out[from])
+	}
+	return out
+}
+
+// channelReplaces returns the package's upgrade edges between the bundles
+// of ch, with ch's overrides applied.
+func (p Package) channelReplaces(ch Channel) UpgradeEdges {
+	inChannel := sets.New[VersionRelease]()
+	for _, b := range ch.Bundles {
+		inChannel.Insert(b.Metadata.VersionRelease())
+	}
+	out := UpgradeEdges{}
+	for _, edges := range []UpgradeEdges{p.UpgradeEdges, ch.UpgradeEdgeOverrides.Add} {
+		for from, tos := range edges {
+			for _, to := range tos {
+				if from == to || !inChannel.Has(from) || !inChannel.Has(to) || ch.UpgradeEdgeOverrides.removes(from, to) {
+					continue
+				}
+				out[from] = appendUnique(out[from], to)
+			}
+		}
+	}
+	return out
+}
+
+// channelSkips returns, for each bundle of ch, the bundles of ch that it
+// skips, either explicitly or by range, with ch's removals applied.
+func (p Package) channelSkips(ch Channel) map[VersionRelease][]VersionRelease {
+	out := map[VersionRelease][]VersionRelease{}
+	for _, to := range ch.Bundles {
+		toVersion := to.Metadata.VersionRelease()
+		s, ok := p.UpgradeSkips[toVersion]
+		if !ok {
+			continue
+		}
+		skipped := sets.New[VersionRelease](s.Versions...)
+		var inRange semver.Range
+		if s.Range != "" {
+			// Ranges are validated when they are loaded. An unparsable range
+			// from elsewhere skips nothing.
+			inRange, _ = semver.ParseRange(s.Range)
+		}
+		for _, from := range ch.Bundles {
+			fromVersion := from.Metadata.VersionRelease()
+			if fromVersion == toVersion || ch.UpgradeEdgeOverrides.removes(fromVersion, toVersion) {
+				continue
+			}
+			if skipped.Has(fromVersion) || (inRange != nil && inRange(from.Metadata.Version)) {
+				out[toVersion] = appendUnique(out[toVersion], fromVersion)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package v1
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChannelUpgradeEdges(t *testing.T) {
+	bundles := testBundles("foo", "0.1.0-0", "0.2.0-0", "0.2.0-1", "0.3.0-0", "0.4.0-0")
+	p := Package{
+		UpgradeEdges: UpgradeEdges{
+			mustVR("0.1.0-0"): vrs("0.2.0-1"),
+			mustVR("0.2.0-0"): vrs("0.2.0-1"),
+			mustVR("0.2.0-1"): vrs("0.3.0-0"),
+			mustVR("0.3.0-0"): vrs("0.4.0-0"),
+		},
+		UpgradeSkips: UpgradeSkips{
+			mustVR("0.3.0-0"): {Versions: vrs("0.1.0-0")},
+			mustVR("0.4.0-0"): {Range: ">=0.2.0 <0.4.0"},
+		},
+	}
+	for _, tc := range []struct {
+		name      string
+		channel   Channel
+		wantSkips map[VersionRelease][]VersionRelease
+		wantEdges UpgradeEdges
+	}{
+		{
+			name:    "all bundles",
+			channel: Channel{Bundles: bundles},
+			wantSkips: map[VersionRelease][]VersionRelease{
+				mustVR("0.3.0-0"): vrs("0.1.0-0"),
+				mustVR("0.4.0-0"): vrs("0.2.0-0", "0.2.0-1", "0.3.0-0"),
+			},
+			wantEdges: UpgradeEdges{
+				mustVR("0.1.0-0"): vrs("0.3.0-0", "0.2.0-1"),
+				mustVR("0.2.0-0"): vrs("0.4.0-0", "0.2.0-1"),
+				mustVR("0.2.0-1"): vrs("0.4.0-0", "0.3.0-0"),
+				mustVR("0.3.0-0"): vrs("0.4.0-0"),
+			},
+		},
+		{
+			name:    "edges and skips leave the channel",
+			channel: Channel{Bundles: testBundles("foo", "0.2.0-1", "0.4.0-0")},
+			wantSkips: map[VersionRelease][]VersionRelease{
+				mustVR("0.4.0-0"): vrs("0.2.0-1"),
+			},
+			wantEdges: UpgradeEdges{
+				mustVR("0.2.0-1"): vrs("0.4.0-0"),
+			},
+		},
+		{
+			name: "overrides",
+			channel: Channel{
+				Bundles: bundles,
+				UpgradeEdgeOverrides: ChannelUpgradeEdges{
+					Add: UpgradeEdges{
+						mustVR("0.1.0-0"): vrs("0.4.0-0"),
+						mustVR("0.4.0-0"): vrs("0.4.0-0"),
+					},
+					Remove: UpgradeEdges{
+						mustVR("0.2.0-1"): vrs("0.3.0-0", "0.4.0-0"),
+						mustVR("0.1.0-0"): vrs("0.3.0-0"),
+					},
+				},
+			},
+			wantSkips: map[VersionRelease][]VersionRelease{
+				mustVR("0.4.0-0"): vrs("0.2.0-0", "0.3.0-0"),
+			},
+			wantEdges: UpgradeEdges{
+				mustVR("0.1.0-0"): vrs("0.4.0-0", "0.2.0-1"),
+				mustVR("0.2.0-0"): vrs("0.4.0-0", "0.2.0-1"),
+				mustVR("0.3.0-0"): vrs("0.4.0-0"),
+			},
+		},
+		{
+			name:      "single bundle",
+			channel:   Channel{Bundles: testBundles("foo", "0.4.0-0")},
+			wantSkips: map[VersionRelease][]VersionRelease{},
+			wantEdges: UpgradeEdges{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := p.channelSkips(tc.channel); !reflect.DeepEqual(got, tc.wantSkips) {
+				t.Errorf("expected skips %v, got %v", tc.wantSkips, got)
+			}
+			if got := p.ChannelUpgradeEdges(tc.channel); !reflect.DeepEqual(got, tc.wantEdges) {
+				t.Errorf("expected edges %v, got %v", tc.wantEdges, got)
+			}
+		})
+	}
+}
+
+func TestLoadUpgradeSkips(t *testing.T) {
+	byVersion := versionReleasesByVersion(testBundles("foo", "0.1.0-0", "0.2.0-1", "0.2.0-0", "0.3.0-0"))
+	for _, tc := range []struct {
+		name       string
+		skips      map[string][]string
+		skipRanges map[string]string
+		want       UpgradeSkips
+		wantErr    string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:  "skips every release",
+			skips: map[string][]string{"0.3.0": {"0.1.0", "0.2.0"}},
+			want: UpgradeSkips{
+				mustVR("0.3.0-0"): {Versions: vrs("0.2.0-1", "0.2.0-0", "0.1.0-0")},
+			},
+		},
+		{
+			name:  "releases of the same version",
+			skips: map[string][]string{"0.2.0": {"0.2.0"}},
+			want: UpgradeSkips{
+				mustVR("0.2.0-0"): {Versions: vrs("0.2.0-1")},
+				mustVR("0.2.0-1"): {Versions: vrs("0.2.0-0")},
+			},
+		},
+		{
+			name:       "skips and ranges",
+			skips:      map[string][]string{"0.3.0": {"0.2.0"}},
+			skipRanges: map[string]string{"0.3.0": "<0.2.0", "0.2.0": ">=0.1.0 <0.2.0"},
+			want: UpgradeSkips{
+				mustVR("0.2.0-0"): {Range: ">=0.1.0 <0.2.0"},
+				mustVR("0.2.0-1"): {Range: ">=0.1.0 <0.2.0"},
+				mustVR("0.3.0-0"): {Versions: vrs("0.2.0-1", "0.2.0-0"), Range: "<0.2.0"},
+			},
+		},
+		{
+			name:    "unknown skipping version",
+			skips:   map[string][]string{"0.4.0": {"0.1.0"}},
+			wantErr: `skips: no bundles found with version "0.4.0"`,
+		},
+		{
+			name:    "unknown skipped version",
+			skips:   map[string][]string{"0.3.0": {"0.0.1"}},
+			wantErr: `skips: no bundles found with version "0.0.1"`,
+		},
+		{
+			name:       "invalid range",
+			skipRanges: map[string]string{"0.3.0": "not a range"},
+			wantErr:    `skipRanges: invalid range "not a range" for version "0.3.0"`,
+		},
+		{
+			name:       "unknown range version",
+			skipRanges: map[string]string{"0.4.0": "<0.4.0"},
+			wantErr:    `skipRanges: no bundles found with version "0.4.0"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := loadUpgradeSkips(tc.skips, tc.skipRanges, byVersion)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadChannelUpgradeEdges(t *testing.T) {
+	bundles := testBundles("foo", "0.1.0-0", "0.1.0-1", "0.2.0-0", "0.2.0-1", "0.3.0-0")
+	for _, tc := range []struct {
+		name    string
+		file    string
+		want    ChannelUpgradeEdges
+		wantErr string
+	}{
+		{
+			name: "no file",
+		},
+		{
+			name: "add leads to the latest release",
+			file: "add:\n  0.1.0: [0.3.0, 0.2.0]\n",
+			want: ChannelUpgradeEdges{
+				Add: UpgradeEdges{
+					mustVR("0.1.0-0"): vrs("0.3.0-0", "0.2.0-1"),
+					mustVR("0.1.0-1"): vrs("0.3.0-0", "0.2.0-1"),
+				},
+			},
+		},
+		{
+			name: "remove applies to every release",
+			file: "remove:\n  0.1.0: [0.2.0]\n",
+			want: ChannelUpgradeEdges{
+				Remove: UpgradeEdges{
+					mustVR("0.1.0-0"): vrs("0.2.0-1", "0.2.0-0"),
+					mustVR("0.1.0-1"): vrs("0.2.0-1", "0.2.0-0"),
+				},
+			},
+		},
+		{
+			name:    "unknown added version",
+			file:    "add:\n  0.1.0: [0.4.0]\n",
+			wantErr: `add: no bundles found with version "0.4.0"`,
+		},
+		{
+			name:    "unknown removed version",
+			file:    "remove:\n  0.0.1: [0.1.0]\n",
+			wantErr: `remove: no bundles found with version "0.0.1"`,
+		},
+		{
+			name:    "invalid file",
+			file:    "add: [0.1.0]\n",
+			wantErr: "cannot unmarshal",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.file != "" {
+				if err := os.WriteFile(filepath.Join(dir, "upgrade-edges.yaml"), []byte(tc.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := loadChannelUpgradeEdges(dir, bundles)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
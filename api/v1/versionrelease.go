@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// VersionRelease identifies a bundle within its package by its semver
+// version and its release, which distinguishes rebuilds of the same
+// version. Its string form is "<version>-<release>", e.g. "0.10.0-2".
+//
+// VersionRelease is comparable, so it can be used as a map key, and
+// marshals to and from its string form.
+type VersionRelease struct {
+	version string
+	release uint
+}
+
+// NewVersionRelease returns the VersionRelease for version and release.
+func NewVersionRelease(version semver.Version, release uint) VersionRelease {
+	return VersionRelease{version: version.String(), release: release}
+}
+
+// ParseVersionRelease parses a string of the form "<version>-<release>".
+// The release is the part after the last hyphen, so "1.0.0-rc.1-2" is
+// release 2 of version 1.0.0-rc.1.
+func ParseVersionRelease(s string) (VersionRelease, error) {
+	i := strings.LastIndex(s, "-")
+	if i < 0 {
+		return VersionRelease{}, fmt.Errorf("invalid version-release %q: missing release", s)
+	}
+	version, err := semver.Parse(s[:i])
+	if err != nil {
+		return VersionRelease{}, fmt.Errorf("invalid version-release %q: %v", s, err)
+	}
+	release, err := strconv.ParseUint(s[i+1:], 10, 0)
+	if err != nil {
+		return VersionRelease{}, fmt.Errorf("invalid version-release %q: invalid release %q", s, s[i+1:])
+	}
+	return NewVersionRelease(version, uint(release)), nil
+}
+
+func (vr VersionRelease) Version() semver.Version {
+	// The version was valid when vr was created.
+	v, _ := semver.Parse(vr.version)
+	return v
+}
+
+func (vr VersionRelease) Release() uint {
+	return vr.release
+}
+
+func (vr VersionRelease) String() string {
+	if vr.version == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-%d", vr.version, vr.release)
+}
+
+// Compare returns -1, 0 or 1 if vr is lower than, equal to or higher than
+// o, comparing versions by semver precedence and then releases.
+func (vr VersionRelease) Compare(o VersionRelease) int {
+	if c := vr.Version().Compare(o.Version()); c != 0 {
+		return c
+	}
+	switch {
+	case vr.release < o.release:
+		return -1
+	case vr.release > o.release:
+		return 1
+	}
+	return 0
+}
+
+func (vr VersionRelease) MarshalText() ([]byte, error) {
+	return []byte(vr.String()), nil
+}
+
+func (vr *VersionRelease) UnmarshalText(text []byte) error {
+	parsed, err := ParseVersionRelease(string(text))
+	if err != nil {
+		return err
+	}
+	*vr = parsed
+	return nil
+}
+
+// VersionRelease returns the bundle's version and release.
+func (bm BundleMetadata) VersionRelease() VersionRelease {
+	return NewVersionRelease(bm.Version, bm.Release)
+}
+
+// sortDescending sorts vrs from highest to lowest.
+func sortDescending(vrs []VersionRelease) {
+	sort.Slice(vrs, func(i, j int) bool { return vrs[i].Compare(vrs[j]) > 0 })
+}
+
+func bundleLess(a, b Bundle) bool {
+	return a.Metadata.VersionRelease().Compare(b.Metadata.VersionRelease()) < 0
+}
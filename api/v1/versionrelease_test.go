@@ -0,0 +1,115 @@
+package v1
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVersionRelease(t *testing.T) {
+	for _, tc := range []struct {
+		in          string
+		wantVersion string
+		wantRelease uint
+		wantString  string
+		wantErr     string
+	}{
+		{in: "0.10.0-2", wantVersion: "0.10.0", wantRelease: 2, wantString: "0.10.0-2"},
+		{in: "1.0.0-0", wantVersion: "1.0.0", wantRelease: 0, wantString: "1.0.0-0"},
+		{in: "1.0.0-rc.1-2", wantVersion: "1.0.0-rc.1", wantRelease: 2, wantString: "1.0.0-rc.1-2"},
+		{in: "1.0.0+build.5-3", wantVersion: "1.0.0+build.5", wantRelease: 3, wantString: "1.0.0+build.5-3"},
+		{in: "1.0.0-007", wantVersion: "1.0.0", wantRelease: 7, wantString: "1.0.0-7"},
+		{in: "", wantErr: "missing release"},
+		{in: "1.0.0", wantErr: "missing release"},
+		{in: "1.0.0-rc.1", wantErr: `invalid release "rc.1"`},
+		{in: "1.0.0-", wantErr: `invalid release ""`},
+		{in: "1.0.0--1", wantErr: `invalid version-release "1.0.0--1"`},
+		{in: "1.0-1", wantErr: `invalid version-release "1.0-1"`},
+		{in: "v1.0.0-1", wantErr: `invalid version-release "v1.0.0-1"`},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			vr, err := ParseVersionRelease(tc.in)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := vr.Version().String(); got != tc.wantVersion {
+				t.Errorf("expected version %q, got %q", tc.wantVersion, got)
+			}
+			if got := vr.Release(); got != tc.wantRelease {
+				t.Errorf("expected release %d, got %d", tc.wantRelease, got)
+			}
+			if got := vr.String(); got != tc.wantString {
+				t.Errorf("expected string %q, got %q", tc.wantString, got)
+			}
+		})
+	}
+}
+
+func TestVersionReleaseCompare(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.0.0-0", b: "1.0.0-0", want: 0},
+		{a: "1.0.0-1", b: "1.0.0-0", want: 1},
+		{a: "1.0.0-0", b: "1.0.0-1", want: -1},
+		{a: "1.0.0-10", b: "1.0.0-9", want: 1},
+		{a: "1.0.1-0", b: "1.0.0-9", want: 1},
+		{a: "0.10.0-0", b: "0.9.0-5", want: 1},
+		{a: "1.0.0-rc.1-5", b: "1.0.0-0", want: -1},
+		{a: "1.0.0-rc.2-0", b: "1.0.0-rc.1-9", want: 1},
+		{a: "1.0.0+a-1", b: "1.0.0+b-1", want: 0},
+		{a: "1.0.0+a-2", b: "1.0.0+b-1", want: 1},
+	} {
+		t.Run(tc.a+" vs "+tc.b, func(t *testing.T) {
+			a, b := mustVR(tc.a), mustVR(tc.b)
+			if got := a.Compare(b); got != tc.want {
+				t.Errorf("expected %d, got %d", tc.want, got)
+			}
+			if got := b.Compare(a); got != -tc.want {
+				t.Errorf("expected reversed comparison %d, got %d", -tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSortDescending(t *testing.T) {
+	got := vrs("0.9.0-1", "1.0.0-rc.1-0", "1.0.0-2", "0.10.0-0", "1.0.0-10", "0.9.0-0")
+	sortDescending(got)
+	want := vrs("1.0.0-10", "1.0.0-2", "1.0.0-rc.1-0", "0.10.0-0", "0.9.0-1", "0.9.0-0")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestVersionReleaseJSON(t *testing.T) {
+	in := map[VersionRelease][]VersionRelease{
+		mustVR("1.0.0-rc.1-2"): vrs("0.9.0-0", "0.9.0-1"),
+	}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"1.0.0-rc.1-2":["0.9.0-0","0.9.0-1"]}`; string(data) != want {
+		t.Errorf("expected %s, got %s", want, data)
+	}
+	var out map[VersionRelease][]VersionRelease
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("expected %v, got %v", in, out)
+	}
+
+	var vr VersionRelease
+	if err := json.Unmarshal([]byte(`"1.0.0"`), &vr); err == nil {
+		t.Errorf("expected error unmarshaling a version without a release")
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	pkg "github.com/joelanford/olm-oci/api/v1"
 	"github.com/joelanford/olm-oci/pkg/client"
+	"github.com/joelanford/olm-oci/pkg/progress"
 	"github.com/joelanford/olm-oci/pkg/tar"
 )
 
@@ -35,7 +36,7 @@ func main() {
 }
 
 func run(ctx context.Context, bundleDir, outputFile string) error {
-	b, err := pkg.LoadBundle(bundleDir)
+	b, err := pkg.LoadBundle(bundleDir, pkg.WithWarningHandler(func(msg string) { log.Printf("warning: %s", msg) }))
 	if err != nil {
 		return fmt.Errorf("load bundle: %v", err)
 	}
@@ -55,10 +56,14 @@ func run(ctx context.Context, bundleDir, outputFile string) error {
 		return fmt.Errorf("create local bundle store: %v", err)
 	}
 
-	desc, err := client.Push(ctx, b, store)
+	reporter := progress.NewTerminalReporter(os.Stdout)
+	desc, err := client.Push(ctx, b, store, reporter)
 	if err != nil {
 		return fmt.Errorf("build bundle: %v", err)
 	}
+	if err := reporter.Close(); err != nil {
+		return err
+	}
 	if err := store.Tag(ctx, desc, "bundle"); err != nil {
 		return fmt.Errorf("tag bundle: %v", err)
 	}
@@ -19,6 +19,7 @@ import (
 	"github.com/joelanford/olm-oci/pkg/client"
 	"github.com/joelanford/olm-oci/pkg/fetch"
 	"github.com/joelanford/olm-oci/pkg/inspect"
+	"github.com/joelanford/olm-oci/pkg/progress"
 	"github.com/joelanford/olm-oci/pkg/tar"
 )
 
@@ -102,7 +103,7 @@ func run(ctx context.Context, bundleDir, outputFile string) error {
 			return err
 		}
 
-		tag := fmt.Sprintf("%s-%s-%d", bundle.Metadata.Package, bundle.Metadata.Version, bundle.Metadata.Release)
+		tag := fmt.Sprintf("%s-%s", bundle.Metadata.Package, bundle.Metadata.VersionRelease())
 		if err := catalogStore.Tag(ctx, desc, tag); err != nil {
 			return err
 		}
@@ -127,7 +128,7 @@ func run(ctx context.Context, bundleDir, outputFile string) error {
 				Bundles: bundles,
 			}},
 		}
-		packageDesc, err := client.Push(ctx, p, catalogStore)
+		packageDesc, err := client.Push(ctx, p, catalogStore, progress.Discard)
 		if err != nil {
 			return err
 		}
@@ -137,7 +138,7 @@ func run(ctx context.Context, bundleDir, outputFile string) error {
 		packages = append(packages, p)
 	}
 
-	catalogDesc, err := client.Push(ctx, &pkg.Catalog{Packages: packages}, catalogStore)
+	catalogDesc, err := client.Push(ctx, &pkg.Catalog{Packages: packages}, catalogStore, progress.Discard)
 	if err != nil {
 		return err
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"oras.land/oras-go/v2/content/oci"
@@ -15,22 +16,28 @@ import (
 )
 
 func NewBuildBundleCommand() *cobra.Command {
+	var (
+		generateProperties    bool
+		annotationPassthrough []string
+	)
 	cmd := &cobra.Command{
-		Use:   "bundle <bundleDir> <outputFile>",
+		Use:   "bundle <bundleDir|chart.tgz|oci://chartRef> <outputFile>",
 		Short: "Build OLM OCI bundle",
 		Run: func(cmd *cobra.Command, args []string) {
 			bundleDir := args[0]
 			outputFile := args[1]
-			if err := runBuildBundle(cmd.Context(), bundleDir, outputFile); err != nil {
+			if err := runBuildBundle(cmd.Context(), bundleDir, outputFile, loadBundleOptions(generateProperties, annotationPassthrough)...); err != nil {
 				log.Fatal(err)
 			}
 		},
 	}
+	cmd.Flags().BoolVar(&generateProperties, "generate-properties", false, "derive properties from bundle content and merge them with declared properties")
+	cmd.Flags().StringArrayVar(&annotationPassthrough, "pass-through-annotation", nil, "bundle annotation key, or key prefix ending in \"*\", to copy from metadata/annotations.yaml to bundle manifests (can be repeated)")
 	return cmd
 }
 
-func runBuildBundle(ctx context.Context, bundleDir, outputFile string) error {
-	b, err := pkg.LoadBundle(bundleDir)
+func runBuildBundle(ctx context.Context, bundleDir, outputFile string, opts ...pkg.LoadBundleOption) error {
+	b, err := loadBundle(ctx, bundleDir, opts...)
 	if err != nil {
 		return fmt.Errorf("load bundle: %v", err)
 	}
@@ -49,10 +56,17 @@ func runBuildBundle(ctx context.Context, bundleDir, outputFile string) error {
 	if err != nil {
 		return fmt.Errorf("create local bundle store: %v", err)
 	}
-	desc, err := client.Push(ctx, b, store)
+	reporter, err := newProgressReporter()
+	if err != nil {
+		return err
+	}
+	desc, err := client.Push(ctx, b, store, reporter)
 	if err != nil {
 		return fmt.Errorf("build bundle: %v", err)
 	}
+	if err := reporter.Close(); err != nil {
+		return err
+	}
 	if err := store.Tag(ctx, desc, "bundle"); err != nil {
 		return fmt.Errorf("tag bundle: %v", err)
 	}
@@ -68,3 +82,25 @@ func runBuildBundle(ctx context.Context, bundleDir, outputFile string) error {
 	fmt.Printf("Tag: %s:bundle\n", outputFile)
 	return nil
 }
+
+// loadBundle loads a bundle from a directory or chart archive, or from a Helm
+// chart in a registry if src has an "oci://" prefix.
+func loadBundle(ctx context.Context, src string, opts ...pkg.LoadBundleOption) (*pkg.Bundle, error) {
+	if strings.HasPrefix(src, "oci://") {
+		return pkg.LoadHelmBundleFromRegistry(ctx, src, opts...)
+	}
+	return pkg.LoadBundle(src, opts...)
+}
+
+func loadBundleOptions(generateProperties bool, annotationPassthrough []string) []pkg.LoadBundleOption {
+	opts := []pkg.LoadBundleOption{
+		pkg.WithWarningHandler(func(msg string) { log.Printf("warning: %s", msg) }),
+	}
+	if generateProperties {
+		opts = append(opts, pkg.WithGeneratedProperties())
+	}
+	if len(annotationPassthrough) > 0 {
+		opts = append(opts, pkg.WithAnnotationPassthrough(annotationPassthrough...))
+	}
+	return opts
+}
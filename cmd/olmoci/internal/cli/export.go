@@ -0,0 +1,17 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewExportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export OLM OCI artifacts as legacy OLM images.",
+	}
+	cmd.AddCommand(
+		NewExportBundleImageCommand(),
+		NewExportFBCImageCommand(),
+	)
+	return cmd
+}
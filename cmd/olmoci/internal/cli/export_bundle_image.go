@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/spf13/cobra"
+
+	pkg "github.com/joelanford/olm-oci/api/v1"
+	"github.com/joelanford/olm-oci/pkg/client"
+	"github.com/joelanford/olm-oci/pkg/fetch"
+)
+
+func NewExportBundleImageCommand() *cobra.Command {
+	var (
+		channels       []string
+		defaultChannel string
+	)
+	cmd := &cobra.Command{
+		Use:   "bundle-image <bundleRef> <imageRef>",
+		Short: "Export an OLM OCI bundle as a registry+v1 or plain+v0 bundle image.",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			bundleRef := args[0]
+			imageRef := args[1]
+
+			var opts []pkg.ExportBundleImageOption
+			if len(channels) > 0 || defaultChannel != "" {
+				opts = append(opts, pkg.WithBundleChannels(channels, defaultChannel))
+			}
+			if err := runExportBundleImage(cmd.Context(), bundleRef, imageRef, opts...); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().StringSliceVar(&channels, "channels", nil, "channels to record in the bundle image (defaults to the bundle's legacy channels)")
+	cmd.Flags().StringVar(&defaultChannel, "default-channel", "", "default channel to record in the bundle image (defaults to the bundle's legacy default channel or the first channel)")
+	return cmd
+}
+
+func runExportBundleImage(ctx context.Context, bundleRef, imageRef string, opts ...pkg.ExportBundleImageOption) error {
+	ref, err := reference.ParseNamed(imageRef)
+	if err != nil {
+		return fmt.Errorf("parse image reference: %v", err)
+	}
+
+	reporter, err := newProgressReporter()
+	if err != nil {
+		return err
+	}
+	var b pkg.Bundle
+	if _, err := client.NewClient(client.WithProgressReporter(reporter)).Pull(ctx, bundleRef, fetch.Into(&b)); err != nil {
+		return fmt.Errorf("pull bundle: %v", err)
+	}
+	if err := reporter.Close(); err != nil {
+		return err
+	}
+	if msg := b.DeprecationWarning(); msg != "" {
+		log.Printf("warning: %s", msg)
+	}
+
+	desc, err := pkg.ExportBundleImage(ctx, b, imageRef, opts...)
+	if err != nil {
+		return fmt.Errorf("export bundle image: %v", err)
+	}
+	fmt.Printf("Digest: %s@%s\n", ref.Name(), desc.Digest.String())
+	if _, ok := ref.(reference.Tagged); ok {
+		fmt.Printf("Tag:    %s\n", ref.String())
+	}
+	return nil
+}
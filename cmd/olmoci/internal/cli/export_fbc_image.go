@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/spf13/cobra"
+
+	pkg "github.com/joelanford/olm-oci/api/v1"
+	"github.com/joelanford/olm-oci/pkg/client"
+	"github.com/joelanford/olm-oci/pkg/fetch"
+)
+
+func NewExportFBCImageCommand() *cobra.Command {
+	var bundleImageRepo string
+	cmd := &cobra.Command{
+		Use:   "fbc-image <catalogRef> <imageRef>",
+		Short: "Export an OLM OCI catalog as a file-based catalog image.",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			catalogRef := args[0]
+			imageRef := args[1]
+
+			var opts []pkg.ExportFBCImageOption
+			if bundleImageRepo != "" {
+				opts = append(opts, pkg.WithBundleImageRepository(bundleImageRepo))
+			}
+			if err := runExportFBCImage(cmd.Context(), catalogRef, imageRef, opts...); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&bundleImageRepo, "bundle-image-repo", "", "export bundles as bundle images to this repository and reference them instead of the bundle artifacts")
+	return cmd
+}
+
+func runExportFBCImage(ctx context.Context, catalogRef, imageRef string, opts ...pkg.ExportFBCImageOption) error {
+	srcRef, err := reference.ParseNamed(catalogRef)
+	if err != nil {
+		return fmt.Errorf("parse catalog reference: %v", err)
+	}
+	ref, err := reference.ParseNamed(imageRef)
+	if err != nil {
+		return fmt.Errorf("parse image reference: %v", err)
+	}
+
+	reporter, err := newProgressReporter()
+	if err != nil {
+		return err
+	}
+	var c pkg.Catalog
+	if _, err := client.NewClient(client.WithProgressReporter(reporter)).Pull(ctx, catalogRef, fetch.Into(&c)); err != nil {
+		return fmt.Errorf("pull catalog: %v", err)
+	}
+	if err := reporter.Close(); err != nil {
+		return err
+	}
+	for _, msg := range c.DeprecationWarnings() {
+		log.Printf("warning: %s", msg)
+	}
+
+	desc, err := pkg.ExportFBCImage(ctx, c, srcRef.Name(), imageRef, opts...)
+	if err != nil {
+		return fmt.Errorf("export catalog image: %v", err)
+	}
+	fmt.Printf("Digest: %s@%s\n", ref.Name(), desc.Digest.String())
+	if _, ok := ref.(reference.Tagged); ok {
+		fmt.Printf("Tag:    %s\n", ref.String())
+	}
+	return nil
+}
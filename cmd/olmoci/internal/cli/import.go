@@ -0,0 +1,17 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewImportCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import legacy OLM images as OLM OCI artifacts.",
+	}
+	cmd.AddCommand(
+		NewImportBundleImageCommand(),
+		NewImportIndexCommand(),
+	)
+	return cmd
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/spf13/cobra"
+
+	pkg "github.com/joelanford/olm-oci/api/v1"
+	"github.com/joelanford/olm-oci/pkg/client"
+)
+
+func NewImportIndexCommand() *cobra.Command {
+	var (
+		generateProperties    bool
+		annotationPassthrough []string
+		annotations           []string
+		catalogMetadata       string
+		skipUnloadable        bool
+	)
+	cmd := &cobra.Command{
+		Use:   "index <indexImage> <target>",
+		Short: "Import a SQLite or file-based index image and push it as an OLM OCI catalog artifact.",
+		Long: `Import a SQLite or file-based index image and push it as an OLM OCI catalog artifact.
+
+Every bundle image referenced by the index is imported. SQLite indexes can
+only be imported by builds with the sqlite build tag ("go build -tags sqlite"
+or "make sqlite"); the default build rejects them.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			indexRef := args[0]
+			targetRef := args[1]
+
+			manifestAnnotations, err := parseAnnotations(annotations)
+			if err != nil {
+				log.Fatal(err)
+			}
+			opts := loadBundleOptions(generateProperties, annotationPassthrough)
+			if skipUnloadable {
+				opts = append(opts, pkg.WithSkipUnloadableBundles())
+			}
+			if err := runImportIndex(cmd.Context(), indexRef, targetRef, catalogMetadata, manifestAnnotations, opts...); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&generateProperties, "generate-properties", false, "derive properties from bundle content and merge them with declared properties")
+	cmd.Flags().StringArrayVar(&annotationPassthrough, "pass-through-annotation", nil, "bundle annotation key, or key prefix ending in \"*\", to copy from metadata/annotations.yaml to bundle manifests (can be repeated)")
+	cmd.Flags().StringArrayVar(&annotations, "annotation", nil, "key=value annotation to add to the pushed root manifest, such as org.opencontainers.image.source=<url> (can be repeated)")
+	cmd.Flags().BoolVar(&skipUnloadable, "skip-unloadable-bundles", false, "leave out bundles whose images cannot be imported instead of failing")
+	cmd.Flags().StringVar(&catalogMetadata, "catalog-metadata", "", "catalog.yaml file with metadata and icon to attach to the imported catalog")
+	return cmd
+}
+
+func runImportIndex(ctx context.Context, indexRef, targetRef, catalogMetadata string, annotations map[string]string, opts ...pkg.LoadBundleOption) error {
+	ref, err := reference.ParseNamed(targetRef)
+	if err != nil {
+		return fmt.Errorf("parse target reference: %v", err)
+	}
+	c, err := pkg.LoadIndexImage(ctx, indexRef, opts...)
+	if err != nil {
+		return fmt.Errorf("load index image: %v", err)
+	}
+	if catalogMetadata != "" {
+		if c.Metadata, err = pkg.LoadCatalogMetadata(catalogMetadata); err != nil {
+			return fmt.Errorf("load catalog metadata: %v", err)
+		}
+		icon, err := pkg.LoadCatalogIcon(catalogMetadata)
+		if err != nil {
+			return fmt.Errorf("load catalog icon: %v", err)
+		}
+		if icon != nil {
+			c.Icon = icon
+		}
+	}
+
+	reporter, err := newProgressReporter()
+	if err != nil {
+		return err
+	}
+	cl := client.NewClient(client.WithProgressReporter(reporter), client.WithManifestAnnotations(annotations))
+	desc, err := cl.Push(ctx, c, targetRef)
+	if err != nil {
+		return fmt.Errorf("push catalog: %v", err)
+	}
+	if err := reporter.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Digest: %s@%s\n", ref.Name(), desc.Digest.String())
+	if _, ok := ref.(reference.Tagged); ok {
+		fmt.Printf("Tag:    %s\n", ref.String())
+	}
+	return nil
+}
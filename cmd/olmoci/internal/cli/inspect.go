@@ -3,76 +3,118 @@ package cli
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/adrg/xdg"
 	"github.com/containers/image/v5/docker/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/cobra"
+	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/oci"
 
-	"github.com/joelanford/olm-oci/pkg/client"
+	"github.com/joelanford/olm-oci/pkg/cache"
 	"github.com/joelanford/olm-oci/pkg/inspect"
 	"github.com/joelanford/olm-oci/pkg/remote"
 )
 
 func NewInspectCommand() *cobra.Command {
-	return &cobra.Command{
+	var (
+		output  string
+		noCache bool
+		opts    inspect.Options
+	)
+	cmd := &cobra.Command{
 		Use:   "inspect <ociRef>",
 		Short: "Recursively inspect an OCI reference (fetching from the remote repository as necessary)",
 		Args:  cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
-			ref, err := reference.Parse(args[0])
+			write, err := inspectWriter(output)
 			if err != nil {
 				log.Fatal(err)
 			}
-			refNamed, ok := ref.(reference.Named)
-			if ok {
-				fileName := refNamed.Name()
-				if _, err := os.Stat(fileName); err == nil {
-					store, err := oci.NewFromTar(cmd.Context(), fileName)
-					if err != nil {
-						log.Fatal(err)
-					}
-					td, err := remote.TagOrDigest(ref)
-					if err != nil {
-						log.Fatal(err)
-					}
-					desc, err := store.Resolve(cmd.Context(), td)
-					if err != nil {
-						log.Fatal(err)
-					}
-					if err := inspect.Inspect(cmd.Context(), store, desc); err != nil {
-						log.Fatal(err)
-					}
-					return
+			if err := runInspect(cmd.Context(), args[0], noCache, opts, write); err != nil {
+				if errors.Is(err, context.Canceled) {
+					os.Exit(1)
 				}
-
+				log.Fatal(err)
 			}
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "tree", "output format (one of: tree, json, yaml, summary)")
+	cmd.Flags().IntVar(&opts.Depth, "depth", 0, "maximum depth below the root to inspect (0 for unlimited)")
+	cmd.Flags().StringSliceVar(&opts.MediaTypes, "media-type", nil, "only include blobs and artifacts with these media types or artifact types")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "fetch directly from the remote repository without writing to the local cache")
+	return cmd
+}
 
-			src, _, desc, err := remote.ResolveNameAndReference(cmd.Context(), args[0])
+func inspectWriter(output string) (func(io.Writer, *inspect.Node) error, error) {
+	switch output {
+	case "tree":
+		return inspect.WriteTree, nil
+	case "json":
+		return inspect.WriteJSON, nil
+	case "yaml":
+		return inspect.WriteYAML, nil
+	case "summary":
+		return inspect.WriteSummary, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", output)
+}
+
+func runInspect(ctx context.Context, refStr string, noCache bool, opts inspect.Options, write func(io.Writer, *inspect.Node) error) error {
+	src, desc, err := resolveInspectSource(ctx, refStr, noCache)
+	if err != nil {
+		return err
+	}
+	n, err := inspect.Inspect(ctx, src, desc, opts)
+	if err != nil {
+		return err
+	}
+	return write(os.Stdout, n)
+}
+
+func resolveInspectSource(ctx context.Context, refStr string, noCache bool) (content.ReadOnlyStorage, ocispec.Descriptor, error) {
+	ref, err := reference.Parse(refStr)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, err
+	}
+	if refNamed, ok := ref.(reference.Named); ok {
+		fileName := refNamed.Name()
+		if _, err := os.Stat(fileName); err == nil {
+			store, err := oci.NewFromTar(ctx, fileName)
 			if err != nil {
-				log.Fatal(err)
+				return nil, ocispec.Descriptor{}, err
 			}
-
-			storeDir := filepath.Join(xdg.CacheHome, "olm-oci", "store")
-			dst, err := oci.NewWithContext(cmd.Context(), storeDir)
+			td, err := remote.TagOrDigest(ref)
 			if err != nil {
-				log.Fatal(err)
+				return nil, ocispec.Descriptor{}, err
 			}
-
-			if err := client.CopyGraphWithProgress(cmd.Context(), src, dst, *desc); err != nil {
-				log.Fatalf("copying to local store: %v", err)
+			desc, err := store.Resolve(ctx, td)
+			if err != nil {
+				return nil, ocispec.Descriptor{}, err
 			}
+			return store, desc, nil
+		}
+	}
 
-			fileSrc := dst
-			if err := inspect.Inspect(cmd.Context(), fileSrc, *desc); err != nil {
-				if errors.Is(err, context.Canceled) {
-					os.Exit(1)
-				}
-				log.Fatal(err)
-			}
-		},
+	src, _, desc, err := remote.ResolveNameAndReference(ctx, refStr)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, err
+	}
+	if noCache {
+		return src, *desc, nil
+	}
+
+	storeDir := filepath.Join(xdg.CacheHome, "olm-oci", "store")
+	dst, err := oci.NewWithContext(ctx, storeDir)
+	if err != nil {
+		return nil, ocispec.Descriptor{}, err
 	}
+	return cache.NewStore(src, dst, cache.WithWarningHandler(func(msg string) {
+		log.Printf("warning: %s", msg)
+	})), *desc, nil
 }
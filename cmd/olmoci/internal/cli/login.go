@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"oras.land/oras-go/v2/registry/remote/auth"
+
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+func NewLoginCommand() *cobra.Command {
+	var (
+		username      string
+		password      string
+		passwordStdin bool
+		identityToken string
+	)
+	cmd := &cobra.Command{
+		Use:   "login <registry>",
+		Short: "Log in to a registry and store the credentials",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if passwordStdin {
+				data, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					log.Fatalf("read password from stdin: %v", err)
+				}
+				password = strings.TrimRight(string(data), "\r\n")
+			}
+			cred := auth.Credential{
+				Username:     username,
+				Password:     password,
+				RefreshToken: identityToken,
+			}
+			if err := runLogin(cmd.Context(), args[0], cred); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&username, "username", "u", "", "registry username")
+	cmd.Flags().StringVarP(&password, "password", "p", "", "registry password (prefer --password-stdin)")
+	cmd.Flags().BoolVar(&passwordStdin, "password-stdin", false, "read the registry password from stdin")
+	cmd.Flags().StringVar(&identityToken, "identity-token", "", "identity (refresh) token to use instead of a password")
+	cmd.MarkFlagsMutuallyExclusive("password", "password-stdin", "identity-token")
+	return cmd
+}
+
+func runLogin(ctx context.Context, registry string, cred auth.Credential) error {
+	if cred.RefreshToken == "" {
+		if cred.Username == "" {
+			return fmt.Errorf("username is required")
+		}
+		if cred.Password == "" {
+			return fmt.Errorf("password is required")
+		}
+	}
+	if err := remote.Login(ctx, registry, cred); err != nil {
+		return fmt.Errorf("login: %v", err)
+	}
+	fmt.Printf("Login succeeded: %s\n", registry)
+	return nil
+}
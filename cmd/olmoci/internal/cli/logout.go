@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+func NewLogoutCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "logout <registry>",
+		Short: "Remove stored credentials for a registry",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := remote.Logout(args[0]); err != nil {
+				log.Fatalf("logout: %v", err)
+			}
+			fmt.Printf("Removed login credentials for %s\n", args[0])
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joelanford/olm-oci/pkg/progress"
+)
+
+var (
+	progressMode  string
+	progressQuiet bool
+)
+
+// AddProgressFlags adds flags to cmd (and all of its subcommands) that
+// select how transfer progress is reported.
+func AddProgressFlags(cmd *cobra.Command) {
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&progressMode, "progress", "tty", "how to report transfer progress to stderr (one of: tty, plain, json, quiet)")
+	flags.BoolVarP(&progressQuiet, "quiet", "q", false, "do not report transfer progress (same as --progress=quiet)")
+}
+
+func newProgressReporter() (progress.Reporter, error) {
+	mode := progressMode
+	if progressQuiet {
+		mode = "quiet"
+	}
+	switch mode {
+	case "tty":
+		return progress.NewTerminalReporter(os.Stderr), nil
+	case "plain":
+		return progress.NewLogReporter(os.Stderr), nil
+	case "json":
+		return progress.NewJSONReporter(os.Stderr), nil
+	case "quiet":
+		return progress.Discard, nil
+	}
+	return nil, fmt.Errorf("unknown progress mode %q", mode)
+}
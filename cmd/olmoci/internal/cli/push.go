@@ -1,6 +1,9 @@
 package cli
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/spf13/cobra"
 )
 
@@ -11,8 +14,25 @@ func NewPushCommand() *cobra.Command {
 	}
 	cmd.AddCommand(
 		NewPushArchiveCommand(),
+		NewPushCatalogCommand(),
 		NewPushPackageCommand(),
 		NewPushBundleCommand(),
 	)
 	return cmd
 }
+
+// parseAnnotations parses annotations given as "key=value" flag values.
+func parseAnnotations(values []string) (map[string]string, error) {
+	var annotations map[string]string
+	for _, kv := range values {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid annotation %q: expected key=value", kv)
+		}
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[k] = v
+	}
+	return annotations, nil
+}
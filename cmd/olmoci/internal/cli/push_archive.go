@@ -6,7 +6,7 @@ import (
 	"log"
 	"os"
 	"runtime"
-	"sync"
+	"strings"
 
 	"github.com/containers/image/v5/docker/reference"
 	"github.com/opencontainers/go-digest"
@@ -14,6 +14,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	"oras.land/oras-go/v2/content/oci"
 	"oras.land/oras-go/v2/registry"
+	orasremote "oras.land/oras-go/v2/registry/remote"
 
 	"github.com/joelanford/olm-oci/pkg/client"
 	"github.com/joelanford/olm-oci/pkg/remote"
@@ -72,14 +73,22 @@ func runPushArchive(ctx context.Context, archiveRefStr, targetRefStr string) err
 		return fmt.Errorf("create target repository client: %v", err)
 	}
 
+	reporter, err := newProgressReporter()
+	if err != nil {
+		return err
+	}
+
 	if archiveTagDigErr == nil {
 		desc, err := srcRepo.Resolve(ctx, archiveTagOrDig)
 		if err != nil {
 			return fmt.Errorf("resolve archive reference: %v", err)
 		}
-		if err := client.CopyGraphWithProgress(ctx, srcRepo, targetRepo, desc); err != nil {
+		if err := client.CopyGraphWithProgress(ctx, srcRepo, targetRepo, desc, reporter); err != nil {
 			return fmt.Errorf("push: %v", err)
 		}
+		if err := reporter.Close(); err != nil {
+			return err
+		}
 		if tag, ok := targetRef.(reference.Tagged); ok {
 			if err := targetRepo.Tag(ctx, desc, tag.Tag()); err != nil {
 				return fmt.Errorf("tag: %v", err)
@@ -93,36 +102,62 @@ func runPushArchive(ctx context.Context, archiveRefStr, targetRefStr string) err
 			return fmt.Errorf("get tags from archive: %v", err)
 		}
 
-		eg, egCtx := errgroup.WithContext(ctx)
+		// Push every tag even if some of them fail, so that a single failed
+		// tag does not cancel the others. Re-running the push skips content
+		// that already made it to the target repository.
+		var eg errgroup.Group
 		eg.SetLimit(runtime.NumCPU())
-		tagMap := map[string]digest.Digest{}
-		var tmm sync.Mutex
-		for _, t := range tags {
-			t := t
+		results := make([]pushTagResult, len(tags))
+		for i, t := range tags {
+			i, t := i, t
 			eg.Go(func() error {
-				desc, err := srcRepo.Resolve(egCtx, t)
-				if err != nil {
-					return fmt.Errorf("resolve archive tag: %v", err)
-				}
-				if err := client.CopyGraphWithProgress(egCtx, srcRepo, targetRepo, desc); err != nil {
-					return fmt.Errorf("push: %v", err)
-				}
-				if err := targetRepo.Tag(egCtx, desc, t); err != nil {
-					return fmt.Errorf("tag: %v", err)
-				}
-				tmm.Lock()
-				defer tmm.Unlock()
-				tagMap[t] = desc.Digest
+				results[i] = pushTag(ctx, srcRepo, targetRepo, t, reporter)
 				return nil
 			})
 		}
-		if err := eg.Wait(); err != nil {
-			return fmt.Errorf("push tags: %v", err)
+		_ = eg.Wait()
+		if err := reporter.Close(); err != nil {
+			return err
 		}
 
-		for _, t := range tags {
-			fmt.Printf("Successfully pushed %s (%s)\n", fmt.Sprintf("%s:%s", targetRefNamed.Name(), t), tagMap[t].String())
+		var failed []string
+		for _, r := range results {
+			ref := fmt.Sprintf("%s:%s", targetRefNamed.Name(), r.tag)
+			if r.err != nil {
+				fmt.Printf("Failed to push %s: %v\n", ref, r.err)
+				failed = append(failed, r.tag)
+				continue
+			}
+			fmt.Printf("Successfully pushed %s (%s)\n", ref, r.digest.String())
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to push %d of %d tags (%s); re-run the push to retry them", len(failed), len(tags), strings.Join(failed, ", "))
 		}
 	}
 	return nil
 }
+
+type pushTagResult struct {
+	tag    string
+	digest digest.Digest
+	err    error
+}
+
+func pushTag(ctx context.Context, src *oci.ReadOnlyStore, dst *orasremote.Repository, tag string, reporter client.ProgressReporter) pushTagResult {
+	result := pushTagResult{tag: tag}
+	desc, err := src.Resolve(ctx, tag)
+	if err != nil {
+		result.err = fmt.Errorf("resolve archive tag: %v", err)
+		return result
+	}
+	if err := client.CopyGraphWithProgress(ctx, src, dst, desc, reporter); err != nil {
+		result.err = fmt.Errorf("push: %v", err)
+		return result
+	}
+	if err := dst.Tag(ctx, desc, tag); err != nil {
+		result.err = fmt.Errorf("tag: %v", err)
+		return result
+	}
+	result.digest = desc.Digest
+	return result
+}
@@ -5,47 +5,67 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/containers/image/v5/docker/reference"
 	"github.com/spf13/cobra"
 
 	pkg "github.com/joelanford/olm-oci/api/v1"
 	"github.com/joelanford/olm-oci/pkg/client"
-	"github.com/joelanford/olm-oci/pkg/remote"
 )
 
 func NewPushBundleCommand() *cobra.Command {
-	return &cobra.Command{
-		Use:   "bundle <bundleDir> <target>",
+	var (
+		generateProperties    bool
+		annotationPassthrough []string
+		annotations           []string
+	)
+	cmd := &cobra.Command{
+		Use:   "bundle <bundleDir|chart.tgz|oci://chartRef> <target>",
 		Short: "Push an OLM OCI bundle artifact to a registry.",
 		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			bundleDir := args[0]
 			targetRef := args[1]
 
-			if err := runPushBundle(cmd.Context(), bundleDir, targetRef); err != nil {
+			manifestAnnotations, err := parseAnnotations(annotations)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := runPushBundle(cmd.Context(), bundleDir, targetRef, manifestAnnotations, loadBundleOptions(generateProperties, annotationPassthrough)...); err != nil {
 				log.Fatal(err)
 			}
 		},
 	}
+	cmd.Flags().BoolVar(&generateProperties, "generate-properties", false, "derive properties from bundle content and merge them with declared properties")
+	cmd.Flags().StringArrayVar(&annotationPassthrough, "pass-through-annotation", nil, "bundle annotation key, or key prefix ending in \"*\", to copy from metadata/annotations.yaml to bundle manifests (can be repeated)")
+	cmd.Flags().StringArrayVar(&annotations, "annotation", nil, "key=value annotation to add to the pushed root manifest, such as org.opencontainers.image.source=<url> (can be repeated)")
+	return cmd
 }
 
-func runPushBundle(ctx context.Context, bundleDir, targetRef string) error {
-	repo, ref, err := remote.ParseNameAndReference(targetRef)
+func runPushBundle(ctx context.Context, bundleDir, targetRef string, annotations map[string]string, opts ...pkg.LoadBundleOption) error {
+	ref, err := reference.ParseNamed(targetRef)
 	if err != nil {
 		return fmt.Errorf("parse target reference: %v", err)
 	}
-	b, err := pkg.LoadBundle(bundleDir)
+	b, err := loadBundle(ctx, bundleDir, opts...)
 	if err != nil {
 		return fmt.Errorf("load bundle: %v", err)
 	}
 
-	desc, err := client.Push(ctx, b, repo)
+	reporter, err := newProgressReporter()
+	if err != nil {
+		return err
+	}
+	c := client.NewClient(client.WithProgressReporter(reporter), client.WithManifestAnnotations(annotations))
+	desc, err := c.Push(ctx, b, targetRef)
 	if err != nil {
 		return fmt.Errorf("push bundle: %v", err)
 	}
-	if err := repo.Tag(ctx, desc, ref.String()); err != nil {
-		return fmt.Errorf("tag bundle: %v", err)
+	if err := reporter.Close(); err != nil {
+		return err
 	}
 	fmt.Printf("Digest: %s@%s\n", ref.Name(), desc.Digest.String())
-	fmt.Printf("Tag:    %s\n", ref.String())
+	if _, ok := ref.(reference.Tagged); ok {
+		fmt.Printf("Tag:    %s\n", ref.String())
+	}
 	return nil
 }
@@ -0,0 +1,71 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/spf13/cobra"
+
+	pkg "github.com/joelanford/olm-oci/api/v1"
+	"github.com/joelanford/olm-oci/pkg/client"
+)
+
+func NewPushCatalogCommand() *cobra.Command {
+	var (
+		generateProperties    bool
+		annotationPassthrough []string
+		annotations           []string
+	)
+	cmd := &cobra.Command{
+		Use:   "catalog <catalogDir> <target>",
+		Short: "Push an OLM OCI catalog artifact to a registry.",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			catalogDir := args[0]
+			targetRef := args[1]
+
+			manifestAnnotations, err := parseAnnotations(annotations)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := runPushCatalog(cmd.Context(), catalogDir, targetRef, manifestAnnotations, loadBundleOptions(generateProperties, annotationPassthrough)...); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	cmd.Flags().BoolVar(&generateProperties, "generate-properties", false, "derive properties from bundle content and merge them with declared properties")
+	cmd.Flags().StringArrayVar(&annotationPassthrough, "pass-through-annotation", nil, "bundle annotation key, or key prefix ending in \"*\", to copy from metadata/annotations.yaml to bundle manifests (can be repeated)")
+	cmd.Flags().StringArrayVar(&annotations, "annotation", nil, "key=value annotation to add to the pushed root manifest, such as org.opencontainers.image.source=<url> (can be repeated)")
+	return cmd
+}
+
+func runPushCatalog(ctx context.Context, catalogDir, targetRef string, annotations map[string]string, opts ...pkg.LoadBundleOption) error {
+	ref, err := reference.ParseNamed(targetRef)
+	if err != nil {
+		return fmt.Errorf("parse target reference: %v", err)
+	}
+	cat, err := pkg.LoadCatalog(catalogDir, opts...)
+	if err != nil {
+		return fmt.Errorf("load catalog: %v", err)
+	}
+
+	reporter, err := newProgressReporter()
+	if err != nil {
+		return err
+	}
+	c := client.NewClient(client.WithProgressReporter(reporter), client.WithManifestAnnotations(annotations))
+	desc, err := c.Push(ctx, cat, targetRef)
+	if err != nil {
+		return fmt.Errorf("push catalog: %v", err)
+	}
+	if err := reporter.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Digest: %s@%s\n", ref.Name(), desc.Digest.String())
+	if _, ok := ref.(reference.Tagged); ok {
+		fmt.Printf("Tag:    %s\n", ref.String())
+	}
+	return nil
+}
@@ -5,15 +5,20 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/containers/image/v5/docker/reference"
 	"github.com/spf13/cobra"
 
 	pkg "github.com/joelanford/olm-oci/api/v1"
 	"github.com/joelanford/olm-oci/pkg/client"
-	"github.com/joelanford/olm-oci/pkg/remote"
 )
 
 func NewPushPackageCommand() *cobra.Command {
-	return &cobra.Command{
+	var (
+		generateProperties    bool
+		annotationPassthrough []string
+		annotations           []string
+	)
+	cmd := &cobra.Command{
 		Use:   "package <packageDir> <target>",
 		Short: "Push an OLM OCI package artifact to a registry.",
 		Args:  cobra.ExactArgs(2),
@@ -21,32 +26,46 @@ func NewPushPackageCommand() *cobra.Command {
 			packageDir := args[0]
 			targetRef := args[1]
 
-			if err := runPushPackage(cmd.Context(), packageDir, targetRef); err != nil {
+			manifestAnnotations, err := parseAnnotations(annotations)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := runPushPackage(cmd.Context(), packageDir, targetRef, manifestAnnotations, loadBundleOptions(generateProperties, annotationPassthrough)...); err != nil {
 				log.Fatal(err)
 			}
 		},
 	}
+	cmd.Flags().BoolVar(&generateProperties, "generate-properties", false, "derive properties from bundle content and merge them with declared properties")
+	cmd.Flags().StringArrayVar(&annotationPassthrough, "pass-through-annotation", nil, "bundle annotation key, or key prefix ending in \"*\", to copy from metadata/annotations.yaml to bundle manifests (can be repeated)")
+	cmd.Flags().StringArrayVar(&annotations, "annotation", nil, "key=value annotation to add to the pushed root manifest, such as org.opencontainers.image.source=<url> (can be repeated)")
+	return cmd
 }
 
-func runPushPackage(ctx context.Context, packageDir, targetRef string) error {
-	repo, ref, err := remote.ParseNameAndReference(targetRef)
+func runPushPackage(ctx context.Context, packageDir, targetRef string, annotations map[string]string, opts ...pkg.LoadBundleOption) error {
+	ref, err := reference.ParseNamed(targetRef)
 	if err != nil {
 		return fmt.Errorf("parse target reference: %v", err)
 	}
-
-	p, err := pkg.LoadPackage(packageDir)
+	p, err := pkg.LoadPackage(packageDir, opts...)
 	if err != nil {
 		return fmt.Errorf("load package: %v", err)
 	}
 
-	desc, err := client.Push(ctx, p, repo)
+	reporter, err := newProgressReporter()
+	if err != nil {
+		return err
+	}
+	c := client.NewClient(client.WithProgressReporter(reporter), client.WithManifestAnnotations(annotations))
+	desc, err := c.Push(ctx, p, targetRef)
 	if err != nil {
 		return fmt.Errorf("push package: %v", err)
 	}
-	if err := repo.Tag(ctx, desc, ref.String()); err != nil {
-		return fmt.Errorf("tag package: %v", err)
+	if err := reporter.Close(); err != nil {
+		return err
 	}
 	fmt.Printf("Digest: %s@%s\n", ref.Name(), desc.Digest.String())
-	fmt.Printf("Tag:    %s\n", ref.String())
+	if _, ok := ref.(reference.Tagged); ok {
+		fmt.Printf("Tag:    %s\n", ref.String())
+	}
 	return nil
 }
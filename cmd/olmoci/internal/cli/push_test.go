@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAnnotations(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		values  []string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "one", values: []string{"org.opencontainers.image.source=https://example.com/repo"}, want: map[string]string{"org.opencontainers.image.source": "https://example.com/repo"}},
+		{name: "several", values: []string{"a=1", "b=2"}, want: map[string]string{"a": "1", "b": "2"}},
+		{name: "empty value", values: []string{"a="}, want: map[string]string{"a": ""}},
+		{name: "value with equals sign", values: []string{"a=b=c"}, want: map[string]string{"a": "b=c"}},
+		{name: "last value wins", values: []string{"a=1", "a=2"}, want: map[string]string{"a": "2"}},
+		{name: "missing equals sign", values: []string{"a"}, wantErr: `invalid annotation "a": expected key=value`},
+		{name: "empty key", values: []string{"=1"}, wantErr: `invalid annotation "=1": expected key=value`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseAnnotations(tc.values)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
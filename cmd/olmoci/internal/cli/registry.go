@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+// AddRegistryFlags adds flags to cmd (and all of its subcommands) that
+// configure how remote registries are accessed, and loads the resulting
+// configuration before any subcommand runs.
+func AddRegistryFlags(cmd *cobra.Command) {
+	var (
+		configFile         string
+		rc                 remote.RegistryConfig
+		useRegistriesConf  bool
+		registriesConfPath string
+		retry              = remote.DefaultRetryPolicy
+		uploadChunkSize    int64
+	)
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&configFile, "registry-config", remote.DefaultConfigFile, "path to the per-registry configuration file")
+	flags.BoolVar(&rc.PlainHTTP, "plain-http", false, "access registries with HTTP instead of HTTPS")
+	flags.BoolVar(&rc.InsecureSkipTLSVerify, "insecure-skip-tls-verify", false, "skip verification of registry TLS certificates")
+	flags.StringVar(&rc.CAFile, "ca-file", "", "PEM bundle of additional certificate authorities to trust")
+	flags.StringVar(&rc.CertFile, "cert-file", "", "PEM client certificate to present to registries")
+	flags.StringVar(&rc.KeyFile, "key-file", "", "PEM key for the client certificate")
+	flags.BoolVar(&useRegistriesConf, "use-registries-conf", false, "apply mirrors, rewrites and blocked registries from containers registries.conf when pulling")
+	flags.IntVar(&retry.MaxRetries, "max-retries", retry.MaxRetries, "maximum number of times to retry a failed registry operation")
+	flags.DurationVar(&retry.MinBackoff.Duration, "retry-min-backoff", retry.MinBackoff.Duration, "wait before the first retry; doubles with each retry")
+	flags.DurationVar(&retry.MaxBackoff.Duration, "retry-max-backoff", retry.MaxBackoff.Duration, "maximum wait between retries")
+	flags.Int64Var(&uploadChunkSize, "upload-chunk-size", remote.DefaultChunkSize, "upload blobs at least this many bytes in resumable chunks of this size (0 to disable)")
+	flags.StringVar(&registriesConfPath, "registries-conf", "", "registries.conf file to use instead of the system and user files (implies --use-registries-conf)")
+
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		cfg, err := remote.LoadConfig(configFile)
+		if err != nil {
+			return fmt.Errorf("load registry config: %v", err)
+		}
+		cfg.Default = rc
+		if useRegistriesConf {
+			cfg.UseRegistriesConf = true
+		}
+		if registriesConfPath != "" {
+			cfg.RegistriesConfPath = registriesConfPath
+		}
+		if cmd.Flags().Changed("max-retries") {
+			cfg.Retry.MaxRetries = retry.MaxRetries
+		}
+		if cmd.Flags().Changed("retry-min-backoff") {
+			cfg.Retry.MinBackoff = retry.MinBackoff
+		}
+		if cmd.Flags().Changed("retry-max-backoff") {
+			cfg.Retry.MaxBackoff = retry.MaxBackoff
+		}
+		if cmd.Flags().Changed("upload-chunk-size") {
+			cfg.UploadChunkSize = uploadChunkSize
+		}
+		remote.DefaultConfig = cfg
+		return nil
+	}
+}
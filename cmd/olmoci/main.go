@@ -19,9 +19,15 @@ func main() {
 		Use:   "olmoci",
 		Short: "Operate on OLM OCI artifacts",
 	}
+	cli.AddRegistryFlags(&c)
+	cli.AddProgressFlags(&c)
 	c.AddCommand(
 		cli.NewBuildCommand(),
+		cli.NewExportCommand(),
+		cli.NewImportCommand(),
 		cli.NewInspectCommand(),
+		cli.NewLoginCommand(),
+		cli.NewLogoutCommand(),
 		cli.NewPushCommand(),
 		cli.NewSystemCommand(),
 	)
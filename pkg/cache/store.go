@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+)
+
+// NewStore returns a read-through cache in front of base. Content is fetched
+// from cache if it is present there. Otherwise, it is fetched from base and
+// written to cache as it is read, so that only the content a caller actually
+// fetches is ever transferred or stored.
+//
+// The cache is best-effort: content that cannot be fetched from or written
+// to cache is still read from base, and the cache error is reported to the
+// warning handler. Content that is not read to the end is not cached.
+func NewStore(base content.ReadOnlyStorage, cache content.Storage, opts ...Option) content.ReadOnlyStorage {
+	s := &Store{
+		base:  base,
+		cache: cache,
+		warn:  func(string) {},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithWarningHandler sets the function that is called with a message when
+// the cache cannot be used. Warnings are discarded by default.
+func WithWarningHandler(warn func(msg string)) Option {
+	return func(s *Store) {
+		s.warn = warn
+	}
+}
+
+type Store struct {
+	base  content.ReadOnlyStorage
+	cache content.Storage
+	warn  func(msg string)
+}
+
+func (s *Store) Exists(ctx context.Context, desc ocispec.Descriptor) (bool, error) {
+	if exists, err := s.cache.Exists(ctx, desc); err == nil && exists {
+		return true, nil
+	}
+	return s.base.Exists(ctx, desc)
+}
+
+func (s *Store) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	if exists, err := s.cache.Exists(ctx, desc); err == nil && exists {
+		rc, err := s.cache.Fetch(ctx, desc)
+		if err == nil {
+			return rc, nil
+		}
+		// The content is already cached, so it is only read from base.
+		s.warn(fmt.Sprintf("fetch %s from cache: %v", desc.Digest, err))
+		return s.base.Fetch(ctx, desc)
+	}
+
+	rc, err := s.base.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	pushed := make(chan error, 1)
+	go func() {
+		err := s.cache.Push(ctx, desc, pr)
+		pr.CloseWithError(err)
+		pushed <- err
+	}()
+	return &cachingReadCloser{
+		store:  s,
+		desc:   desc,
+		rc:     rc,
+		pw:     pw,
+		pushed: pushed,
+	}, nil
+}
+
+// errIncomplete aborts caching content that was closed before it was read
+// to the end, so that an incomplete copy is never stored.
+var errIncomplete = errors.New("content was not read to the end")
+
+// cachingReadCloser reads content from base and writes it to the cache as it
+// is read. Once writing to the cache fails, the rest of the content is only
+// read from base.
+type cachingReadCloser struct {
+	store  *Store
+	desc   ocispec.Descriptor
+	rc     io.ReadCloser
+	pw     *io.PipeWriter
+	pushed <-chan error
+	n      int64
+}
+
+func (c *cachingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	c.n += int64(n)
+	if n > 0 && c.pw != nil {
+		if _, werr := c.pw.Write(p[:n]); werr != nil {
+			c.finish(werr)
+		}
+	}
+	if err == io.EOF && c.pw != nil {
+		c.finish(nil)
+	}
+	return n, err
+}
+
+func (c *cachingReadCloser) Close() error {
+	if c.pw != nil {
+		// Callers, such as JSON decoders, may stop reading once they have
+		// read all of the content, before they see EOF.
+		if c.n == c.desc.Size {
+			c.finish(nil)
+		} else {
+			c.finish(errIncomplete)
+		}
+	}
+	return c.rc.Close()
+}
+
+// finish stops writing to the cache, closing the pipe with err, and waits for
+// the cache push to complete. Cache errors are reported as warnings.
+func (c *cachingReadCloser) finish(err error) {
+	c.pw.CloseWithError(err)
+	c.pw = nil
+	pushErr := <-c.pushed
+	if pushErr == nil {
+		// The cache stopped reading without an error, so err is the
+		// reason that writing to it failed.
+		pushErr = err
+	}
+	if pushErr == nil || errors.Is(pushErr, errIncomplete) {
+		return
+	}
+	c.store.warn(fmt.Sprintf("cache %s: %v", c.desc.Digest, pushErr))
+}
@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/content/oci"
+	"oras.land/oras-go/v2/errdef"
+)
+
+// failingStorage is a content.Storage whose Fetch and Push fail with the
+// configured errors.
+type failingStorage struct {
+	content.Storage
+	fetchErr error
+	pushErr  error
+}
+
+func (s failingStorage) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	if s.fetchErr != nil {
+		return nil, s.fetchErr
+	}
+	return s.Storage.Fetch(ctx, desc)
+}
+
+func (s failingStorage) Push(ctx context.Context, desc ocispec.Descriptor, r io.Reader) error {
+	if s.pushErr != nil {
+		// Read some of the content first, as a real store would.
+		_, _ = r.Read(make([]byte, 1))
+		return s.pushErr
+	}
+	return s.Storage.Push(ctx, desc, r)
+}
+
+func TestStoreFetch(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	desc := content.NewDescriptorFromBytes("application/octet-stream", data)
+
+	for _, tc := range []struct {
+		name string
+		// inBase and inCache set whether the content is in the store.
+		inBase, inCache bool
+		fetchErr        error
+		pushErr         error
+		// readAll sets whether all of the content is read before Close.
+		readAll      bool
+		wantCached   bool
+		wantWarnings []string
+	}{
+		{name: "hit", inCache: true, readAll: true, wantCached: true},
+		{name: "miss fills the cache", inBase: true, readAll: true, wantCached: true},
+		{name: "miss read in part is not cached", inBase: true, readAll: false, wantCached: false},
+		{
+			name:         "cache push fails",
+			inBase:       true,
+			pushErr:      errors.New("disk full"),
+			readAll:      true,
+			wantWarnings: []string{"cache " + desc.Digest.String() + ": disk full"},
+		},
+		{
+			name:         "cache push already exists",
+			inBase:       true,
+			pushErr:      errdef.ErrAlreadyExists,
+			readAll:      true,
+			wantWarnings: []string{"cache " + desc.Digest.String() + ": " + errdef.ErrAlreadyExists.Error()},
+		},
+		{
+			name:     "cache fetch fails",
+			inBase:   true,
+			inCache:  true,
+			fetchErr: errors.New("permission denied"),
+			readAll:  true,
+			// The content is already in the cache.
+			wantCached:   true,
+			wantWarnings: []string{"fetch " + desc.Digest.String() + " from cache: permission denied"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			base, cache := memory.New(), memory.New()
+			if tc.inBase {
+				if err := base.Push(ctx, desc, bytes.NewReader(data)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.inCache {
+				if err := cache.Push(ctx, desc, bytes.NewReader(data)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var warnings []string
+			s := NewStore(base, failingStorage{Storage: cache, fetchErr: tc.fetchErr, pushErr: tc.pushErr}, WithWarningHandler(func(msg string) {
+				warnings = append(warnings, msg)
+			}))
+
+			rc, err := s.Fetch(ctx, desc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.readAll {
+				got, err := io.ReadAll(rc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("expected %d bytes of content, got %d bytes", len(data), len(got))
+				}
+			} else if _, err := io.ReadFull(rc, make([]byte, 10)); err != nil {
+				t.Fatal(err)
+			}
+			if err := rc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			cached, err := cache.Exists(ctx, desc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cached != tc.wantCached {
+				t.Errorf("expected cached %v, got %v", tc.wantCached, cached)
+			}
+			if strings.Join(warnings, "\n") != strings.Join(tc.wantWarnings, "\n") {
+				t.Errorf("expected warnings %q, got %q", tc.wantWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestStoreFetchOCILayout(t *testing.T) {
+	ctx := context.Background()
+	data := []byte(`{"foo":"bar"}`)
+	desc := content.NewDescriptorFromBytes("application/json", data)
+	base := memory.New()
+	if err := base.Push(ctx, desc, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := oci.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var warnings []string
+	s := NewStore(base, cache, WithWarningHandler(func(msg string) { warnings = append(warnings, msg) }))
+
+	for _, step := range []struct {
+		name string
+		read int
+		want bool
+	}{
+		{name: "read in part", read: 5, want: false},
+		// Decoders may stop reading at the end of the content without
+		// seeing EOF.
+		{name: "read to the end of the content", read: len(data), want: true},
+		{name: "fetched again from the cache", read: len(data), want: true},
+	} {
+		rc, err := s.Fetch(ctx, desc)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if _, err := io.ReadFull(rc, make([]byte, step.read)); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if err := rc.Close(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		cached, err := cache.Exists(ctx, desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cached != step.want {
+			t.Errorf("%s: expected cached %v, got %v", step.name, step.want, cached)
+		}
+	}
+	if len(warnings) > 0 {
+		t.Errorf("expected no warnings, got %q", warnings)
+	}
+}
@@ -7,23 +7,23 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"runtime"
 	"sort"
+	"sync"
 
-	"github.com/docker/docker/pkg/jsonmessage"
-	dockerprogress "github.com/docker/docker/pkg/progress"
-	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/containers/image/v5/docker/reference"
 	"github.com/go-logr/logr"
-	"github.com/mattn/go-isatty"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"golang.org/x/sync/errgroup"
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/content/memory"
 	"oras.land/oras-go/v2/errdef"
+	orasremote "oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
 
 	"github.com/joelanford/olm-oci/pkg/progress"
+	"github.com/joelanford/olm-oci/pkg/remote"
 )
 
 type Artifact interface {
@@ -38,45 +38,282 @@ type Blob interface {
 	Data() (io.ReadCloser, error)
 }
 
+// AnnotatedBlob is implemented by blobs that annotate their descriptors,
+// for example with a file name that generic OCI tools can write them to.
+type AnnotatedBlob interface {
+	Blob
+	Annotations() map[string]string
+}
+
+// IndexedArtifact is implemented by artifacts with blobs that describe their
+// sub-artifacts. IndexBlobs is called with the descriptors of the pushed
+// sub-artifacts, in the order returned by SubArtifacts.
+type IndexedArtifact interface {
+	Artifact
+	IndexBlobs(subArtifacts []ocispec.Descriptor) ([]Blob, error)
+}
+
+// ProgressReporter receives progress events for artifact graph transfers.
+type ProgressReporter = progress.Reporter
+
+// DecodeFunc decodes the artifact graph rooted at desc, fetching its content
+// from src.
+type DecodeFunc func(ctx context.Context, src content.Fetcher, desc ocispec.Descriptor) error
+
+// Client pushes, pulls and copies OLM OCI artifact graphs.
 type Client struct {
-	Target oras.Target
-	Log    logr.Logger
+	concurrency int
+	reporter    ProgressReporter
+	log         logr.Logger
+	staging     oras.Target
+	registry    *remote.Config
+	credential  func(ctx context.Context, hostport string) (auth.Credential, error)
+	annotations map[string]string
+}
+
+// NewClient returns a Client configured by opts. By default, the client
+// transfers runtime.NumCPU() blobs at once, discards progress and logs, and
+// accesses registries with remote.DefaultConfig.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		concurrency: runtime.NumCPU(),
+		reporter:    progress.Discard,
+		log:         logr.Discard(),
+		registry:    remote.DefaultConfig,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.registry == nil {
+		c.registry = remote.DefaultConfig
+	}
+	if c.credential != nil {
+		cfg := *c.registry
+		cfg.Credential = c.credential
+		c.registry = &cfg
+	}
+	return c
+}
+
+// Push pushes artifact to ref. If ref is tagged, the artifact is also tagged.
+func (c *Client) Push(ctx context.Context, artifact Artifact, ref string) (ocispec.Descriptor, error) {
+	named, err := reference.ParseNamed(ref)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("parse reference: %v", err)
+	}
+	repo, err := c.registry.NewRepository(named.Name())
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("create repository client: %v", err)
+	}
+	desc, err := c.push(ctx, artifact, repo)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if tagged, ok := named.(reference.Tagged); ok {
+		if err := repo.Tag(ctx, desc, tagged.Tag()); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("tag artifact: %v", err)
+		}
+	}
+	c.log.Info("pushed artifact", "reference", ref, "artifactType", artifact.ArtifactType(), "digest", desc.Digest)
+	return desc, nil
+}
+
+// Pull pulls the artifact graph that ref resolves to into the staging store
+// and decodes it with decode.
+func (c *Client) Pull(ctx context.Context, ref string, decode DecodeFunc) (ocispec.Descriptor, error) {
+	repo, _, desc, err := c.registry.ResolveNameAndReference(ctx, ref)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("resolve reference: %v", err)
+	}
+	staging := c.stagingStore()
+	if err := c.copyGraph(ctx, repo, staging, *desc); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if err := decode(ctx, staging, *desc); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("decode artifact: %v", err)
+	}
+	c.log.Info("pulled artifact", "reference", ref, "digest", desc.Digest)
+	return *desc, nil
+}
+
+// Resolve returns the descriptor that ref refers to.
+func (c *Client) Resolve(ctx context.Context, ref string) (ocispec.Descriptor, error) {
+	_, _, desc, err := c.registry.ResolveNameAndReference(ctx, ref)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	c.log.V(1).Info("resolved reference", "reference", ref, "digest", desc.Digest)
+	return *desc, nil
+}
+
+// Tag resolves ref and tags the result with each of tags in the same
+// repository.
+func (c *Client) Tag(ctx context.Context, ref string, tags ...string) (ocispec.Descriptor, error) {
+	named, err := reference.ParseNamed(ref)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("parse reference: %v", err)
+	}
+	tagOrDigest, err := remote.TagOrDigest(named)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	repo, err := c.registry.NewRepository(named.Name())
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("create repository client: %v", err)
+	}
+	desc, err := repo.Resolve(ctx, tagOrDigest)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("resolve reference: %v", err)
+	}
+	for _, tag := range tags {
+		if err := repo.Tag(ctx, desc, tag); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("tag %q: %v", tag, err)
+		}
+		c.log.Info("tagged artifact", "repository", named.Name(), "tag", tag, "digest", desc.Digest)
+	}
+	return desc, nil
+}
+
+// Copy copies the artifact graph that srcRef resolves to into dstRef's
+// repository. If dstRef is tagged, the copy is also tagged.
+func (c *Client) Copy(ctx context.Context, srcRef, dstRef string) (ocispec.Descriptor, error) {
+	src, _, desc, err := c.registry.ResolveNameAndReference(ctx, srcRef)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("resolve source reference: %v", err)
+	}
+	dstNamed, err := reference.ParseNamed(dstRef)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("parse destination reference: %v", err)
+	}
+	dst, err := c.registry.NewRepository(dstNamed.Name())
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("create repository client: %v", err)
+	}
+	if err := c.copyGraph(ctx, src, dst, *desc); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	if tagged, ok := dstNamed.(reference.Tagged); ok {
+		if err := dst.Tag(ctx, *desc, tagged.Tag()); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("tag artifact: %v", err)
+		}
+	}
+	c.log.Info("copied artifact", "source", srcRef, "destination", dstRef, "digest", desc.Digest)
+	return *desc, nil
+}
+
+func (c *Client) stagingStore() oras.Target {
+	if c.staging != nil {
+		return c.staging
+	}
+	return memory.New()
 }
 
-func Push(ctx context.Context, artifact Artifact, target oras.Target) (ocispec.Descriptor, error) {
-	store := memory.New()
-	desc, err := push(ctx, artifact, store)
+// push stages artifact's graph and copies it to target.
+func (c *Client) push(ctx context.Context, artifact Artifact, target oras.Target) (ocispec.Descriptor, error) {
+	staging := c.stagingStore()
+	desc, err := push(ctx, artifact, staging, c.annotations)
 	if err != nil {
 		return ocispec.Descriptor{}, fmt.Errorf("stage artifact graph locally: %v", err)
 	}
+	c.log.V(1).Info("staged artifact graph", "artifactType", artifact.ArtifactType(), "digest", desc.Digest)
 
-	if err := CopyGraphWithProgress(ctx, store, target, desc); err != nil {
+	if err := c.copyGraph(ctx, staging, target, desc); err != nil {
 		return ocispec.Descriptor{}, fmt.Errorf("push artifact graph: %v", err)
 	}
 	return desc, nil
 }
 
-func pushSubArtifacts(ctx context.Context, eg *errgroup.Group, descChan chan<- ocispec.Descriptor, subIndices []Artifact, store *memory.Store) {
-	for _, si := range subIndices {
-		si := si
+// copyGraph copies the graph rooted at desc from src to dst, reporting the
+// progress of each descriptor and a final transfer summary.
+func (c *Client) copyGraph(ctx context.Context, src content.ReadOnlyStorage, dst oras.Target, desc ocispec.Descriptor) error {
+	action := transferAction(src, dst)
+	ps := progress.NewStore(src, c.reporter, action)
+
+	var (
+		mu      sync.Mutex
+		summary = progress.Summary{Root: desc}
+	)
+	opts := oras.CopyGraphOptions{
+		Concurrency: c.concurrency,
+		OnCopySkipped: func(ctx context.Context, desc ocispec.Descriptor) error {
+			mu.Lock()
+			summary.BlobsSkipped++
+			summary.BytesSkipped += desc.Size
+			mu.Unlock()
+			c.log.V(1).Info("skipped existing content", "action", action, "mediaType", desc.MediaType, "digest", desc.Digest, "size", desc.Size)
+			c.reporter.Report(progress.Event{
+				Type:       progress.EventSkipped,
+				Action:     action,
+				Descriptor: &desc,
+			})
+			return nil
+		},
+		PostCopy: func(_ context.Context, desc ocispec.Descriptor) error {
+			mu.Lock()
+			summary.BlobsTransferred++
+			summary.BytesTransferred += desc.Size
+			mu.Unlock()
+			c.log.V(1).Info("transferred content", "action", action, "mediaType", desc.MediaType, "digest", desc.Digest, "size", desc.Size)
+			c.reporter.Report(progress.Event{
+				Type:       progress.EventComplete,
+				Action:     action,
+				Descriptor: &desc,
+				Current:    desc.Size,
+			})
+			return nil
+		},
+	}
+	if err := oras.CopyGraph(ctx, ps, newRetryTarget(dst, ps, c.registry), desc, opts); err != nil {
+		return fmt.Errorf("copy artifact graph: %v", err)
+	}
+	c.reporter.Report(progress.Event{
+		Type:    progress.EventSummary,
+		Action:  action,
+		Summary: &summary,
+	})
+	return nil
+}
+
+// Push stages artifact's graph in memory and copies it to target, reporting
+// progress to reporter.
+func Push(ctx context.Context, artifact Artifact, target oras.Target, reporter ProgressReporter) (ocispec.Descriptor, error) {
+	return NewClient(WithProgressReporter(reporter)).push(ctx, artifact, target)
+}
+
+// CopyGraphWithProgress copies the graph rooted at desc from src to dst,
+// reporting the progress of each descriptor and a final transfer summary to
+// reporter.
+func CopyGraphWithProgress(ctx context.Context, src content.ReadOnlyStorage, dst oras.Target, desc ocispec.Descriptor, reporter ProgressReporter) error {
+	return NewClient(WithProgressReporter(reporter)).copyGraph(ctx, src, dst, desc)
+}
+
+func transferAction(src content.ReadOnlyStorage, dst oras.Target) string {
+	if _, ok := dst.(*orasremote.Repository); ok {
+		return "Pushing"
+	}
+	if _, ok := src.(*orasremote.Repository); ok {
+		return "Pulling"
+	}
+	return "Copying"
+}
+
+func pushSubArtifacts(ctx context.Context, eg *errgroup.Group, descs []ocispec.Descriptor, subIndices []Artifact, store content.Storage) {
+	for i, si := range subIndices {
+		i, si := i, si
 		eg.Go(func() error {
-			manifestDesc, err := push(ctx, si, store)
+			manifestDesc, err := push(ctx, si, store, nil)
 			if err != nil {
 				return err
 			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case descChan <- manifestDesc:
-			}
+			descs[i] = manifestDesc
 			return nil
 		})
 	}
 }
 
-func pushBlobs(ctx context.Context, eg *errgroup.Group, descChan chan<- ocispec.Descriptor, blobs []Blob, store *memory.Store) {
-	for _, blob := range blobs {
-		blob := blob
+func pushBlobs(ctx context.Context, eg *errgroup.Group, descs []ocispec.Descriptor, blobs []Blob, store content.Storage) {
+	for i, blob := range blobs {
+		i, blob := i, blob
 		eg.Go(func() error {
 			rc, err := blob.Data()
 			if err != nil {
@@ -89,75 +326,50 @@ func pushBlobs(ctx context.Context, eg *errgroup.Group, descChan chan<- ocispec.
 			}
 
 			desc := content.NewDescriptorFromBytes(blob.MediaType(), data)
+			if ab, ok := blob.(AnnotatedBlob); ok {
+				desc.Annotations = ab.Annotations()
+			}
 			if err := pushIfNotExist(ctx, store, desc, bytes.NewReader(data)); err != nil {
 				return fmt.Errorf("push blob %q with digest %s failed: %w", desc.MediaType, desc.Digest, err)
 			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case descChan <- desc:
-				return nil
-			}
+			descs[i] = desc
+			return nil
 		})
 	}
 }
 
-func CopyGraphWithProgress(ctx context.Context, src content.ReadOnlyStorage, dst oras.Target, desc ocispec.Descriptor) error {
-	pr, pw := io.Pipe()
-	fd := os.Stdout.Fd()
-	isTTY := isatty.IsTerminal(fd)
-	out := streamformatter.NewJSONProgressOutput(pw, !isTTY)
-	ps := progress.NewStore(src, out)
-	errChan := make(chan error, 1)
-	go func() {
-		errChan <- jsonmessage.DisplayJSONMessagesStream(pr, os.Stdout, fd, isTTY, nil)
-	}()
-	opts := oras.CopyGraphOptions{
-		Concurrency: runtime.NumCPU(),
-		OnCopySkipped: func(ctx context.Context, desc ocispec.Descriptor) error {
-			return out.WriteProgress(dockerprogress.Progress{
-				ID:     progress.IDForDesc(desc),
-				Action: "Artifact is up to date",
-			})
-		},
-		PostCopy: func(_ context.Context, desc ocispec.Descriptor) error {
-			return out.WriteProgress(dockerprogress.Progress{
-				ID:      progress.IDForDesc(desc),
-				Action:  "Complete",
-				Current: desc.Size,
-				Total:   desc.Size,
-			})
-		},
-	}
-	if err := oras.CopyGraph(ctx, ps, dst, desc, opts); err != nil {
-		return fmt.Errorf("copy artifact graph: %v", err)
-	}
-	if err := pw.Close(); err != nil {
-		return fmt.Errorf("close progress writer: %v", err)
-	}
-	if err := <-errChan; err != nil {
-		return fmt.Errorf("display progress: %v", err)
-	}
-	return nil
-}
+// push pushes artifact's graph to store. annotations are added to the root
+// manifest only, unless the artifact sets the same key. The manifests of
+// sub-artifacts, such as bundles shared by many catalogs, are left as is so
+// that their digests do not depend on where they were pushed from.
+func push(ctx context.Context, artifact Artifact, store content.Storage, annotations map[string]string) (ocispec.Descriptor, error) {
+	subArtifacts := artifact.SubArtifacts()
+	blobs := artifact.Blobs()
+	subDescs := make([]ocispec.Descriptor, len(subArtifacts))
 
-func push(ctx context.Context, artifact Artifact, store *memory.Store) (ocispec.Descriptor, error) {
 	eg, egCtx := errgroup.WithContext(ctx)
-	numDescs := len(artifact.SubArtifacts()) + len(artifact.Blobs())
-	descChan := make(chan ocispec.Descriptor, numDescs)
-
-	pushSubArtifacts(egCtx, eg, descChan, artifact.SubArtifacts(), store)
-	pushBlobs(egCtx, eg, descChan, artifact.Blobs(), store)
+	pushSubArtifacts(egCtx, eg, subDescs, subArtifacts, store)
+	if ia, ok := artifact.(IndexedArtifact); ok {
+		// Index blobs describe the pushed sub-artifacts, so they can only be
+		// built once every sub-artifact has been pushed.
+		if err := eg.Wait(); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		indexBlobs, err := ia.IndexBlobs(subDescs)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("build index blobs: %w", err)
+		}
+		blobs = append(blobs, indexBlobs...)
+		eg, egCtx = errgroup.WithContext(ctx)
+	}
+	blobDescs := make([]ocispec.Descriptor, len(blobs))
+	pushBlobs(egCtx, eg, blobDescs, blobs, store)
 
 	if err := eg.Wait(); err != nil {
 		return ocispec.Descriptor{}, err
 	}
-	close(descChan)
 
-	descriptors := make([]ocispec.Descriptor, 0, numDescs)
-	for desc := range descChan {
-		descriptors = append(descriptors, desc)
-	}
+	descriptors := append(subDescs, blobDescs...)
 	sort.Slice(descriptors, func(i, j int) bool {
 		return descriptors[i].Digest.String() < descriptors[j].Digest.String()
 	})
@@ -166,7 +378,7 @@ func push(ctx context.Context, artifact Artifact, store *memory.Store) (ocispec.
 		MediaType:    ocispec.MediaTypeArtifactManifest,
 		ArtifactType: artifact.ArtifactType(),
 		Blobs:        descriptors,
-		Annotations:  artifact.Annotations(),
+		Annotations:  mergeAnnotations(annotations, artifact.Annotations()),
 	})
 	desc := content.NewDescriptorFromBytes(ocispec.MediaTypeArtifactManifest, data)
 
@@ -186,7 +398,23 @@ func push(ctx context.Context, artifact Artifact, store *memory.Store) (ocispec.
 	return desc, nil
 }
 
-func pushIfNotExist(ctx context.Context, store *memory.Store, desc ocispec.Descriptor, r io.Reader) error {
+// mergeAnnotations returns the union of base and overrides, preferring
+// overrides. It returns nil if both are empty.
+func mergeAnnotations(base, overrides map[string]string) map[string]string {
+	if len(base) == 0 {
+		return overrides
+	}
+	out := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range overrides {
+		out[k] = v
+	}
+	return out
+}
+
+func pushIfNotExist(ctx context.Context, store content.Storage, desc ocispec.Descriptor, r io.Reader) error {
 	if err := store.Push(ctx, desc, r); err != nil && !errors.Is(err, errdef.ErrAlreadyExists) {
 		return err
 	}
@@ -0,0 +1,323 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+	"oras.land/oras-go/v2/registry/remote/auth"
+
+	"github.com/joelanford/olm-oci/pkg/progress"
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+type testArtifact struct {
+	artifactType string
+	annotations  map[string]string
+	subArtifacts []Artifact
+	blobs        []Blob
+}
+
+func (a testArtifact) ArtifactType() string           { return a.artifactType }
+func (a testArtifact) Annotations() map[string]string { return a.annotations }
+func (a testArtifact) SubArtifacts() []Artifact       { return a.subArtifacts }
+func (a testArtifact) Blobs() []Blob                  { return a.blobs }
+
+type testBlob string
+
+func (b testBlob) MediaType() string { return "text/plain" }
+func (b testBlob) Data() (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(b))), nil
+}
+
+func fetchManifest(t *testing.T, ctx context.Context, src content.Fetcher, desc ocispec.Descriptor) ocispec.Artifact {
+	t.Helper()
+	data, err := content.FetchAll(ctx, src, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var art ocispec.Artifact
+	if err := json.Unmarshal(data, &art); err != nil {
+		t.Fatal(err)
+	}
+	return art
+}
+
+func TestPushManifestAnnotations(t *testing.T) {
+	child := testArtifact{
+		artifactType: "application/vnd.test.child",
+		annotations:  map[string]string{ocispec.AnnotationTitle: "child"},
+		blobs:        []Blob{testBlob("child")},
+	}
+	for _, tc := range []struct {
+		name            string
+		annotations     map[string]string
+		rootAnnotations map[string]string
+		wantRoot        map[string]string
+	}{
+		{
+			name:            "no client annotations",
+			rootAnnotations: map[string]string{ocispec.AnnotationTitle: "root"},
+			wantRoot:        map[string]string{ocispec.AnnotationTitle: "root"},
+		},
+		{
+			name:            "client annotations",
+			annotations:     map[string]string{ocispec.AnnotationSource: "https://example.com/repo"},
+			rootAnnotations: map[string]string{ocispec.AnnotationTitle: "root"},
+			wantRoot:        map[string]string{ocispec.AnnotationTitle: "root", ocispec.AnnotationSource: "https://example.com/repo"},
+		},
+		{
+			name:        "client annotations without artifact annotations",
+			annotations: map[string]string{ocispec.AnnotationSource: "https://example.com/repo"},
+			wantRoot:    map[string]string{ocispec.AnnotationSource: "https://example.com/repo"},
+		},
+		{
+			name:            "artifact annotations take precedence",
+			annotations:     map[string]string{ocispec.AnnotationTitle: "client"},
+			rootAnnotations: map[string]string{ocispec.AnnotationTitle: "root"},
+			wantRoot:        map[string]string{ocispec.AnnotationTitle: "root"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.New()
+			root := testArtifact{
+				artifactType: "application/vnd.test.root",
+				annotations:  tc.rootAnnotations,
+				subArtifacts: []Artifact{child},
+				blobs:        []Blob{testBlob("root")},
+			}
+			desc, err := NewClient(WithManifestAnnotations(tc.annotations)).push(ctx, root, store)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rootArt := fetchManifest(t, ctx, store, desc)
+			if !reflect.DeepEqual(rootArt.Annotations, tc.wantRoot) {
+				t.Errorf("expected root annotations %v, got %v", tc.wantRoot, rootArt.Annotations)
+			}
+			var children int
+			for _, b := range rootArt.Blobs {
+				if b.MediaType != ocispec.MediaTypeArtifactManifest {
+					continue
+				}
+				children++
+				childArt := fetchManifest(t, ctx, store, b)
+				if !reflect.DeepEqual(childArt.Annotations, child.annotations) {
+					t.Errorf("expected sub-artifact annotations %v, got %v", child.annotations, childArt.Annotations)
+				}
+			}
+			if children != 1 {
+				t.Errorf("expected 1 sub-artifact, got %d", children)
+			}
+		})
+	}
+}
+
+func TestNewClientRegistryConfig(t *testing.T) {
+	credential := func(context.Context, string) (auth.Credential, error) {
+		return auth.Credential{Username: "foo", Password: "bar"}, nil
+	}
+	custom := &remote.Config{UploadChunkSize: 1024}
+	for _, tc := range []struct {
+		name           string
+		opts           []Option
+		wantConfig     *remote.Config
+		wantCredential bool
+	}{
+		{name: "default", wantConfig: remote.DefaultConfig},
+		{name: "nil config", opts: []Option{WithRegistryConfig(nil)}, wantConfig: remote.DefaultConfig},
+		{name: "custom config", opts: []Option{WithRegistryConfig(custom)}, wantConfig: custom},
+		{name: "credential", opts: []Option{WithCredential(credential)}, wantConfig: remote.DefaultConfig, wantCredential: true},
+		{name: "nil config with credential", opts: []Option{WithRegistryConfig(nil), WithCredential(credential)}, wantConfig: remote.DefaultConfig, wantCredential: true},
+		{name: "custom config with credential", opts: []Option{WithRegistryConfig(custom), WithCredential(credential)}, wantConfig: custom, wantCredential: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewClient(tc.opts...)
+			if c.registry == nil {
+				t.Fatal("expected a registry config")
+			}
+			if !tc.wantCredential {
+				if c.registry != tc.wantConfig {
+					t.Errorf("expected registry config %p, got %p", tc.wantConfig, c.registry)
+				}
+				return
+			}
+			// The credential is set on a copy, leaving the given config as is.
+			if c.registry == tc.wantConfig {
+				t.Fatal("expected a copy of the registry config")
+			}
+			if tc.wantConfig.Credential != nil {
+				t.Error("expected the given registry config to be unchanged")
+			}
+			if c.registry.Credential == nil {
+				t.Fatal("expected a credential")
+			}
+			if c.registry.UploadChunkSize != tc.wantConfig.UploadChunkSize {
+				t.Errorf("expected upload chunk size %d, got %d", tc.wantConfig.UploadChunkSize, c.registry.UploadChunkSize)
+			}
+		})
+	}
+}
+
+func TestClientPushPullCopy(t *testing.T) {
+	ctx := context.Background()
+	reg, host := newTestRegistry(t)
+	cfg := remote.NewConfig()
+	cfg.Default.PlainHTTP = true
+	cfg.Credential = func(context.Context, string) (auth.Credential, error) {
+		return auth.EmptyCredential, nil
+	}
+
+	root := testArtifact{
+		artifactType: "application/vnd.test.root",
+		annotations:  map[string]string{ocispec.AnnotationTitle: "root"},
+		subArtifacts: []Artifact{testArtifact{
+			artifactType: "application/vnd.test.child",
+			blobs:        []Blob{testBlob("child")},
+		}},
+		blobs: []Blob{testBlob("root")},
+	}
+	reporter := &recordingReporter{}
+	c := NewClient(
+		WithRegistryConfig(cfg),
+		WithProgressReporter(reporter),
+		WithManifestAnnotations(map[string]string{ocispec.AnnotationSource: "https://example.com/repo"}),
+	)
+
+	pushed, err := c.Push(ctx, root, host+"/foo:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2 manifests and 2 blobs
+	if got := reporter.summary(t); got.BlobsTransferred != 4 || got.Root.Digest != pushed.Digest {
+		t.Errorf("expected a summary of 4 transferred blobs of %s, got %+v", pushed.Digest, got)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		ref     string
+		wantErr string
+	}{
+		{name: "tag", ref: host + "/foo:v1"},
+		{name: "digest", ref: host + "/foo@" + pushed.Digest.String()},
+		{name: "unknown tag", ref: host + "/foo:v2", wantErr: "resolve reference"},
+		{name: "unknown repository", ref: host + "/bar:v1", wantErr: "resolve reference"},
+	} {
+		t.Run("pull by "+tc.name, func(t *testing.T) {
+			staging := memory.New()
+			pc := NewClient(WithRegistryConfig(cfg), WithStagingStore(staging))
+			var decoded ocispec.Artifact
+			desc, err := pc.Pull(ctx, tc.ref, func(ctx context.Context, src content.Fetcher, desc ocispec.Descriptor) error {
+				decoded = fetchManifest(t, ctx, src, desc)
+				return nil
+			})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if desc.Digest != pushed.Digest {
+				t.Errorf("expected digest %s, got %s", pushed.Digest, desc.Digest)
+			}
+			if decoded.ArtifactType != root.artifactType || decoded.Annotations[ocispec.AnnotationSource] == "" {
+				t.Errorf("expected the pushed root manifest, got %+v", decoded)
+			}
+			// The whole graph is pulled into the staging store.
+			for _, b := range decoded.Blobs {
+				if exists, err := staging.Exists(ctx, b); err != nil || !exists {
+					t.Errorf("expected %s to be pulled, got exists %v, err %v", b.Digest, exists, err)
+				}
+			}
+		})
+	}
+
+	reporter.reset()
+	copied, err := c.Copy(ctx, host+"/foo:v1", host+"/bar:v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if copied.Digest != pushed.Digest {
+		t.Errorf("expected copied digest %s, got %s", pushed.Digest, copied.Digest)
+	}
+	if got := reporter.summary(t); got.BlobsTransferred != 4 {
+		t.Errorf("expected a summary of 4 transferred blobs, got %+v", got)
+	}
+	resolved, err := c.Resolve(ctx, host+"/bar:v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Digest != pushed.Digest {
+		t.Errorf("expected %s to resolve to %s, got %s", host+"/bar:v2", pushed.Digest, resolved.Digest)
+	}
+
+	// Pushing the same graph again transfers nothing.
+	reporter.reset()
+	if _, err := c.Push(ctx, root, host+"/bar:v2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := reporter.summary(t); got.BlobsTransferred != 0 || got.BlobsSkipped == 0 {
+		t.Errorf("expected a summary of only skipped blobs, got %+v", got)
+	}
+
+	tagged, err := c.Tag(ctx, host+"/bar:v2", "latest", "stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []string{"latest", "stable"} {
+		resolved, err := c.Resolve(ctx, host+"/bar:"+tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved.Digest != tagged.Digest {
+			t.Errorf("expected tag %q to resolve to %s, got %s", tag, tagged.Digest, resolved.Digest)
+		}
+	}
+	if reg.requests == 0 {
+		t.Error("expected requests to the test registry")
+	}
+}
+
+// recordingReporter records the progress events reported to it.
+type recordingReporter struct {
+	mu     sync.Mutex
+	events []progress.Event
+}
+
+func (r *recordingReporter) Report(e progress.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recordingReporter) Close() error { return nil }
+
+func (r *recordingReporter) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
+
+// summary returns the summary of the last transfer reported.
+func (r *recordingReporter) summary(t *testing.T) progress.Summary {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.events {
+		if e.Type == progress.EventSummary {
+			return *e.Summary
+		}
+	}
+	t.Fatal("expected a summary event")
+	return progress.Summary{}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/registry/remote/auth"
+
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithConcurrency sets the maximum number of blobs transferred at once.
+func WithConcurrency(n int) Option {
+	return func(c *Client) {
+		c.concurrency = n
+	}
+}
+
+// WithProgressReporter sets the reporter that receives transfer progress.
+func WithProgressReporter(r ProgressReporter) Option {
+	return func(c *Client) {
+		c.reporter = r
+	}
+}
+
+// WithLogger sets the logger used for structured logging.
+func WithLogger(log logr.Logger) Option {
+	return func(c *Client) {
+		c.log = log
+	}
+}
+
+// WithStagingStore sets the store that artifact graphs are staged in before
+// they are pushed, and pulled into before they are decoded. By default, each
+// operation stages content in a new in-memory store.
+func WithStagingStore(s oras.Target) Option {
+	return func(c *Client) {
+		c.staging = s
+	}
+}
+
+// WithRegistryConfig sets the configuration used to access remote
+// registries. A nil cfg selects remote.DefaultConfig.
+func WithRegistryConfig(cfg *remote.Config) Option {
+	return func(c *Client) {
+		c.registry = cfg
+	}
+}
+
+// WithCredential sets the function that supplies registry credentials,
+// replacing the environment and auth file lookups.
+func WithCredential(credential func(ctx context.Context, hostport string) (auth.Credential, error)) Option {
+	return func(c *Client) {
+		c.credential = credential
+	}
+}
+
+// WithManifestAnnotations sets annotations, such as
+// "org.opencontainers.image.created" or "org.opencontainers.image.source",
+// that are added to the root manifest of each artifact graph pushed by the
+// client. Annotations set by the artifact take precedence.
+func WithManifestAnnotations(annotations map[string]string) Option {
+	return func(c *Client) {
+		c.annotations = annotations
+	}
+}
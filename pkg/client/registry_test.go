@@ -0,0 +1,131 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// testRegistry is an in-memory registry that implements the parts of the
+// OCI distribution API used to push, pull, copy and tag artifact graphs.
+// Uploads are monolithic, so blobs must be smaller than the upload chunk
+// size.
+type testRegistry struct {
+	mu       sync.Mutex
+	content  map[string][]byte // keyed by repository@digest
+	types    map[string]string // manifest media types, keyed by repository@digest
+	tags     map[string]string // digests, keyed by repository:tag
+	uploads  map[string]string // repositories, keyed by upload ID
+	requests int
+}
+
+var testRegistryPath = regexp.MustCompile(`^/v2/(.+)/(blobs|manifests)/(.+)$`)
+
+// newTestRegistry starts a testRegistry and returns it with its host and
+// port.
+func newTestRegistry(t *testing.T) (*testRegistry, string) {
+	t.Helper()
+	r := &testRegistry{
+		content: map[string][]byte{},
+		types:   map[string]string{},
+		tags:    map[string]string{},
+		uploads: map[string]string{},
+	}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, strings.TrimPrefix(srv.URL, "http://")
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+
+	if req.URL.Path == "/v2/" {
+		return
+	}
+	m := testRegistryPath.FindStringSubmatch(req.URL.Path)
+	if m == nil {
+		http.NotFound(w, req)
+		return
+	}
+	repo, kind, ref := m[1], m[2], m[3]
+	switch {
+	case kind == "blobs" && ref == "uploads/" && req.Method == http.MethodPost:
+		id := strconv.Itoa(len(r.uploads))
+		r.uploads[id] = repo
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", repo, id))
+		w.WriteHeader(http.StatusAccepted)
+	case kind == "blobs" && strings.HasPrefix(ref, "uploads/") && req.Method == http.MethodPut:
+		if r.uploads[strings.TrimPrefix(ref, "uploads/")] != repo {
+			http.NotFound(w, req)
+			return
+		}
+		r.put(w, req, repo, digest.Digest(req.URL.Query().Get("digest")), "")
+	case kind == "blobs" && (req.Method == http.MethodHead || req.Method == http.MethodGet):
+		r.get(w, req, repo+"@"+ref, "application/octet-stream")
+	case kind == "manifests" && req.Method == http.MethodPut:
+		dgst := digest.Digest(ref)
+		if dgst.Validate() != nil {
+			dgst = ""
+		}
+		r.put(w, req, repo, dgst, ref)
+	case kind == "manifests" && (req.Method == http.MethodHead || req.Method == http.MethodGet):
+		key := repo + "@" + ref
+		if dgst, ok := r.tags[repo+":"+ref]; ok {
+			key = repo + "@" + dgst
+		}
+		r.get(w, req, key, r.types[key])
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+// put stores the request body in repo. Manifests are stored with their
+// media type and tagged with ref, unless ref is a digest.
+func (r *testRegistry) put(w http.ResponseWriter, req *http.Request, repo string, dgst digest.Digest, ref string) {
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if dgst == "" {
+		dgst = digest.FromBytes(data)
+	}
+	if dgst != digest.FromBytes(data) {
+		http.Error(w, "digest mismatch", http.StatusBadRequest)
+		return
+	}
+	key := repo + "@" + dgst.String()
+	r.content[key] = data
+	if ref != "" {
+		r.types[key] = req.Header.Get("Content-Type")
+		if ref != dgst.String() {
+			r.tags[repo+":"+ref] = dgst.String()
+		}
+	}
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (r *testRegistry) get(w http.ResponseWriter, req *http.Request, key, mediaType string) {
+	data, ok := r.content[key]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Docker-Content-Digest", digest.FromBytes(data).String())
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
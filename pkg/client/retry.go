@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2"
+	"oras.land/oras-go/v2/content"
+	orasremote "oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+
+	"github.com/joelanford/olm-oci/pkg/remote"
+)
+
+// retryTarget retries blob pushes to a copy destination. Requests to remote
+// repositories are already retried by their transport, except for the
+// upload of a blob's content, whose stream cannot be rewound. Those uploads
+// are retried here by re-fetching the content from src. Large blobs pushed
+// to remote repositories are uploaded in resumable chunks.
+type retryTarget struct {
+	oras.Target
+	src       content.ReadOnlyStorage
+	policy    remote.RetryPolicy
+	chunkSize int64
+}
+
+func newRetryTarget(dst oras.Target, src content.ReadOnlyStorage, cfg *remote.Config) oras.Target {
+	return &retryTarget{
+		Target:    dst,
+		src:       src,
+		policy:    cfg.Retry,
+		chunkSize: cfg.UploadChunkSize,
+	}
+}
+
+func (t *retryTarget) Push(ctx context.Context, desc ocispec.Descriptor, r io.Reader) error {
+	repo, ok := t.Target.(*orasremote.Repository)
+	if !ok || isManifest(desc.MediaType) {
+		return t.Target.Push(ctx, desc, r)
+	}
+
+	first := true
+	open := func() (io.ReadCloser, error) {
+		if first {
+			first = false
+			return io.NopCloser(r), nil
+		}
+		return t.src.Fetch(ctx, desc)
+	}
+	if t.chunkSize > 0 && desc.Size >= t.chunkSize {
+		return remote.PushBlobChunked(ctx, repo, desc, open, t.chunkSize, t.policy)
+	}
+	return t.policy.Do(ctx, func() error {
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		err = t.Target.Push(ctx, desc, rc)
+		if err != nil && !isContentUploadError(err) {
+			return remote.Permanent(err)
+		}
+		return err
+	})
+}
+
+// isContentUploadError reports whether err came from the PUT request that
+// streams a blob's content. Every other request of a blob push has already
+// been retried by the repository's transport.
+func isContentUploadError(err error) bool {
+	var respErr *errcode.ErrorResponse
+	if errors.As(err, &respErr) {
+		return respErr.Method == http.MethodPut
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Op == "Put"
+	}
+	return false
+}
+
+func isManifest(mediaType string) bool {
+	switch mediaType {
+	case ocispec.MediaTypeArtifactManifest,
+		ocispec.MediaTypeImageManifest,
+		ocispec.MediaTypeImageIndex,
+		schema2.MediaTypeManifest,
+		manifestlist.MediaTypeManifestList:
+		return true
+	}
+	return false
+}
@@ -3,6 +3,7 @@ package fetch
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -10,7 +11,9 @@ import (
 	"oras.land/oras-go/v2/content"
 
 	pkg "github.com/joelanford/olm-oci/api/v1"
+	"github.com/joelanford/olm-oci/pkg/client"
 	"github.com/joelanford/olm-oci/pkg/inspect"
+	"github.com/joelanford/olm-oci/pkg/remote"
 )
 
 func IgnoreMediaTypes(mediaTypes ...string) func(context.Context, content.Fetcher, v1.Descriptor) ([]ocispec.Descriptor, error) {
@@ -43,6 +46,59 @@ func FetchArtifact(ctx context.Context, src content.Fetcher, desc ocispec.Descri
 	return inspect.DecodeArtifact(rc)
 }
 
+// Into returns a client.DecodeFunc that decodes a pulled artifact into v,
+// which must be a *pkg.Catalog, *pkg.Package, *pkg.Channel or *pkg.Bundle.
+func Into(v any, skipMediaTypes ...string) client.DecodeFunc {
+	return func(ctx context.Context, src content.Fetcher, desc ocispec.Descriptor) error {
+		art, err := FetchArtifact(ctx, src, desc)
+		if err != nil {
+			return err
+		}
+		switch out := v.(type) {
+		case *pkg.Catalog:
+			c, err := FetchCatalog(ctx, src, art, skipMediaTypes...)
+			if err != nil {
+				return err
+			}
+			*out = *c
+		case *pkg.Package:
+			p, err := FetchPackage(ctx, src, art, skipMediaTypes...)
+			if err != nil {
+				return err
+			}
+			*out = *p
+		case *pkg.Channel:
+			ch, err := FetchChannel(ctx, src, art, skipMediaTypes...)
+			if err != nil {
+				return err
+			}
+			*out = *ch
+		case *pkg.Bundle:
+			b, err := FetchBundle(ctx, src, art, skipMediaTypes...)
+			if err != nil {
+				return err
+			}
+			b.Digest = desc.Digest
+			*out = *b
+		default:
+			return fmt.Errorf("cannot decode artifact into %T", v)
+		}
+		return nil
+	}
+}
+
+// isBlob reports whether b has role and mediaType. Blobs are dispatched on
+// both, so that a blob whose media type does not match its role is rejected
+// instead of being decoded as something it is not.
+func isBlob(b ocispec.Descriptor, role, mediaType string) bool {
+	return pkg.BlobRole(b) == role && b.MediaType == mediaType
+}
+
+// isIcon reports whether b is an icon in a supported format.
+func isIcon(b ocispec.Descriptor) bool {
+	return pkg.BlobRole(b) == pkg.BlobRoleIcon && pkg.IsIconMediaType(b.MediaType)
+}
+
 func FetchCatalog(ctx context.Context, src content.Fetcher, catArtifact ocispec.Artifact, skipMediaTypes ...string) (*pkg.Catalog, error) {
 	if catArtifact.ArtifactType != pkg.MediaTypeCatalog {
 		return nil, fmt.Errorf("expected artifact type %q, got %q", pkg.MediaTypeCatalog, catArtifact.ArtifactType)
@@ -54,32 +110,44 @@ func FetchCatalog(ctx context.Context, src content.Fetcher, catArtifact ocispec.
 			continue
 		}
 		if err := func() error {
-			if b.MediaType != ocispec.MediaTypeArtifactManifest {
-				return fmt.Errorf("expected artifact manifest, got %q", b.MediaType)
-			}
-
 			br, err := src.Fetch(ctx, b)
 			if err != nil {
 				return fmt.Errorf("fetch blob: %v", err)
 			}
 			defer br.Close()
 
-			blobArt, err := inspect.DecodeArtifact(br)
-			if err != nil {
-				return fmt.Errorf("decode artifact manifest: %v", err)
-			}
-			switch blobArt.ArtifactType {
-			case pkg.MediaTypePackage:
-				p, err := FetchPackage(ctx, src, blobArt, skipMediaTypes...)
+			if b.MediaType == ocispec.MediaTypeArtifactManifest {
+				blobArt, err := inspect.DecodeArtifact(br)
 				if err != nil {
-					return fmt.Errorf("fetch package: %v", err)
+					return fmt.Errorf("decode artifact manifest: %v", err)
 				}
-				c.Packages = append(c.Packages, *p)
-			default:
-				return fmt.Errorf("expected artifact type %q, got %q", pkg.MediaTypePackage, blobArt.ArtifactType)
+				switch blobArt.ArtifactType {
+				case pkg.MediaTypePackage:
+					p, err := FetchPackage(ctx, src, blobArt, skipMediaTypes...)
+					if err != nil {
+						return fmt.Errorf("fetch package: %v", err)
+					}
+					c.Packages = append(c.Packages, *p)
+				default:
+					return fmt.Errorf("expected artifact type %q, got %q", pkg.MediaTypePackage, blobArt.ArtifactType)
+				}
+				return nil
 			}
-			return nil
 
+			switch {
+			case isBlob(b, pkg.BlobRoleMetadata, pkg.MediaTypeCatalogMetadata):
+				c.Metadata, err = inspect.DecodeCatalogMetadata(br)
+			case isBlob(b, pkg.BlobRolePackageIndex, pkg.MediaTypePackageIndex):
+				// The package index is derived from the packages, and is
+				// rebuilt whenever the catalog is pushed.
+			case isIcon(b):
+				var icon pkg.Icon
+				icon, err = inspect.DecodeIcon(b.MediaType, br)
+				c.Icon = &icon
+			default:
+				return fmt.Errorf("unsupported catalog blob media type %q with role %q", b.MediaType, pkg.BlobRole(b))
+			}
+			return err
 		}(); err != nil {
 			return nil, err
 		}
@@ -123,21 +191,23 @@ func FetchPackage(ctx context.Context, src content.Fetcher, pkgArtifact ocispec.
 				return nil
 			}
 
-			switch b.MediaType {
-			case pkg.MediaTypePackageMetadata:
+			switch {
+			case isBlob(b, pkg.BlobRoleMetadata, pkg.MediaTypePackageMetadata):
 				p.Metadata, err = inspect.DecodePackageMetadata(br)
-			case pkg.MediaTypeUpgradeEdges:
+			case isBlob(b, pkg.BlobRoleUpgradeEdges, pkg.MediaTypeUpgradeEdges):
 				p.UpgradeEdges, err = inspect.DecodeUpgradeEdges(br)
-			case pkg.MediaTypeProperties:
+			case isBlob(b, pkg.BlobRoleUpgradeSkips, pkg.MediaTypeUpgradeSkips):
+				p.UpgradeSkips, err = inspect.DecodeUpgradeSkips(br)
+			case isBlob(b, pkg.BlobRoleProperties, pkg.MediaTypeProperties):
 				p.Properties, err = inspect.DecodeProperties(br)
-			case "image/png", "image/svg+xml":
+			case isIcon(b):
 				var icon pkg.Icon
 				icon, err = inspect.DecodeIcon(b.MediaType, br)
 				p.Icon = &icon
-			case "text/markdown":
+			case isBlob(b, pkg.BlobRoleDescription, pkg.MediaTypeDescription):
 				p.Description, err = inspect.DecodeDescription(br)
 			default:
-				return fmt.Errorf("unsupported package blob media type %q", b.MediaType)
+				return fmt.Errorf("unsupported package blob media type %q with role %q", b.MediaType, pkg.BlobRole(b))
 			}
 			return err
 		}(); err != nil {
@@ -185,15 +255,15 @@ func FetchChannel(ctx context.Context, src content.Fetcher, chArt ocispec.Artifa
 				return nil
 			}
 
-			switch b.MediaType {
-			case pkg.MediaTypeChannelMetadata:
+			switch {
+			case isBlob(b, pkg.BlobRoleMetadata, pkg.MediaTypeChannelMetadata):
 				ch.Metadata, err = inspect.DecodeChannelMetadata(br)
-			case pkg.MediaTypeProperties:
+			case isBlob(b, pkg.BlobRoleProperties, pkg.MediaTypeProperties):
 				ch.Properties, err = inspect.DecodeProperties(br)
-			case ocispec.MediaTypeArtifactManifest:
-				return fmt.Errorf("unsupported channel blob artifact type %q", b.ArtifactType)
+			case isBlob(b, pkg.BlobRoleUpgradeEdges, pkg.MediaTypeChannelUpgradeEdges):
+				ch.UpgradeEdgeOverrides, err = inspect.DecodeChannelUpgradeEdges(br)
 			default:
-				return fmt.Errorf("unsupported channel blob media type %q", b.MediaType)
+				return fmt.Errorf("unsupported channel blob media type %q with role %q", b.MediaType, pkg.BlobRole(b))
 			}
 			return err
 		}(); err != nil {
@@ -212,6 +282,29 @@ func FetchBundle(ctx context.Context, src content.Fetcher, bArt ocispec.Artifact
 	bundle := pkg.Bundle{
 		ContentMediaType: bArt.Annotations[pkg.AnnotationKeyBundleContentMediaType],
 	}
+	for _, k := range []string{pkg.AnnotationKeyBundleLegacyChannels, pkg.AnnotationKeyBundleLegacyDefaultChannel} {
+		if v, ok := bArt.Annotations[k]; ok {
+			if bundle.MigrationHints == nil {
+				bundle.MigrationHints = map[string]string{}
+			}
+			bundle.MigrationHints[k] = v
+		}
+	}
+	// Only annotations passed through from the bundle are restored. Others,
+	// such as those added to the root manifest by the pushing client, are not
+	// part of the bundle.
+	if keys := bArt.Annotations[pkg.AnnotationKeyBundleCustomAnnotations]; keys != "" {
+		for _, k := range strings.Split(keys, ",") {
+			v, ok := bArt.Annotations[k]
+			if !ok {
+				continue
+			}
+			if bundle.CustomAnnotations == nil {
+				bundle.CustomAnnotations = map[string]string{}
+			}
+			bundle.CustomAnnotations[k] = v
+		}
+	}
 	for _, b := range bArt.Blobs {
 		if skips.Has(b.MediaType) {
 			continue
@@ -223,19 +316,19 @@ func FetchBundle(ctx context.Context, src content.Fetcher, bArt ocispec.Artifact
 			}
 			defer br.Close()
 
-			switch b.MediaType {
-			case pkg.MediaTypeBundleMetadata:
+			switch {
+			case isBlob(b, pkg.BlobRoleMetadata, pkg.MediaTypeBundleMetadata):
 				bundle.Metadata, err = inspect.DecodeBundleMetadata(br)
-			case pkg.MediaTypeProperties:
+			case isBlob(b, pkg.BlobRoleProperties, pkg.MediaTypeProperties):
 				bundle.Properties, err = inspect.DecodeProperties(br)
-			case pkg.MediaTypeConstraints:
+			case isBlob(b, pkg.BlobRoleConstraints, pkg.MediaTypeConstraints):
 				bundle.Constraints, err = inspect.DecodeConstraints(br)
-			case pkg.MediaTypeRelatedImages:
+			case isBlob(b, pkg.BlobRoleRelatedImages, pkg.MediaTypeRelatedImages):
 				bundle.RelatedImages, err = inspect.DecodeRelatedImages(br)
-			case pkg.MediaTypeBundleContent:
+			case isBlob(b, pkg.BlobRoleContent, pkg.MediaTypeBundleContent):
 				bundle.Content, err = inspect.DecodeBundleContent(br)
 			default:
-				return fmt.Errorf("unsupported bundle blob type %q", b.MediaType)
+				return fmt.Errorf("unsupported bundle blob media type %q with role %q", b.MediaType, pkg.BlobRole(b))
 			}
 			return err
 		}(); err != nil {
@@ -244,3 +337,68 @@ func FetchBundle(ctx context.Context, src content.Fetcher, bArt ocispec.Artifact
 	}
 	return &bundle, nil
 }
+
+// FetchPackageByName fetches the package named name from the catalog that
+// catalogRef resolves to. Only the catalog manifest, its package index and
+// the named package's manifest are fetched to find the package. Catalogs
+// without a package index are searched by fetching each package manifest.
+func FetchPackageByName(ctx context.Context, catalogRef, name string, skipMediaTypes ...string) (*pkg.Package, error) {
+	repo, _, desc, err := remote.ResolveNameAndReference(ctx, catalogRef)
+	if err != nil {
+		return nil, fmt.Errorf("resolve reference: %v", err)
+	}
+	catArt, err := FetchArtifact(ctx, repo, *desc)
+	if err != nil {
+		return nil, err
+	}
+	if catArt.ArtifactType != pkg.MediaTypeCatalog {
+		return nil, fmt.Errorf("expected artifact type %q, got %q", pkg.MediaTypeCatalog, catArt.ArtifactType)
+	}
+
+	pkgDesc, err := findPackage(ctx, repo, catArt, name)
+	if err != nil {
+		return nil, err
+	}
+	pkgArt, err := FetchArtifact(ctx, repo, *pkgDesc)
+	if err != nil {
+		return nil, err
+	}
+	return FetchPackage(ctx, repo, pkgArt, skipMediaTypes...)
+}
+
+func findPackage(ctx context.Context, src content.Fetcher, catArt ocispec.Artifact, name string) (*ocispec.Descriptor, error) {
+	for _, b := range catArt.Blobs {
+		if !isBlob(b, pkg.BlobRolePackageIndex, pkg.MediaTypePackageIndex) {
+			continue
+		}
+		br, err := src.Fetch(ctx, b)
+		if err != nil {
+			return nil, fmt.Errorf("fetch package index: %v", err)
+		}
+		defer br.Close()
+		index, err := inspect.DecodePackageIndex(br)
+		if err != nil {
+			return nil, err
+		}
+		entry, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("package %q not found in catalog", name)
+		}
+		desc := entry.Descriptor()
+		return &desc, nil
+	}
+
+	for _, b := range catArt.Blobs {
+		if b.MediaType != ocispec.MediaTypeArtifactManifest {
+			continue
+		}
+		art, err := FetchArtifact(ctx, src, b)
+		if err != nil {
+			return nil, err
+		}
+		if art.ArtifactType == pkg.MediaTypePackage && art.Annotations[pkg.AnnotationKeyName] == name {
+			return &b, nil
+		}
+	}
+	return nil, fmt.Errorf("package %q not found in catalog", name)
+}
@@ -0,0 +1,211 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+
+	pkg "github.com/joelanford/olm-oci/api/v1"
+	"github.com/joelanford/olm-oci/pkg/client"
+	"github.com/joelanford/olm-oci/pkg/progress"
+)
+
+func TestFetchBundleBlobDispatch(t *testing.T) {
+	metadata := []byte("package: foo\nversion: 1.0.0\n")
+	for _, tc := range []struct {
+		name      string
+		role      string
+		mediaType string
+		wantErr   string
+	}{
+		{name: "role and media type match", role: pkg.BlobRoleMetadata, mediaType: pkg.MediaTypeBundleMetadata},
+		{name: "role inferred from media type", mediaType: pkg.MediaTypeBundleMetadata},
+		{name: "media type of another role", role: pkg.BlobRoleMetadata, mediaType: pkg.MediaTypeProperties, wantErr: "unsupported bundle blob"},
+		{name: "metadata of another artifact type", role: pkg.BlobRoleMetadata, mediaType: pkg.MediaTypePackageMetadata, wantErr: "unsupported bundle blob"},
+		{name: "unknown role", role: "unknown", mediaType: pkg.MediaTypeBundleMetadata, wantErr: "unsupported bundle blob"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.New()
+			desc := ocispec.Descriptor{
+				MediaType: tc.mediaType,
+				Digest:    digest.FromBytes(metadata),
+				Size:      int64(len(metadata)),
+			}
+			if tc.role != "" {
+				desc.Annotations = map[string]string{pkg.AnnotationKeyBlobRole: tc.role}
+			}
+			if err := store.Push(ctx, desc, bytes.NewReader(metadata)); err != nil {
+				t.Fatal(err)
+			}
+			b, err := FetchBundle(ctx, store, ocispec.Artifact{
+				MediaType:    ocispec.MediaTypeArtifactManifest,
+				ArtifactType: pkg.MediaTypeBundle,
+				Blobs:        []ocispec.Descriptor{desc},
+			})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.Metadata.Package != "foo" {
+				t.Errorf("expected package %q, got %q", "foo", b.Metadata.Package)
+			}
+		})
+	}
+}
+
+func TestPulledCatalogReferencesPushedBundles(t *testing.T) {
+	ctx := context.Background()
+	var bundles []pkg.Bundle
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		b, err := pkg.LoadBundleFS(fstest.MapFS{
+			"manifests/configmap.yaml":  {Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n")},
+			"metadata/annotations.yaml": {Data: []byte("annotations:\n  io.operatorframework.bundle.package: foo\n  io.operatorframework.bundle.version: " + version + "\n")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundles = append(bundles, *b)
+	}
+	c := pkg.Catalog{Packages: []pkg.Package{{
+		Metadata: pkg.PackageMetadata{Name: "foo", DefaultChannel: "stable"},
+		Channels: []pkg.Channel{{Metadata: pkg.ChannelMetadata{Name: "stable"}, Bundles: bundles}},
+	}}}
+
+	store := memory.New()
+	desc, err := client.Push(ctx, &c, store, progress.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pulled pkg.Catalog
+	if err := Into(&pulled)(ctx, store, desc); err != nil {
+		t.Fatal(err)
+	}
+
+	// The digests of pulled bundles are those of the pushed bundle manifests.
+	pushed := map[string]bool{}
+	for _, b := range pulled.Packages[0].Channels[0].Bundles {
+		pushed["oci://example.com/repo@"+b.Digest.String()] = true
+	}
+	if len(pushed) != len(bundles) {
+		t.Fatalf("expected %d pushed bundles, got %d", len(bundles), len(pushed))
+	}
+	cfg, err := pulled.ToFBC(ctx, "example.com/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range cfg.Bundles {
+		if !pushed[b.Image] {
+			t.Errorf("expected bundle %s image %s to reference a pushed bundle manifest", b.Name, b.Image)
+		}
+	}
+}
+
+// countingFetcher counts the content fetched from a content.Fetcher.
+type countingFetcher struct {
+	content.Fetcher
+	fetched int
+}
+
+func (f *countingFetcher) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	f.fetched++
+	return f.Fetcher.Fetch(ctx, desc)
+}
+
+func TestFindPackage(t *testing.T) {
+	ctx := context.Background()
+	c := pkg.Catalog{Packages: []pkg.Package{
+		{Metadata: pkg.PackageMetadata{Name: "bar"}},
+		{Metadata: pkg.PackageMetadata{Name: "baz"}},
+		{Metadata: pkg.PackageMetadata{Name: "foo"}},
+	}}
+	store := memory.New()
+	desc, err := client.Push(ctx, &c, store, progress.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catArt, err := FetchArtifact(ctx, store, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Catalogs pushed before package indexes were added have none. Their
+	// package manifests are listed in package name order here so that the
+	// number of manifests fetched by the scan is known.
+	unindexed := catArt
+	unindexed.Blobs = nil
+	pkgDescs := map[string]ocispec.Descriptor{}
+	for _, b := range catArt.Blobs {
+		switch {
+		case pkg.BlobRole(b) == pkg.BlobRolePackageIndex:
+		case b.MediaType == ocispec.MediaTypeArtifactManifest:
+			art, err := FetchArtifact(ctx, store, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkgDescs[art.Annotations[pkg.AnnotationKeyName]] = b
+		default:
+			unindexed.Blobs = append(unindexed.Blobs, b)
+		}
+	}
+	if len(pkgDescs)+len(unindexed.Blobs) == len(catArt.Blobs) {
+		t.Fatal("expected the pushed catalog to have a package index")
+	}
+	for _, name := range []string{"bar", "baz", "foo"} {
+		unindexed.Blobs = append(unindexed.Blobs, pkgDescs[name])
+	}
+
+	for _, tc := range []struct {
+		name        string
+		catalog     ocispec.Artifact
+		pkgName     string
+		wantFetched int
+		wantErr     string
+	}{
+		{name: "package index", catalog: catArt, pkgName: "foo", wantFetched: 1},
+		{name: "not in package index", catalog: catArt, pkgName: "qux", wantFetched: 1, wantErr: `package "qux" not found in catalog`},
+		{name: "scan stops at the package", catalog: unindexed, pkgName: "baz", wantFetched: 2},
+		{name: "scan fetches every package", catalog: unindexed, pkgName: "foo", wantFetched: 3},
+		{name: "not found by scan", catalog: unindexed, pkgName: "qux", wantFetched: 3, wantErr: `package "qux" not found in catalog`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := &countingFetcher{Fetcher: store}
+			pkgDesc, err := findPackage(ctx, src, tc.catalog, tc.pkgName)
+			if src.fetched != tc.wantFetched {
+				t.Errorf("expected %d fetches, got %d", tc.wantFetched, src.fetched)
+			}
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkgArt, err := FetchArtifact(ctx, store, *pkgDesc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := FetchPackage(ctx, store, pkgArt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Metadata.Name != tc.pkgName {
+				t.Errorf("expected package %q, got %q", tc.pkgName, p.Metadata.Name)
+			}
+		})
+	}
+}
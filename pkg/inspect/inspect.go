@@ -12,175 +12,135 @@ import (
 	"github.com/docker/distribution/manifest/manifestlist"
 	"github.com/docker/distribution/manifest/schema2"
 	"github.com/nlepage/go-tarfs"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/yaml"
 	"oras.land/oras-go/v2/content"
 
 	pkg "github.com/joelanford/olm-oci/api/v1"
 )
 
-func Inspect(ctx context.Context, repo content.ReadOnlyStorage, desc ocispec.Descriptor) error {
-	return inspect(ctx, repo, desc, "")
+// Node is a single descriptor in an inspected artifact graph, along with its
+// decoded metadata (if its media type is understood) and its children.
+//
+// Nodes for the same descriptor that are reachable from multiple parents are
+// shared, so callers rendering a Node tree can detect repeated subtrees by
+// digest.
+type Node struct {
+	Descriptor   ocispec.Descriptor `json:"descriptor"`
+	ArtifactType string             `json:"artifactType,omitempty"`
+	Annotations  map[string]string  `json:"annotations,omitempty"`
+	Metadata     any                `json:"metadata,omitempty"`
+	Children     []*Node            `json:"children,omitempty"`
 }
 
-func inspect(ctx context.Context, target content.ReadOnlyStorage, d ocispec.Descriptor, indent string) error {
+// File describes a single regular file found in a bundle content or image
+// layer blob.
+type File struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+	Size int64  `json:"size"`
+}
+
+// Options configure how Inspect walks an artifact graph.
+type Options struct {
+	// Depth limits how many levels below the root descriptor are walked.
+	// Nodes at the depth limit are reported by descriptor only, without
+	// being fetched. Zero means unlimited.
+	Depth int
+
+	// MediaTypes, if non-empty, limits the leaf nodes in the result to those
+	// whose media type or artifact type is in the list. Manifests are always
+	// walked, but are pruned from the result if none of their descendants
+	// match.
+	MediaTypes []string
+}
+
+// Inspect fetches desc from target and recursively builds a Node tree
+// describing it and its successors.
+func Inspect(ctx context.Context, target content.ReadOnlyStorage, desc ocispec.Descriptor, opts Options) (*Node, error) {
+	i := inspector{
+		target:     target,
+		depth:      opts.Depth,
+		mediaTypes: sets.New[string](opts.MediaTypes...),
+		nodes:      map[nodeKey]*Node{},
+	}
+	return i.inspect(ctx, desc, 0)
+}
+
+type nodeKey struct {
+	digest    digest.Digest
+	remaining int
+}
+
+type inspector struct {
+	target     content.ReadOnlyStorage
+	depth      int
+	mediaTypes sets.Set[string]
+	nodes      map[nodeKey]*Node
+}
+
+func (i *inspector) inspect(ctx context.Context, d ocispec.Descriptor, level int) (*Node, error) {
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return nil, ctx.Err()
 	default:
 	}
-	
-	fmt.Printf("%s- target: %v\n", target)
-	fmt.Printf("%s- Media Type: %v\n", indent, d.MediaType)
-	fmt.Printf("%s  Digest: %v\n", indent, d.Digest)
-	fmt.Printf("%s  Size: %v\n", indent, d.Size)
 
-	rc, err := target.Fetch(ctx, d)
+	remaining := -1
+	if i.depth > 0 {
+		remaining = i.depth - level
+	}
+	key := nodeKey{digest: d.Digest, remaining: remaining}
+	if n, ok := i.nodes[key]; ok {
+		return n, nil
+	}
+
+	n := &Node{Descriptor: d}
+	if !isManifest(d.MediaType) {
+		// A blob's annotations are those of its descriptor, such as its
+		// title and role.
+		n.Annotations = d.Annotations
+	}
+	i.nodes[key] = n
+	if remaining == 0 || (!isManifest(d.MediaType) && !i.selected(n)) {
+		return n, nil
+	}
+	if !isManifest(d.MediaType) && !isKnownBlob(d.MediaType) {
+		return n, nil
+	}
+
+	rc, err := i.target.Fetch(ctx, d)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("fetch %s: %v", d.Digest, err)
 	}
 	defer rc.Close()
 
+	var children []ocispec.Descriptor
 	switch d.MediaType {
 	case ocispec.MediaTypeArtifactManifest:
 		a, err := DecodeArtifact(rc)
 		if err != nil {
-			return err
-		}
-		fmt.Printf("%s  Artifact Type: %v\n", indent, a.ArtifactType)
-		fmt.Printf("%s  Artifact Annotations: %#v\n", indent, a.Annotations)
-		fmt.Printf("%s  Artifact Blobs:\n", indent)
-		for _, blob := range a.Blobs {
-			if err := inspect(ctx, target, blob, fmt.Sprintf("%s    ", indent)); err != nil {
-				return err
-			}
-		}
-	case pkg.MediaTypePackageMetadata:
-		m, err := DecodePackageMetadata(rc)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("%s  Package Metadata:\n", indent)
-		fmt.Printf("%s    Name: %s\n", indent, m.Name)
-		if m.DisplayName != "" {
-			fmt.Printf("%s    DisplayName: %s\n", indent, m.DisplayName)
-		}
-		if len(m.Keywords) > 0 {
-			fmt.Printf("%s    Keywords: %s\n", indent, m.Keywords)
-		}
-		if len(m.URLs) > 0 {
-			fmt.Printf("%s    URLs: %s\n", indent, m.URLs)
-		}
-		if len(m.Maintainers) > 0 {
-			fmt.Printf("%s    Maintainers: %s\n", indent, m.Maintainers)
-		}
-	case pkg.MediaTypeChannelMetadata:
-		m, err := DecodeChannelMetadata(rc)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("%s  Channel Metadata:\n", indent)
-		fmt.Printf("%s    Name: %s\n", indent, m.Name)
-	case pkg.MediaTypeBundleMetadata:
-		m, err := DecodeBundleMetadata(rc)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("%s  Bundle Metadata:\n", indent)
-		fmt.Printf("%s    Package: %s\n", indent, m.Package)
-		fmt.Printf("%s    Version: %s\n", indent, m.Version)
-		fmt.Printf("%s    Release: %d\n", indent, m.Release)
-	case pkg.MediaTypeUpgradeEdges:
-		edges, err := DecodeUpgradeEdges(rc)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("%s  Upgrade Edges:\n", indent)
-		for from, to := range edges {
-			fmt.Printf("%s    - From: %s\n", indent, from)
-			fmt.Printf("%s      To: %s\n", indent, strings.Join(to, ", "))
-		}
-	case pkg.MediaTypeRelatedImages:
-		relatedImages, err := DecodeRelatedImages(rc)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("%s  Related Images:\n", indent)
-		for _, image := range relatedImages {
-			fmt.Printf("%s    - Image: %s\n", indent, image.Image)
-			if image.Name != "" {
-				fmt.Printf("%s      Name: %s\n", indent, image.Name)
-			}
-		}
-	case pkg.MediaTypeBundleContent:
-		bc, err := DecodeBundleContent(rc)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("%s  Bundle Content:\n", indent)
-		if err := fs.WalkDir(bc.FS, ".", func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if d.IsDir() {
-				return nil
-			}
-			stat, err := d.Info()
-			if err != nil {
-				return err
-			}
-			fmt.Printf("%s    - Path: %s\n", indent, path)
-			fmt.Printf("%s      Mode: %s\n", indent, stat.Mode())
-			fmt.Printf("%s      Size: %d\n", indent, stat.Size())
-
-			return nil
-		}); err != nil {
-			return err
-		}
-	case pkg.MediaTypeProperties:
-		properties, err := DecodeProperties(rc)
-		if err != nil {
-			return err
-		}
-		if len(properties) > 0 {
-			fmt.Printf("%s  Properties:\n", indent)
-			for _, p := range properties {
-				fmt.Printf("%s    Type: %s\n", indent, p.Type)
-				fmt.Printf("%s    Value: %s\n", indent, string(p.Value))
-			}
-		}
-	case pkg.MediaTypeConstraints:
-		constraints, err := DecodeConstraints(rc)
-		if err != nil {
-			return err
-		}
-		if len(constraints) > 0 {
-			fmt.Printf("%s  Constraints:\n", indent)
-			for _, c := range constraints {
-				fmt.Printf("%s    Type: %s\n", indent, c.Type)
-				fmt.Printf("%s    Value: %s\n", indent, string(c.Value))
-			}
+			return nil, err
 		}
+		n.ArtifactType = a.ArtifactType
+		n.Annotations = a.Annotations
+		children = a.Blobs
 	case ocispec.MediaTypeImageIndex:
-		var i ocispec.Index
-		if err := json.NewDecoder(rc).Decode(&i); err != nil {
-			return err
-		}
-		fmt.Printf("%s  Image Index Annotations: %#v\n", indent, i.Annotations)
-		fmt.Printf("%s  Image Index Manifests:\n", indent)
-		for _, blob := range i.Manifests {
-			if err := inspect(ctx, target, blob, fmt.Sprintf("%s    ", indent)); err != nil {
-				return err
-			}
+		var idx ocispec.Index
+		if err := json.NewDecoder(rc).Decode(&idx); err != nil {
+			return nil, err
 		}
+		n.Annotations = idx.Annotations
+		children = idx.Manifests
 	case manifestlist.MediaTypeManifestList:
 		var m manifestlist.ManifestList
 		if err := json.NewDecoder(rc).Decode(&m); err != nil {
-			return err
+			return nil, err
 		}
-		fmt.Printf("%s  Manifest List Manifests:\n", indent)
 		for _, blob := range m.Manifests {
-			desc := ocispec.Descriptor{
+			children = append(children, ocispec.Descriptor{
 				MediaType:   blob.MediaType,
 				Digest:      blob.Digest,
 				Size:        blob.Size,
@@ -193,135 +153,179 @@ func inspect(ctx context.Context, target content.ReadOnlyStorage, d ocispec.Desc
 					OSFeatures:   blob.Platform.OSFeatures,
 					Variant:      blob.Platform.Variant,
 				},
-			}
-			if err := inspect(ctx, target, desc, fmt.Sprintf("%s    ", indent)); err != nil {
-				return err
-			}
+			})
 		}
 	case ocispec.MediaTypeImageManifest:
 		var m ocispec.Manifest
 		if err := json.NewDecoder(rc).Decode(&m); err != nil {
-			return err
-		}
-		fmt.Printf("%s  Image Config:\n", indent)
-		if err := inspect(ctx, target, m.Config, fmt.Sprintf("%s    ", indent)); err != nil {
-			return err
-		}
-		fmt.Printf("%s  Image Manifest Layers:\n", indent)
-		for _, blob := range m.Layers {
-			if err := inspect(ctx, target, blob, fmt.Sprintf("%s    ", indent)); err != nil {
-				return err
-			}
+			return nil, err
 		}
+		n.Annotations = m.Annotations
+		children = append([]ocispec.Descriptor{m.Config}, m.Layers...)
 	case schema2.MediaTypeManifest:
 		var m schema2.Manifest
 		if err := json.NewDecoder(rc).Decode(&m); err != nil {
-			return err
+			return nil, err
 		}
-		configDesc := ocispec.Descriptor{
+		children = append(children, ocispec.Descriptor{
 			MediaType:   m.Config.MediaType,
 			Digest:      m.Config.Digest,
 			Size:        m.Config.Size,
 			URLs:        m.Config.URLs,
 			Annotations: m.Config.Annotations,
 			Platform:    m.Config.Platform,
-		}
-		fmt.Printf("%s  Image Config:\n", indent)
-		if err := inspect(ctx, target, configDesc, fmt.Sprintf("%s    ", indent)); err != nil {
-			return err
-		}
-		fmt.Printf("%s  Image Manifest Layers:\n", indent)
+		})
 		for _, blob := range m.Layers {
-			blobDesc := ocispec.Descriptor{
+			children = append(children, ocispec.Descriptor{
 				MediaType:   blob.MediaType,
 				Digest:      blob.Digest,
 				Size:        blob.Size,
 				URLs:        blob.URLs,
 				Annotations: blob.Annotations,
 				Platform:    blob.Platform,
-			}
-			if err := inspect(ctx, target, blobDesc, fmt.Sprintf("%s    ", indent)); err != nil {
-				return err
-			}
+			})
 		}
-	case schema2.MediaTypeLayer, ocispec.MediaTypeImageLayer:
-		gzr, err := gzip.NewReader(rc)
-		if err != nil {
-			return fmt.Errorf("read gzip: %v", err)
-		}
-		tfs, err := tarfs.New(gzr)
-		if err != nil {
-			return fmt.Errorf("read tar: %v", err)
+	default:
+		if n.Metadata, err = decodeMetadata(d.MediaType, rc); err != nil {
+			return nil, fmt.Errorf("decode %s: %v", d.Digest, err)
 		}
-		fmt.Printf("%s  File Content:\n", indent)
-		if err := fs.WalkDir(tfs, ".", func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if d.IsDir() {
-				return nil
-			}
-			stat, err := d.Info()
-			if err != nil {
-				return err
-			}
-			fmt.Printf("%s    - Path: %s\n", indent, path)
-			fmt.Printf("%s      Mode: %s\n", indent, stat.Mode())
-			fmt.Printf("%s      Size: %d\n", indent, stat.Size())
+		return n, nil
+	}
 
-			return nil
-		}); err != nil {
-			return err
-		}
-	case ocispec.MediaTypeImageConfig, schema2.MediaTypeImageConfig:
-		var c ocispec.Image
-		if err := json.NewDecoder(rc).Decode(&c); err != nil {
-			return err
-		}
-		fmt.Printf("%s  Author: %s\n", indent, c.Author)
-		if c.Created != nil {
-			fmt.Printf("%s  Created: %s\n", indent, c.Created)
+	for _, child := range children {
+		cn, err := i.inspect(ctx, child, level+1)
+		if err != nil {
+			return nil, err
 		}
-		fmt.Printf("%s  OS: %s\n", indent, c.OS)
-		if c.OSVersion != "" {
-			fmt.Printf("%s  OS Version: %s\n", indent, c.OSVersion)
+		if i.selected(cn) || len(cn.Children) > 0 {
+			n.Children = append(n.Children, cn)
 		}
-		if len(c.OSFeatures) > 0 {
-			fmt.Printf("%s  OS Features: [%s]\n", indent, strings.Join(c.OSFeatures, ","))
+	}
+	return n, nil
+}
+
+func (i *inspector) selected(n *Node) bool {
+	if i.mediaTypes.Len() == 0 {
+		return true
+	}
+	return i.mediaTypes.Has(n.Descriptor.MediaType) || (n.ArtifactType != "" && i.mediaTypes.Has(n.ArtifactType))
+}
+
+func isManifest(mediaType string) bool {
+	switch mediaType {
+	case ocispec.MediaTypeArtifactManifest,
+		ocispec.MediaTypeImageIndex,
+		ocispec.MediaTypeImageManifest,
+		manifestlist.MediaTypeManifestList,
+		schema2.MediaTypeManifest:
+		return true
+	}
+	return false
+}
+
+func isKnownBlob(mediaType string) bool {
+	switch mediaType {
+	case pkg.MediaTypeCatalogMetadata,
+		pkg.MediaTypePackageIndex,
+		pkg.MediaTypePackageMetadata,
+		pkg.MediaTypeChannelMetadata,
+		pkg.MediaTypeBundleMetadata,
+		pkg.MediaTypeUpgradeEdges,
+		pkg.MediaTypeUpgradeSkips,
+		pkg.MediaTypeChannelUpgradeEdges,
+		pkg.MediaTypeRelatedImages,
+		pkg.MediaTypeBundleContent,
+		pkg.MediaTypeProperties,
+		pkg.MediaTypeConstraints,
+		pkg.MediaTypeIconPNG,
+		pkg.MediaTypeIconJPEG,
+		pkg.MediaTypeIconGIF,
+		pkg.MediaTypeIconWebP,
+		pkg.MediaTypeIconSVG,
+		schema2.MediaTypeLayer,
+		ocispec.MediaTypeImageLayerGzip,
+		ocispec.MediaTypeImageConfig,
+		schema2.MediaTypeImageConfig:
+		return true
+	}
+	return false
+}
+
+func decodeMetadata(mediaType string, r io.Reader) (any, error) {
+	switch mediaType {
+	case pkg.MediaTypeCatalogMetadata:
+		return DecodeCatalogMetadata(r)
+	case pkg.MediaTypePackageIndex:
+		return DecodePackageIndex(r)
+	case pkg.MediaTypePackageMetadata:
+		return DecodePackageMetadata(r)
+	case pkg.MediaTypeChannelMetadata:
+		return DecodeChannelMetadata(r)
+	case pkg.MediaTypeBundleMetadata:
+		return DecodeBundleMetadata(r)
+	case pkg.MediaTypeUpgradeEdges:
+		return DecodeUpgradeEdges(r)
+	case pkg.MediaTypeUpgradeSkips:
+		return DecodeUpgradeSkips(r)
+	case pkg.MediaTypeChannelUpgradeEdges:
+		return DecodeChannelUpgradeEdges(r)
+	case pkg.MediaTypeRelatedImages:
+		return DecodeRelatedImages(r)
+	case pkg.MediaTypeProperties:
+		return DecodeProperties(r)
+	case pkg.MediaTypeConstraints:
+		return DecodeConstraints(r)
+	case pkg.MediaTypeIconPNG, pkg.MediaTypeIconJPEG, pkg.MediaTypeIconGIF, pkg.MediaTypeIconWebP, pkg.MediaTypeIconSVG:
+		icon, err := DecodeIcon(mediaType, r)
+		if err != nil {
+			return nil, err
 		}
-		fmt.Printf("%s  Architecture: %s\n", indent, c.Architecture)
-		fmt.Printf("%s  RootFS:\n", indent)
-		fmt.Printf("%s      Type: %s\n", indent, c.RootFS.Type)
-		fmt.Printf("%s      DiffIDs:\n", indent)
-		for _, id := range c.RootFS.DiffIDs {
-			fmt.Printf("%s          %s\n", indent, id)
+		return icon.Info()
+	case pkg.MediaTypeBundleContent:
+		bc, err := DecodeBundleContent(r)
+		if err != nil {
+			return nil, err
 		}
-		fmt.Printf("%s  Config:\n", indent)
-		if len(c.Config.Labels) > 0 {
-			fmt.Printf("%s      Labels: %s\n", indent, c.Config.Labels)
+		return listFiles(bc.FS)
+	case schema2.MediaTypeLayer, ocispec.MediaTypeImageLayerGzip:
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("read gzip: %v", err)
 		}
-		fmt.Printf("%s      User: %s\n", indent, c.Config.User)
-		if len(c.Config.Cmd) > 0 {
-			fmt.Printf("%s      Cmd: %s\n", indent, c.Config.Cmd)
+		tfs, err := tarfs.New(gzr)
+		if err != nil {
+			return nil, fmt.Errorf("read tar: %v", err)
 		}
-		fmt.Printf("%s      Env:\n", indent)
-		for _, env := range c.Config.Env {
-			fmt.Printf("%s          %s\n", indent, env)
+		return listFiles(tfs)
+	case ocispec.MediaTypeImageConfig, schema2.MediaTypeImageConfig:
+		var c ocispec.Image
+		if err := json.NewDecoder(r).Decode(&c); err != nil {
+			return nil, err
 		}
-		fmt.Printf("%s      Entrypoint: %s\n", indent, c.Config.Entrypoint)
-		if len(c.Config.ExposedPorts) > 0 {
-			fmt.Printf("%s      ExposedPorts: %s\n", indent, c.Config.ExposedPorts)
+		return c, nil
+	}
+	return nil, nil
+}
+
+func listFiles(fsys fs.FS) ([]File, error) {
+	var files []File
+	if err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
-		fmt.Printf("%s      WorkingDir: %s\n", indent, c.Config.WorkingDir)
-		if len(c.Config.Volumes) > 0 {
-			fmt.Printf("%s      Volumes: %s\n", indent, c.Config.Volumes)
+		if d.IsDir() {
+			return nil
 		}
-		if c.Config.StopSignal != "" {
-			fmt.Printf("%s      StopSignal: %s\n", indent, c.Config.StopSignal)
+		stat, err := d.Info()
+		if err != nil {
+			return err
 		}
-
+		files = append(files, File{Path: path, Mode: stat.Mode().String(), Size: stat.Size()})
+		return nil
+	}); err != nil {
+		return nil, err
 	}
-	return nil
+	return files, nil
 }
 
 func JSONDecode(r io.Reader, obj any) error {
@@ -342,6 +346,18 @@ func DecodeArtifact(r io.Reader) (ocispec.Artifact, error) {
 	return a, err
 }
 
+func DecodeCatalogMetadata(r io.Reader) (pkg.CatalogMetadata, error) {
+	var v pkg.CatalogMetadata
+	err := YAMLDecode(r, &v)
+	return v, err
+}
+
+func DecodePackageIndex(r io.Reader) (pkg.PackageIndex, error) {
+	var v pkg.PackageIndex
+	err := YAMLDecode(r, &v)
+	return v, err
+}
+
 func DecodePackageMetadata(r io.Reader) (pkg.PackageMetadata, error) {
 	var v pkg.PackageMetadata
 	err := YAMLDecode(r, &v)
@@ -366,6 +382,18 @@ func DecodeUpgradeEdges(r io.Reader) (pkg.UpgradeEdges, error) {
 	return v, err
 }
 
+func DecodeUpgradeSkips(r io.Reader) (pkg.UpgradeSkips, error) {
+	var v pkg.UpgradeSkips
+	err := YAMLDecode(r, &v)
+	return v, err
+}
+
+func DecodeChannelUpgradeEdges(r io.Reader) (pkg.ChannelUpgradeEdges, error) {
+	var v pkg.ChannelUpgradeEdges
+	err := YAMLDecode(r, &v)
+	return v, err
+}
+
 func DecodeRelatedImages(r io.Reader) (pkg.RelatedImages, error) {
 	var v pkg.RelatedImages
 	err := YAMLDecode(r, &v)
@@ -397,9 +425,12 @@ func DecodeBundleContent(r io.Reader) (pkg.BundleContent, error) {
 }
 
 func DecodeIcon(mediaType string, r io.Reader) (pkg.Icon, error) {
+	if !pkg.IsIconMediaType(mediaType) {
+		return pkg.Icon{}, fmt.Errorf("unsupported icon media type %q", mediaType)
+	}
 	data, err := io.ReadAll(r)
 	if err != nil {
-		return pkg.Icon{}, nil
+		return pkg.Icon{}, fmt.Errorf("read icon: %v", err)
 	}
 	return pkg.Icon{ImageMediaType: mediaType, ImageData: data}, nil
 }
@@ -0,0 +1,177 @@
+package inspect
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/content/memory"
+
+	pkg "github.com/joelanford/olm-oci/api/v1"
+	"github.com/joelanford/olm-oci/pkg/client"
+	"github.com/joelanford/olm-oci/pkg/progress"
+)
+
+// pushTestCatalog pushes a catalog with one package to a memory store. Each
+// of the package's channels has the same two bundles, the first of which is
+// deprecated.
+func pushTestCatalog(t *testing.T, channels ...string) (content.ReadOnlyStorage, ocispec.Descriptor) {
+	t.Helper()
+	var bundles []pkg.Bundle
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		b, err := pkg.LoadBundleFS(fstest.MapFS{
+			"manifests/configmap.yaml":  {Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n")},
+			"metadata/annotations.yaml": {Data: []byte("annotations:\n  io.operatorframework.bundle.package: foo\n  io.operatorframework.bundle.version: " + version + "\n")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bundles = append(bundles, *b)
+	}
+	bundles[0].Metadata.Deprecation = &pkg.Deprecation{Message: "use 1.1.0"}
+	p := pkg.Package{Metadata: pkg.PackageMetadata{Name: "foo", DefaultChannel: channels[0]}}
+	for _, ch := range channels {
+		p.Channels = append(p.Channels, pkg.Channel{Metadata: pkg.ChannelMetadata{Name: ch}, Bundles: bundles})
+	}
+	c := pkg.Catalog{
+		Metadata: pkg.CatalogMetadata{DisplayName: "Test Catalog"},
+		Packages: []pkg.Package{p},
+	}
+	store := memory.New()
+	desc, err := client.Push(context.Background(), &c, store, progress.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, desc
+}
+
+// walkNodes calls f with each node in the tree rooted at n and its level.
+func walkNodes(n *Node, level int, f func(n *Node, level int)) {
+	f(n, level)
+	for _, c := range n.Children {
+		walkNodes(c, level+1, f)
+	}
+}
+
+func TestInspectDepth(t *testing.T) {
+	store, desc := pushTestCatalog(t, "stable")
+	for _, tc := range []struct {
+		name  string
+		depth int
+		// wantLevels is the number of levels in the tree.
+		wantLevels int
+	}{
+		// catalog, package, channel, bundle, bundle blobs
+		{name: "unlimited", depth: 0, wantLevels: 5},
+		{name: "root only", depth: 1, wantLevels: 2},
+		{name: "to the channels", depth: 3, wantLevels: 4},
+		{name: "beyond the leaves", depth: 10, wantLevels: 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := Inspect(context.Background(), store, desc, Options{Depth: tc.depth})
+			if err != nil {
+				t.Fatal(err)
+			}
+			levels := 0
+			walkNodes(n, 0, func(n *Node, level int) {
+				if level+1 > levels {
+					levels = level + 1
+				}
+				if tc.depth > 0 && level == tc.depth {
+					// Nodes at the depth limit are not fetched.
+					if n.ArtifactType != "" || n.Metadata != nil || len(n.Children) > 0 {
+						t.Errorf("expected %s at the depth limit to be reported by descriptor only, got %+v", n.Descriptor.Digest, n)
+					}
+				}
+			})
+			if levels != tc.wantLevels {
+				t.Errorf("expected %d levels, got %d", tc.wantLevels, levels)
+			}
+		})
+	}
+}
+
+func TestInspectMediaTypes(t *testing.T) {
+	store, desc := pushTestCatalog(t, "stable")
+	for _, tc := range []struct {
+		name       string
+		mediaTypes []string
+		// wantLeaves counts the leaves of the tree by media type, or by
+		// artifact type for manifests.
+		wantLeaves map[string]int
+	}{
+		{
+			name:       "blob media type",
+			mediaTypes: []string{pkg.MediaTypeBundleMetadata},
+			wantLeaves: map[string]int{pkg.MediaTypeBundleMetadata: 2},
+		},
+		{
+			name:       "several blob media types",
+			mediaTypes: []string{pkg.MediaTypeCatalogMetadata, pkg.MediaTypeChannelMetadata},
+			wantLeaves: map[string]int{pkg.MediaTypeCatalogMetadata: 1, pkg.MediaTypeChannelMetadata: 1},
+		},
+		{
+			name:       "artifact type",
+			mediaTypes: []string{pkg.MediaTypeBundle},
+			wantLeaves: map[string]int{pkg.MediaTypeBundle: 2},
+		},
+		{
+			name:       "no match",
+			mediaTypes: []string{"application/vnd.example.unknown"},
+			wantLeaves: map[string]int{pkg.MediaTypeCatalog: 1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n, err := Inspect(context.Background(), store, desc, Options{MediaTypes: tc.mediaTypes})
+			if err != nil {
+				t.Fatal(err)
+			}
+			leaves := map[string]int{}
+			walkNodes(n, 0, func(n *Node, _ int) {
+				if len(n.Children) > 0 {
+					return
+				}
+				key := n.Descriptor.MediaType
+				if n.ArtifactType != "" {
+					key = n.ArtifactType
+				}
+				leaves[key]++
+			})
+			if len(leaves) != len(tc.wantLeaves) {
+				t.Errorf("expected leaves %v, got %v", tc.wantLeaves, leaves)
+			}
+			for k, v := range tc.wantLeaves {
+				if leaves[k] != v {
+					t.Errorf("expected leaves %v, got %v", tc.wantLeaves, leaves)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestInspectSharedNodes(t *testing.T) {
+	store, desc := pushTestCatalog(t, "stable", "fast")
+	n, err := Inspect(context.Background(), store, desc, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both channels have the same bundles, so their nodes are shared.
+	nodes := map[string]*Node{}
+	shared := 0
+	walkNodes(n, 0, func(n *Node, _ int) {
+		key := n.Descriptor.Digest.String()
+		if prev, ok := nodes[key]; ok {
+			if prev != n {
+				t.Errorf("expected the nodes of %s to be shared", key)
+			}
+			shared++
+		}
+		nodes[key] = n
+	})
+	if shared == 0 {
+		t.Error("expected shared nodes")
+	}
+}
@@ -0,0 +1,360 @@
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"sigs.k8s.io/yaml"
+
+	pkg "github.com/joelanford/olm-oci/api/v1"
+)
+
+// WriteJSON writes n to w as indented JSON.
+func WriteJSON(w io.Writer, n *Node) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(n)
+}
+
+// WriteYAML writes n to w as YAML.
+func WriteYAML(w io.Writer, n *Node) error {
+	data, err := yaml.Marshal(n)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteTree writes n to w as an indented, human-readable tree. Subtrees that
+// were already written are printed once more by descriptor only and marked as
+// shared.
+func WriteTree(w io.Writer, n *Node) error {
+	tw := treeWriter{w: w, seen: map[digest.Digest]struct{}{}}
+	tw.write(n, "")
+	return tw.err
+}
+
+// WriteSummary writes one line per distinct bundle found in n, containing the
+// bundle's package, version, release and digest, and whether it is
+// deprecated.
+func WriteSummary(w io.Writer, n *Node) error {
+	seen := map[digest.Digest]struct{}{}
+	var lines []string
+	var walk func(*Node)
+	walk = func(n *Node) {
+		if _, ok := seen[n.Descriptor.Digest]; ok {
+			return
+		}
+		seen[n.Descriptor.Digest] = struct{}{}
+		if n.ArtifactType == pkg.MediaTypeBundle {
+			lines = append(lines, bundleSummary(n))
+			return
+		}
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	walk(n)
+	sort.Strings(lines)
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(w, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bundleSummary(n *Node) string {
+	packageName := n.Annotations[pkg.AnnotationKeyBundlePackage]
+	version := n.Annotations[pkg.AnnotationKeyBundleVersion]
+	release := n.Annotations[pkg.AnnotationKeyBundleRelease]
+	deprecated := ""
+	for _, c := range n.Children {
+		if m, ok := c.Metadata.(pkg.BundleMetadata); ok {
+			packageName = m.Package
+			version = m.Version.String()
+			release = fmt.Sprintf("%d", m.Release)
+			if m.Deprecation != nil {
+				deprecated = " (deprecated)"
+			}
+		}
+	}
+	if packageName == "" {
+		packageName = "<unknown>"
+	}
+	return fmt.Sprintf("%s %s-%s %s%s", packageName, version, release, n.Descriptor.Digest, deprecated)
+}
+
+type treeWriter struct {
+	w    io.Writer
+	seen map[digest.Digest]struct{}
+	err  error
+}
+
+func (t *treeWriter) printf(format string, args ...any) {
+	if t.err != nil {
+		return
+	}
+	_, t.err = fmt.Fprintf(t.w, format, args...)
+}
+
+func (t *treeWriter) write(n *Node, indent string) {
+	d := n.Descriptor
+	if _, ok := t.seen[d.Digest]; ok && (len(n.Children) > 0 || n.Metadata != nil) {
+		t.printf("%s- Digest: %s (shared, see above)\n", indent, d.Digest)
+		return
+	}
+	t.seen[d.Digest] = struct{}{}
+
+	t.printf("%s- Media Type: %s\n", indent, d.MediaType)
+	t.printf("%s  Digest: %s\n", indent, d.Digest)
+	t.printf("%s  Size: %d\n", indent, d.Size)
+	if n.ArtifactType != "" {
+		t.printf("%s  Artifact Type: %s\n", indent, n.ArtifactType)
+	}
+	if len(n.Annotations) > 0 {
+		t.printf("%s  Annotations:\n", indent)
+		keys := make([]string, 0, len(n.Annotations))
+		for k := range n.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			t.printf("%s    %s: %s\n", indent, k, n.Annotations[k])
+		}
+	}
+	t.writeMetadata(n.Metadata, indent)
+	if len(n.Children) > 0 {
+		t.printf("%s  Children:\n", indent)
+		for _, c := range n.Children {
+			t.write(c, indent+"    ")
+		}
+	}
+}
+
+func (t *treeWriter) writeDeprecation(d *pkg.Deprecation, indent string) {
+	if d != nil {
+		t.printf("%s    Deprecated: %s\n", indent, d.Message)
+	}
+}
+
+func (t *treeWriter) writeEdges(edges pkg.UpgradeEdges, indent string) {
+	for _, from := range sortedKeys(edges) {
+		t.printf("%s  - From: %s\n", indent, from)
+		t.printf("%s    To: %s\n", indent, joinVersionReleases(edges[from]))
+	}
+}
+
+// sortedKeys returns the keys of m from lowest to highest.
+func sortedKeys[V any](m map[pkg.VersionRelease]V) []pkg.VersionRelease {
+	keys := make([]pkg.VersionRelease, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Compare(keys[j]) < 0 })
+	return keys
+}
+
+func joinVersionReleases(vrs []pkg.VersionRelease) string {
+	strs := make([]string, len(vrs))
+	for i, vr := range vrs {
+		strs[i] = vr.String()
+	}
+	return strings.Join(strs, ", ")
+}
+
+func (t *treeWriter) writeMetadata(md any, indent string) {
+	switch m := md.(type) {
+	case pkg.CatalogMetadata:
+		t.printf("%s  Catalog Metadata:\n", indent)
+		for _, f := range []struct{ name, value string }{
+			{"DisplayName", m.DisplayName},
+			{"Publisher", m.Publisher},
+			{"Description", m.Description},
+			{"Homepage", m.Homepage},
+			{"Support", m.Support},
+		} {
+			if f.value != "" {
+				t.printf("%s    %s: %s\n", indent, f.name, f.value)
+			}
+		}
+	case pkg.PackageIndex:
+		t.printf("%s  Package Index:\n", indent)
+		names := make([]string, 0, len(m))
+		for name := range m {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			e := m[name]
+			t.printf("%s    - Name: %s\n", indent, name)
+			t.printf("%s      Digest: %s\n", indent, e.Digest)
+			if e.LatestVersion != nil {
+				t.printf("%s      Latest Version: %s\n", indent, e.LatestVersion)
+			}
+			if len(e.ChannelHeads) > 0 {
+				t.printf("%s      Channel Heads:\n", indent)
+				channels := make([]string, 0, len(e.ChannelHeads))
+				for ch := range e.ChannelHeads {
+					channels = append(channels, ch)
+				}
+				sort.Strings(channels)
+				for _, ch := range channels {
+					t.printf("%s        %s: %s\n", indent, ch, e.ChannelHeads[ch])
+				}
+			}
+			if len(e.ProvidedGVKs) > 0 {
+				t.printf("%s      Provided GVKs:\n", indent)
+				for _, gvk := range e.ProvidedGVKs {
+					t.printf("%s        %s/%s, Kind=%s\n", indent, gvk.Group, gvk.Version, gvk.Kind)
+				}
+			}
+		}
+	case pkg.PackageMetadata:
+		t.printf("%s  Package Metadata:\n", indent)
+		t.printf("%s    Name: %s\n", indent, m.Name)
+		if m.DisplayName != "" {
+			t.printf("%s    DisplayName: %s\n", indent, m.DisplayName)
+		}
+		if len(m.Keywords) > 0 {
+			t.printf("%s    Keywords: %s\n", indent, m.Keywords)
+		}
+		if len(m.URLs) > 0 {
+			t.printf("%s    URLs: %s\n", indent, m.URLs)
+		}
+		if len(m.Maintainers) > 0 {
+			t.printf("%s    Maintainers: %s\n", indent, m.Maintainers)
+		}
+		if m.Licenses != "" {
+			t.printf("%s    Licenses: %s\n", indent, m.Licenses)
+		}
+		t.writeDeprecation(m.Deprecation, indent)
+	case pkg.ChannelMetadata:
+		t.printf("%s  Channel Metadata:\n", indent)
+		t.printf("%s    Name: %s\n", indent, m.Name)
+		t.writeDeprecation(m.Deprecation, indent)
+	case pkg.BundleMetadata:
+		t.printf("%s  Bundle Metadata:\n", indent)
+		t.printf("%s    Package: %s\n", indent, m.Package)
+		t.printf("%s    Version: %s\n", indent, m.Version)
+		t.printf("%s    Release: %d\n", indent, m.Release)
+		t.writeDeprecation(m.Deprecation, indent)
+	case pkg.UpgradeEdges:
+		t.printf("%s  Upgrade Edges:\n", indent)
+		t.writeEdges(m, indent+"  ")
+	case pkg.UpgradeSkips:
+		t.printf("%s  Upgrade Skips:\n", indent)
+		for _, to := range sortedKeys(m) {
+			t.printf("%s    - To: %s\n", indent, to)
+			if len(m[to].Versions) > 0 {
+				t.printf("%s      Skips: %s\n", indent, joinVersionReleases(m[to].Versions))
+			}
+			if len(m[to].Names) > 0 {
+				t.printf("%s      Skipped Bundles: %s\n", indent, strings.Join(m[to].Names, ", "))
+			}
+			if m[to].Range != "" {
+				t.printf("%s      Skip Range: %s\n", indent, m[to].Range)
+			}
+		}
+	case pkg.ChannelUpgradeEdges:
+		t.printf("%s  Channel Upgrade Edges:\n", indent)
+		for _, e := range []struct {
+			name  string
+			edges pkg.UpgradeEdges
+		}{
+			{"Add", m.Add},
+			{"Remove", m.Remove},
+		} {
+			if len(e.edges) > 0 {
+				t.printf("%s    %s:\n", indent, e.name)
+				t.writeEdges(e.edges, indent+"    ")
+			}
+		}
+	case pkg.RelatedImages:
+		t.printf("%s  Related Images:\n", indent)
+		for _, image := range m {
+			t.printf("%s    - Image: %s\n", indent, image.Image)
+			if image.Name != "" {
+				t.printf("%s      Name: %s\n", indent, image.Name)
+			}
+		}
+	case pkg.Properties:
+		if len(m) > 0 {
+			t.printf("%s  Properties:\n", indent)
+			for _, p := range m {
+				t.printf("%s    - Type: %s\n", indent, p.Type)
+				t.printf("%s      Value: %s\n", indent, string(p.Value))
+			}
+		}
+	case pkg.Constraints:
+		if len(m) > 0 {
+			t.printf("%s  Constraints:\n", indent)
+			for _, c := range m {
+				t.printf("%s    - Type: %s\n", indent, c.Type)
+				t.printf("%s      Value: %s\n", indent, string(c.Value))
+			}
+		}
+	case pkg.IconInfo:
+		t.printf("%s  Icon:\n", indent)
+		t.printf("%s    Media Type: %s\n", indent, m.MediaType)
+		t.printf("%s    Size: %d\n", indent, m.Size)
+		if m.Width > 0 && m.Height > 0 {
+			t.printf("%s    Dimensions: %dx%d\n", indent, m.Width, m.Height)
+		}
+	case []File:
+		t.printf("%s  Files:\n", indent)
+		for _, f := range m {
+			t.printf("%s    - Path: %s\n", indent, f.Path)
+			t.printf("%s      Mode: %s\n", indent, f.Mode)
+			t.printf("%s      Size: %d\n", indent, f.Size)
+		}
+	case ocispec.Image:
+		t.printf("%s  Author: %s\n", indent, m.Author)
+		if m.Created != nil {
+			t.printf("%s  Created: %s\n", indent, m.Created)
+		}
+		t.printf("%s  OS: %s\n", indent, m.OS)
+		if m.OSVersion != "" {
+			t.printf("%s  OS Version: %s\n", indent, m.OSVersion)
+		}
+		if len(m.OSFeatures) > 0 {
+			t.printf("%s  OS Features: [%s]\n", indent, strings.Join(m.OSFeatures, ","))
+		}
+		t.printf("%s  Architecture: %s\n", indent, m.Architecture)
+		t.printf("%s  RootFS:\n", indent)
+		t.printf("%s      Type: %s\n", indent, m.RootFS.Type)
+		t.printf("%s      DiffIDs:\n", indent)
+		for _, id := range m.RootFS.DiffIDs {
+			t.printf("%s          %s\n", indent, id)
+		}
+		t.printf("%s  Config:\n", indent)
+		if len(m.Config.Labels) > 0 {
+			t.printf("%s      Labels: %s\n", indent, m.Config.Labels)
+		}
+		t.printf("%s      User: %s\n", indent, m.Config.User)
+		if len(m.Config.Cmd) > 0 {
+			t.printf("%s      Cmd: %s\n", indent, m.Config.Cmd)
+		}
+		t.printf("%s      Env:\n", indent)
+		for _, env := range m.Config.Env {
+			t.printf("%s          %s\n", indent, env)
+		}
+		t.printf("%s      Entrypoint: %s\n", indent, m.Config.Entrypoint)
+		if len(m.Config.ExposedPorts) > 0 {
+			t.printf("%s      ExposedPorts: %s\n", indent, m.Config.ExposedPorts)
+		}
+		t.printf("%s      WorkingDir: %s\n", indent, m.Config.WorkingDir)
+		if len(m.Config.Volumes) > 0 {
+			t.printf("%s      Volumes: %s\n", indent, m.Config.Volumes)
+		}
+		if m.Config.StopSignal != "" {
+			t.printf("%s      StopSignal: %s\n", indent, m.Config.StopSignal)
+		}
+	}
+}
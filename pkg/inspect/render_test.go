@@ -0,0 +1,227 @@
+package inspect
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"sigs.k8s.io/yaml"
+
+	pkg "github.com/joelanford/olm-oci/api/v1"
+)
+
+func testDescriptor(mediaType, data string) ocispec.Descriptor {
+	return ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromString(data), Size: int64(len(data))}
+}
+
+// testTree returns a channel node with two bundles that share their related
+// images node. The first bundle is deprecated and the second bundle was not
+// fetched, so it is only described by its annotations.
+func testTree() *Node {
+	relatedImages := &Node{
+		Descriptor: testDescriptor(pkg.MediaTypeRelatedImages, "related-images"),
+		Metadata:   pkg.RelatedImages{{Image: "example.com/foo:v1", Name: "foo"}},
+	}
+	return &Node{
+		Descriptor:   testDescriptor(ocispec.MediaTypeArtifactManifest, "channel"),
+		ArtifactType: pkg.MediaTypeChannel,
+		Annotations:  map[string]string{pkg.AnnotationKeyName: "stable"},
+		Children: []*Node{
+			{
+				Descriptor: testDescriptor(pkg.MediaTypeChannelMetadata, "channel-metadata"),
+				Metadata:   pkg.ChannelMetadata{Name: "stable", Deprecation: &pkg.Deprecation{Message: "use fast"}},
+			},
+			{
+				Descriptor:   testDescriptor(ocispec.MediaTypeArtifactManifest, "bundle-1"),
+				ArtifactType: pkg.MediaTypeBundle,
+				Children: []*Node{
+					{
+						Descriptor: testDescriptor(pkg.MediaTypeBundleMetadata, "bundle-1-metadata"),
+						Metadata: pkg.BundleMetadata{
+							Package:     "foo",
+							Version:     semver.MustParse("1.0.0"),
+							Release:     1,
+							Deprecation: &pkg.Deprecation{Message: "use 1.1.0"},
+						},
+					},
+					relatedImages,
+				},
+			},
+			{
+				Descriptor:   testDescriptor(ocispec.MediaTypeArtifactManifest, "bundle-2"),
+				ArtifactType: pkg.MediaTypeBundle,
+				Annotations: map[string]string{
+					pkg.AnnotationKeyBundlePackage: "foo",
+					pkg.AnnotationKeyBundleVersion: "1.1.0",
+					pkg.AnnotationKeyBundleRelease: "0",
+				},
+				Children: []*Node{relatedImages},
+			},
+		},
+	}
+}
+
+func TestWriteTree(t *testing.T) {
+	n := testTree()
+	d := func(data string) string { return digest.FromString(data).String() }
+	want := strings.Join([]string{
+		"- Media Type: " + ocispec.MediaTypeArtifactManifest,
+		"  Digest: " + d("channel"),
+		"  Size: 7",
+		"  Artifact Type: " + pkg.MediaTypeChannel,
+		"  Annotations:",
+		"    " + pkg.AnnotationKeyName + ": stable",
+		"  Children:",
+		"    - Media Type: " + pkg.MediaTypeChannelMetadata,
+		"      Digest: " + d("channel-metadata"),
+		"      Size: 16",
+		"      Channel Metadata:",
+		"        Name: stable",
+		"        Deprecated: use fast",
+		"    - Media Type: " + ocispec.MediaTypeArtifactManifest,
+		"      Digest: " + d("bundle-1"),
+		"      Size: 8",
+		"      Artifact Type: " + pkg.MediaTypeBundle,
+		"      Children:",
+		"        - Media Type: " + pkg.MediaTypeBundleMetadata,
+		"          Digest: " + d("bundle-1-metadata"),
+		"          Size: 17",
+		"          Bundle Metadata:",
+		"            Package: foo",
+		"            Version: 1.0.0",
+		"            Release: 1",
+		"            Deprecated: use 1.1.0",
+		"        - Media Type: " + pkg.MediaTypeRelatedImages,
+		"          Digest: " + d("related-images"),
+		"          Size: 14",
+		"          Related Images:",
+		"            - Image: example.com/foo:v1",
+		"              Name: foo",
+		"    - Media Type: " + ocispec.MediaTypeArtifactManifest,
+		"      Digest: " + d("bundle-2"),
+		"      Size: 8",
+		"      Artifact Type: " + pkg.MediaTypeBundle,
+		"      Annotations:",
+		"        " + pkg.AnnotationKeyBundlePackage + ": foo",
+		"        " + pkg.AnnotationKeyBundleRelease + ": 0",
+		"        " + pkg.AnnotationKeyBundleVersion + ": 1.1.0",
+		"      Children:",
+		"        - Digest: " + d("related-images") + " (shared, see above)",
+		"",
+	}, "\n")
+
+	var buf bytes.Buffer
+	if err := WriteTree(&buf, n); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	d := func(data string) string { return digest.FromString(data).String() }
+	for _, tc := range []struct {
+		name string
+		node *Node
+		want string
+	}{
+		{
+			name: "bundles",
+			node: testTree(),
+			want: "foo 1.0.0-1 " + d("bundle-1") + " (deprecated)\n" +
+				"foo 1.1.0-0 " + d("bundle-2") + "\n",
+		},
+		{
+			name: "shared bundle",
+			node: func() *Node {
+				n := testTree()
+				n.Children = append(n.Children, n.Children[1])
+				return n
+			}(),
+			want: "foo 1.0.0-1 " + d("bundle-1") + " (deprecated)\n" +
+				"foo 1.1.0-0 " + d("bundle-2") + "\n",
+		},
+		{
+			name: "bundle without metadata",
+			node: &Node{
+				Descriptor:   testDescriptor(ocispec.MediaTypeArtifactManifest, "bundle"),
+				ArtifactType: pkg.MediaTypeBundle,
+			},
+			want: "<unknown> - " + d("bundle") + "\n",
+		},
+		{
+			name: "no bundles",
+			node: &Node{Descriptor: testDescriptor(pkg.MediaTypeCatalogMetadata, "catalog-metadata")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteSummary(&buf, tc.node); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteJSONAndYAML(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		write     func(*bytes.Buffer, *Node) error
+		unmarshal func([]byte, any) error
+	}{
+		{
+			name:      "json",
+			write:     func(buf *bytes.Buffer, n *Node) error { return WriteJSON(buf, n) },
+			unmarshal: json.Unmarshal,
+		},
+		{
+			name:      "yaml",
+			write:     func(buf *bytes.Buffer, n *Node) error { return WriteYAML(buf, n) },
+			unmarshal: func(data []byte, v any) error { return yaml.Unmarshal(data, v) },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n := testTree()
+			var buf bytes.Buffer
+			if err := tc.write(&buf, n); err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				Descriptor   ocispec.Descriptor `json:"descriptor"`
+				ArtifactType string             `json:"artifactType"`
+				Annotations  map[string]string  `json:"annotations"`
+				Children     []struct {
+					Descriptor ocispec.Descriptor `json:"descriptor"`
+					Metadata   map[string]any     `json:"metadata"`
+					Children   []json.RawMessage  `json:"children"`
+				} `json:"children"`
+			}
+			if err := tc.unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("unmarshal %s: %v", buf.String(), err)
+			}
+			if got.Descriptor.Digest != n.Descriptor.Digest || got.ArtifactType != n.ArtifactType || got.Annotations[pkg.AnnotationKeyName] != "stable" {
+				t.Errorf("expected the channel node, got %+v", got)
+			}
+			if len(got.Children) != len(n.Children) {
+				t.Fatalf("expected %d children, got %d", len(n.Children), len(got.Children))
+			}
+			if name := got.Children[0].Metadata["name"]; name != "stable" {
+				t.Errorf("expected channel metadata name %q, got %v", "stable", name)
+			}
+			// Shared nodes are written in full wherever they appear.
+			for i := 1; i < 3; i++ {
+				if len(got.Children[i].Children) == 0 {
+					t.Errorf("expected the children of bundle %d", i)
+				}
+			}
+		})
+	}
+}
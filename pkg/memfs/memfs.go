@@ -0,0 +1,222 @@
+// Package memfs provides a small in-memory file system for content that is
+// assembled in memory, such as unpacked image layers.
+package memfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	_ fs.FS         = &FS{}
+	_ fs.ReadFileFS = &FS{}
+)
+
+// FS is an in-memory fs.FS. Directories are implied by the files below
+// them (with mode 0555, like testing/fstest.MapFS), and may also be added
+// explicitly to set their mode and time. The zero value is not usable; use
+// New.
+type FS struct {
+	entries map[string]*entry
+}
+
+type entry struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// New returns an empty FS.
+func New() *FS {
+	return &FS{entries: map[string]*entry{}}
+}
+
+// Copy returns a copy of fsys. Copying an FS is cheap, because file
+// contents are shared.
+func Copy(fsys fs.FS) (*FS, error) {
+	out := New()
+	if fsys == nil {
+		return out, nil
+	}
+	if m, ok := fsys.(*FS); ok {
+		for name, e := range m.entries {
+			out.entries[name] = e
+		}
+		return out, nil
+	}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			out.Mkdir(name, info.Mode().Perm(), info.ModTime())
+		case d.Type().IsRegular():
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			out.WriteFile(name, data, info.Mode().Perm(), info.ModTime())
+		}
+		return nil
+	})
+	return out, err
+}
+
+// WriteFile adds the regular file name, replacing any existing entry.
+func (m *FS) WriteFile(name string, data []byte, perm fs.FileMode, modTime time.Time) {
+	m.entries[name] = &entry{data: data, mode: perm.Perm(), modTime: modTime}
+}
+
+// Mkdir adds the directory name, replacing any existing entry.
+func (m *FS) Mkdir(name string, perm fs.FileMode, modTime time.Time) {
+	m.entries[name] = &entry{mode: fs.ModeDir | perm.Perm(), modTime: modTime}
+}
+
+// RemoveAll removes name and everything below it.
+func (m *FS) RemoveAll(name string) {
+	delete(m.entries, name)
+	m.RemoveContents(name)
+}
+
+// RemoveContents removes everything below the directory name, or everything
+// in m if name is ".".
+func (m *FS) RemoveContents(name string) {
+	for n := range m.entries {
+		if name == "." || strings.HasPrefix(n, name+"/") {
+			delete(m.entries, n)
+		}
+	}
+}
+
+func (m *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := m.entries[name]
+	if ok && !e.mode.IsDir() {
+		return &openFile{info: fileInfo{name: path.Base(name), entry: e}, r: bytes.NewReader(e.data)}, nil
+	}
+	children := m.children(name)
+	if !ok && name != "." && len(children) == 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if !ok {
+		e = &entry{mode: fs.ModeDir | 0555}
+	}
+	return &openDir{info: fileInfo{name: path.Base(name), entry: e}, entries: children}, nil
+}
+
+func (m *FS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := m.entries[name]
+	if !ok || e.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), e.data...), nil
+}
+
+// children returns the entries directly below the directory dir, sorted by
+// name, including directories that are only implied by their contents.
+func (m *FS) children(dir string) []fs.DirEntry {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	byName := map[string]fs.DirEntry{}
+	for name, e := range m.entries {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		child, _, implied := strings.Cut(strings.TrimPrefix(name, prefix), "/")
+		if child == "" {
+			continue
+		}
+		if !implied {
+			byName[child] = fs.FileInfoToDirEntry(fileInfo{name: child, entry: e})
+		} else if _, ok := byName[child]; !ok {
+			if explicit, ok := m.entries[prefix+child]; ok {
+				e = explicit
+			} else {
+				e = &entry{mode: fs.ModeDir | 0555}
+			}
+			byName[child] = fs.FileInfoToDirEntry(fileInfo{name: child, entry: e})
+		}
+	}
+	out := make([]fs.DirEntry, 0, len(byName))
+	for _, d := range byName {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+	return out
+}
+
+type fileInfo struct {
+	name string
+	*entry
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return int64(len(fi.data)) }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fileInfo) Sys() any           { return nil }
+
+type openFile struct {
+	info fileInfo
+	r    *bytes.Reader
+}
+
+func (f *openFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *openFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *openFile) Close() error               { return nil }
+
+func (f *openFile) Seek(offset int64, whence int) (int64, error) {
+	return f.r.Seek(offset, whence)
+}
+
+func (f *openFile) ReadAt(b []byte, offset int64) (int, error) {
+	return f.r.ReadAt(b, offset)
+}
+
+type openDir struct {
+	info    fileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *openDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *openDir) Close() error               { return nil }
+
+func (d *openDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errors.New("is a directory")}
+}
+
+func (d *openDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
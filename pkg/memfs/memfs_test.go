@@ -0,0 +1,84 @@
+package memfs
+
+import (
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestFS(t *testing.T) {
+	m := New()
+	m.Mkdir("a", 0700, time.Unix(1, 0))
+	m.WriteFile("a/b.txt", []byte("b"), 0644, time.Time{})
+	m.WriteFile("c/d/e.txt", []byte("e"), 0600, time.Time{})
+	m.WriteFile("f.txt", nil, 0644, time.Time{})
+	if err := fstest.TestFS(m, "a/b.txt", "c/d/e.txt", "f.txt"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name string
+		mode fs.FileMode
+	}{
+		{name: "a", mode: fs.ModeDir | 0700},
+		{name: "c", mode: fs.ModeDir | 0555},
+		{name: "c/d/e.txt", mode: 0600},
+	} {
+		info, err := fs.Stat(m, tc.name)
+		if err != nil {
+			t.Fatalf("stat %s: %v", tc.name, err)
+		}
+		if info.Mode() != tc.mode {
+			t.Errorf("%s: expected mode %v, got %v", tc.name, tc.mode, info.Mode())
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		remove func(*FS)
+		want   []string
+	}{
+		{name: "all", remove: func(m *FS) { m.RemoveAll("a") }, want: []string{"ab.txt"}},
+		{name: "contents", remove: func(m *FS) { m.RemoveContents("a") }, want: []string{"a", "ab.txt"}},
+		{name: "root contents", remove: func(m *FS) { m.RemoveContents(".") }, want: nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := New()
+			m.Mkdir("a", 0755, time.Time{})
+			m.WriteFile("a/b.txt", nil, 0644, time.Time{})
+			m.WriteFile("ab.txt", nil, 0644, time.Time{})
+			tc.remove(m)
+			var got []string
+			if err := fs.WalkDir(m, ".", func(name string, _ fs.DirEntry, err error) error {
+				if name != "." {
+					got = append(got, name)
+				}
+				return err
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src := fstest.MapFS{"a/b.txt": {Data: []byte("b"), Mode: 0644}}
+	m, err := Copy(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Copy(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.RemoveAll("a")
+	if data, err := fs.ReadFile(m, "a/b.txt"); err != nil || string(data) != "b" {
+		t.Errorf("expected copy to leave original intact, got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,194 @@
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/pkg/jsonmessage"
+	dockerprogress "github.com/docker/docker/pkg/progress"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/mattn/go-isatty"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Reporter receives progress events for artifact graph transfers.
+// Implementations must be safe for concurrent use, since several transfers
+// may report to the same Reporter at once.
+type Reporter interface {
+	// Report handles a single event.
+	Report(Event)
+
+	// Close flushes any buffered output. No events may be reported after
+	// Close is called.
+	Close() error
+}
+
+type EventType string
+
+const (
+	// EventStart is reported when a descriptor's content starts transferring.
+	EventStart EventType = "start"
+	// EventProgress is reported periodically while a descriptor's content is
+	// transferring. Current holds the number of bytes transferred so far.
+	EventProgress EventType = "progress"
+	// EventSkipped is reported for descriptors that were not transferred
+	// because they were already present at the destination.
+	EventSkipped EventType = "skipped"
+	// EventComplete is reported when a descriptor's content has been
+	// transferred.
+	EventComplete EventType = "complete"
+	// EventSummary is reported once at the end of each transfer.
+	EventSummary EventType = "summary"
+)
+
+// Event is a single progress event. Descriptor is set for every event type
+// except EventSummary, for which Summary is set.
+type Event struct {
+	Time       time.Time           `json:"time"`
+	Type       EventType           `json:"type"`
+	Action     string              `json:"action,omitempty"`
+	Descriptor *ocispec.Descriptor `json:"descriptor,omitempty"`
+	Current    int64               `json:"current,omitempty"`
+	Summary    *Summary            `json:"summary,omitempty"`
+}
+
+// Summary totals the descriptors that were transferred or skipped while
+// copying the graph rooted at Root.
+type Summary struct {
+	Root             ocispec.Descriptor `json:"root"`
+	BlobsTransferred int                `json:"blobsTransferred"`
+	BytesTransferred int64              `json:"bytesTransferred"`
+	BlobsSkipped     int                `json:"blobsSkipped"`
+	BytesSkipped     int64              `json:"bytesSkipped"`
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%s: transferred %d blobs (%d bytes), skipped %d already present (%d bytes)",
+		s.Root.Digest, s.BlobsTransferred, s.BytesTransferred, s.BlobsSkipped, s.BytesSkipped)
+}
+
+// Discard is a Reporter that drops every event.
+var Discard Reporter = discard{}
+
+type discard struct{}
+
+func (discard) Report(Event) {}
+func (discard) Close() error { return nil }
+
+// NewTerminalReporter renders progress bars to f using docker's progress
+// renderer. If f is not a terminal, progress is rendered as plain lines.
+// Transfer summaries are written when the reporter is closed.
+func NewTerminalReporter(f *os.File) Reporter {
+	pr, pw := io.Pipe()
+	isTTY := isatty.IsTerminal(f.Fd())
+	r := &terminalReporter{
+		f:    f,
+		pw:   pw,
+		out:  streamformatter.NewJSONProgressOutput(pw, !isTTY),
+		done: make(chan error, 1),
+	}
+	go func() {
+		r.done <- jsonmessage.DisplayJSONMessagesStream(pr, f, f.Fd(), isTTY, nil)
+	}()
+	return r
+}
+
+type terminalReporter struct {
+	f    *os.File
+	pw   *io.PipeWriter
+	out  dockerprogress.Output
+	done chan error
+
+	mu        sync.Mutex
+	summaries []Summary
+}
+
+func (r *terminalReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var p dockerprogress.Progress
+	switch e.Type {
+	case EventProgress:
+		p = dockerprogress.Progress{Action: e.Action, Current: e.Current, Total: e.Descriptor.Size}
+	case EventSkipped:
+		p = dockerprogress.Progress{Action: "Artifact is up to date"}
+	case EventComplete:
+		p = dockerprogress.Progress{Action: "Complete", Current: e.Descriptor.Size, Total: e.Descriptor.Size}
+	case EventSummary:
+		r.summaries = append(r.summaries, *e.Summary)
+		return
+	default:
+		return
+	}
+	p.ID = IDForDesc(*e.Descriptor)
+	_ = r.out.WriteProgress(p)
+}
+
+func (r *terminalReporter) Close() error {
+	if err := r.pw.Close(); err != nil {
+		return fmt.Errorf("close progress writer: %v", err)
+	}
+	if err := <-r.done; err != nil {
+		return fmt.Errorf("display progress: %v", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.summaries {
+		if _, err := fmt.Fprintln(r.f, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewLogReporter writes one plain line to w for each descriptor that
+// completes or is skipped, and for each transfer summary.
+func NewLogReporter(w io.Writer) Reporter {
+	return &logReporter{w: w}
+}
+
+type logReporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (r *logReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch e.Type {
+	case EventComplete:
+		fmt.Fprintf(r.w, "%s %s %s (%d bytes): done\n", e.Action, e.Descriptor.MediaType, e.Descriptor.Digest, e.Descriptor.Size)
+	case EventSkipped:
+		fmt.Fprintf(r.w, "%s %s (%d bytes): already present\n", e.Descriptor.MediaType, e.Descriptor.Digest, e.Descriptor.Size)
+	case EventSummary:
+		fmt.Fprintln(r.w, e.Summary)
+	}
+}
+
+func (r *logReporter) Close() error { return nil }
+
+// NewJSONReporter writes every event to w as newline-delimited JSON.
+func NewJSONReporter(w io.Writer) Reporter {
+	return &jsonReporter{enc: json.NewEncoder(w)}
+}
+
+type jsonReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (r *jsonReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	_ = r.enc.Encode(e)
+}
+
+func (r *jsonReporter) Close() error { return nil }
@@ -0,0 +1,174 @@
+package progress
+
+import (
+	"bufio"
+	"bytes"
+	_ "crypto/sha256"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var (
+	testDesc = ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeArtifactManifest,
+		Digest:    digest.FromString("manifest"),
+		Size:      8,
+	}
+	testSummary = Summary{
+		Root:             testDesc,
+		BlobsTransferred: 2,
+		BytesTransferred: 1024,
+		BlobsSkipped:     1,
+		BytesSkipped:     8,
+	}
+)
+
+// testEvents returns one event of each type for testDesc.
+func testEvents() []Event {
+	desc := testDesc
+	summary := testSummary
+	return []Event{
+		{Type: EventStart, Action: "Pushing", Descriptor: &desc},
+		{Type: EventProgress, Action: "Pushing", Descriptor: &desc, Current: 4},
+		{Type: EventComplete, Action: "Pushing", Descriptor: &desc, Current: 8},
+		{Type: EventSkipped, Action: "Pushing", Descriptor: &desc},
+		{Type: EventSummary, Action: "Pushing", Summary: &summary},
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	want := testDesc.Digest.String() + ": transferred 2 blobs (1024 bytes), skipped 1 already present (8 bytes)"
+	if got := testSummary.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestTerminalReporter(t *testing.T) {
+	// Output to a file that is not a terminal is rendered as plain lines,
+	// which leave out progress and completion.
+	f, err := os.Create(filepath.Join(t.TempDir(), "progress"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := NewTerminalReporter(f)
+	for _, e := range testEvents() {
+		r.Report(e)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Summaries are written after the progress output.
+	want := IDForDesc(testDesc) + ": Artifact is up to date\n" + testSummary.String() + "\n"
+	if string(got) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestLogReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewLogReporter(&buf)
+	for _, e := range testEvents() {
+		r.Report(e)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"Pushing " + testDesc.MediaType + " " + testDesc.Digest.String() + " (8 bytes): done",
+		testDesc.MediaType + " " + testDesc.Digest.String() + " (8 bytes): already present",
+		testSummary.String(),
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestJSONReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewJSONReporter(&buf)
+	events := testEvents()
+	fixed := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	events[0].Time = fixed
+	before := time.Now()
+	for _, e := range events {
+		r.Report(e)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Event
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var e Event
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			t.Fatalf("unmarshal %s: %v", s.Text(), err)
+		}
+		got = append(got, e)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("expected %d events, got %d", len(events), len(got))
+	}
+	for i, e := range got {
+		switch {
+		case i == 0 && !e.Time.Equal(fixed):
+			t.Errorf("expected the time of event %d to be kept, got %v", i, e.Time)
+		case i > 0 && e.Time.Before(before):
+			t.Errorf("expected event %d to be timed when it was reported, got %v", i, e.Time)
+		}
+		e.Time = events[i].Time
+		if !reflect.DeepEqual(e, events[i]) {
+			t.Errorf("expected event %d to be %+v, got %+v", i, events[i], e)
+		}
+	}
+}
+
+func TestReportersConcurrentUse(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		reporter  func(*bytes.Buffer) Reporter
+		wantLines int
+	}{
+		// complete, skipped and summary
+		{name: "log", reporter: func(buf *bytes.Buffer) Reporter { return NewLogReporter(buf) }, wantLines: 3},
+		{name: "json", reporter: func(buf *bytes.Buffer) Reporter { return NewJSONReporter(buf) }, wantLines: 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			const transfers = 10
+			var buf bytes.Buffer
+			r := tc.reporter(&buf)
+			var wg sync.WaitGroup
+			for i := 0; i < transfers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for _, e := range testEvents() {
+						r.Report(e)
+					}
+				}()
+			}
+			wg.Wait()
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Count(buf.String(), "\n"); got != transfers*tc.wantLines {
+				t.Errorf("expected %d lines, got %d", transfers*tc.wantLines, got)
+			}
+		})
+	}
+}
@@ -3,22 +3,30 @@ package progress
 import (
 	"context"
 	"io"
+	"time"
 
-	"github.com/docker/docker/pkg/progress"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"oras.land/oras-go/v2/content"
 )
 
-func NewStore(base content.ReadOnlyStorage, out progress.Output) content.ReadOnlyStorage {
+// progressInterval is the minimum time between two progress events for the
+// same descriptor.
+const progressInterval = 100 * time.Millisecond
+
+// NewStore wraps base so that every fetched descriptor reports its progress
+// to r, labelled with action (e.g. "Pushing" or "Pulling").
+func NewStore(base content.ReadOnlyStorage, r Reporter, action string) content.ReadOnlyStorage {
 	return &Store{
-		base: base,
-		out:  out,
+		base:     base,
+		reporter: r,
+		action:   action,
 	}
 }
 
 type Store struct {
-	base content.ReadOnlyStorage
-	out  progress.Output
+	base     content.ReadOnlyStorage
+	reporter Reporter
+	action   string
 }
 
 func (s *Store) Exists(ctx context.Context, desc ocispec.Descriptor) (bool, error) {
@@ -30,8 +38,42 @@ func (s *Store) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadClos
 	if err != nil {
 		return nil, err
 	}
+	s.reporter.Report(Event{
+		Type:       EventStart,
+		Action:     s.action,
+		Descriptor: &desc,
+	})
+	return &progressReader{
+		ReadCloser: rc,
+		reporter:   s.reporter,
+		action:     s.action,
+		desc:       desc,
+	}, nil
+}
+
+type progressReader struct {
+	io.ReadCloser
+	reporter Reporter
+	action   string
+	desc     ocispec.Descriptor
 
-	return progress.NewProgressReader(rc, s.out, desc.Size, IDForDesc(desc), "Pushing "), nil
+	current    int64
+	lastReport time.Time
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.current += int64(n)
+	if now := time.Now(); err != nil || now.Sub(r.lastReport) >= progressInterval {
+		r.lastReport = now
+		r.reporter.Report(Event{
+			Type:       EventProgress,
+			Action:     r.action,
+			Descriptor: &r.desc,
+			Current:    r.current,
+		})
+	}
+	return n, err
 }
 
 func IDForDesc(desc ocispec.Descriptor) string {
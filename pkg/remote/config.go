@@ -0,0 +1,151 @@
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/adrg/xdg"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultConfigFile is the path of the registry configuration file that is
+// loaded when no other path is given.
+var DefaultConfigFile = filepath.Join(xdg.ConfigHome, "olm-oci", "registries.yaml")
+
+// DefaultConfig is the configuration used by NewRepository and the other
+// package-level helpers that talk to remote registries.
+var DefaultConfig = NewConfig()
+
+// Config configures how remote registries are accessed.
+type Config struct {
+	// Registries holds per-registry settings, keyed by registry host (and
+	// port, if not the default).
+	Registries map[string]RegistryConfig `json:"registries,omitempty"`
+
+	// Default holds settings that apply to every registry. Settings in
+	// Registries take precedence for their registry.
+	Default RegistryConfig `json:"-"`
+
+	// UseRegistriesConf applies the mirror, prefix rewrite and blocking
+	// rules from containers registries.conf when pulling.
+	UseRegistriesConf bool `json:"useRegistriesConf,omitempty"`
+
+	// RegistriesConfPath, if set, is read instead of the system and user
+	// registries.conf files. Setting it implies UseRegistriesConf.
+	RegistriesConfPath string `json:"registriesConfPath,omitempty"`
+
+	// Retry configures how failed blob and manifest operations are retried.
+	Retry RetryPolicy `json:"retry"`
+
+	// UploadChunkSize is the size of the chunks that blobs at least this
+	// large are uploaded in. Chunked uploads are resumed from the last
+	// chunk the registry received when they are retried.
+	UploadChunkSize int64 `json:"uploadChunkSize"`
+
+	// Credential, if set, supplies registry credentials instead of the
+	// environment and the docker and containers auth files.
+	Credential func(ctx context.Context, hostport string) (auth.Credential, error) `json:"-"`
+}
+
+// NewConfig returns a configuration with the default retry policy and
+// upload chunk size.
+func NewConfig() *Config {
+	return &Config{
+		Retry:           DefaultRetryPolicy,
+		UploadChunkSize: DefaultChunkSize,
+	}
+}
+
+// RegistryConfig configures the transport used to access a single registry.
+type RegistryConfig struct {
+	// PlainHTTP accesses the registry with HTTP instead of HTTPS.
+	PlainHTTP bool `json:"plainHTTP,omitempty"`
+
+	// InsecureSkipTLSVerify disables verification of the registry's
+	// certificate chain and host name.
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
+
+	// CAFile is a PEM bundle of certificate authorities trusted in addition
+	// to the system trust store.
+	CAFile string `json:"caFile,omitempty"`
+
+	// CertFile and KeyFile are a PEM client certificate and key presented to
+	// the registry.
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+}
+
+// LoadConfig reads a registry configuration file on top of the defaults
+// from NewConfig. A missing file is not an error.
+func LoadConfig(path string) (*Config, error) {
+	cfg := NewConfig()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// ForRegistry returns the effective configuration for the registry at
+// hostport.
+func (c *Config) ForRegistry(hostport string) RegistryConfig {
+	rc, ok := c.Registries[hostport]
+	if !ok {
+		return c.Default
+	}
+	rc.PlainHTTP = rc.PlainHTTP || c.Default.PlainHTTP
+	rc.InsecureSkipTLSVerify = rc.InsecureSkipTLSVerify || c.Default.InsecureSkipTLSVerify
+	if rc.CAFile == "" {
+		rc.CAFile = c.Default.CAFile
+	}
+	if rc.CertFile == "" && rc.KeyFile == "" {
+		rc.CertFile, rc.KeyFile = c.Default.CertFile, c.Default.KeyFile
+	}
+	return rc
+}
+
+// HTTPClient returns an HTTP client whose transport is configured with rc's
+// TLS settings.
+func (rc RegistryConfig) HTTPClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: rc.InsecureSkipTLSVerify,
+	}
+	if rc.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		caData, err := os.ReadFile(rc.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", rc.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if rc.CertFile != "" || rc.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(rc.CertFile, rc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,170 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/pkg/docker/config"
+	"github.com/containers/image/v5/types"
+	dockerconfig "github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	dockertypes "github.com/docker/cli/cli/config/types"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// EnvRegistryTokenPrefix is the prefix of environment variables that provide
+// a bearer token for a single registry. The rest of the variable name is the
+// registry host (and port), upper-cased, with every character that is not a
+// letter or digit replaced by an underscore. For example, a token for
+// "quay.io" is read from OLMOCI_REGISTRY_TOKEN_QUAY_IO and a token for
+// "localhost:5000" is read from OLMOCI_REGISTRY_TOKEN_LOCALHOST_5000.
+const EnvRegistryTokenPrefix = "OLMOCI_REGISTRY_TOKEN_"
+
+const dockerHubConfigKey = "https://index.docker.io/v1/"
+
+var (
+	loadOnce      sync.Once
+	dockerCfg     *configfile.ConfigFile
+	loadConfigErr error
+)
+
+func loadDockerConfig() (*configfile.ConfigFile, error) {
+	loadOnce.Do(func() {
+		dockerCfg, loadConfigErr = dockerconfig.Load(dockerconfig.Dir())
+	})
+	return dockerCfg, loadConfigErr
+}
+
+// getCredentials returns a credential function for repoName. Credentials are
+// looked up, in order, from:
+//  1. a bearer token in the registry's OLMOCI_REGISTRY_TOKEN_* variable
+//  2. the docker config file, including credHelpers and credsStore
+//  3. the containers auth files (e.g. those written by podman login)
+//
+// A docker config that cannot be read, or a credential helper that fails,
+// does not prevent the containers auth files from being used. Its error is
+// only returned if they have no credentials for the registry either.
+func getCredentials(repoName string) func(context.Context, string) (auth.Credential, error) {
+	return func(ctx context.Context, hostport string) (auth.Credential, error) {
+		if token, ok := os.LookupEnv(registryTokenEnvVar(hostport)); ok {
+			return auth.Credential{AccessToken: token}, nil
+		}
+
+		cred, dockerErr := dockerConfigCredential(hostport)
+		if dockerErr == nil && cred != auth.EmptyCredential {
+			return cred, nil
+		}
+
+		ref, err := reference.ParseNamed(repoName)
+		if err != nil {
+			return auth.Credential{}, err
+		}
+		authConfig, err := config.GetCredentialsForRef(nil, ref)
+		if err != nil {
+			if dockerErr != nil {
+				return auth.Credential{}, fmt.Errorf("%v; get containers credentials for %s: %v", dockerErr, hostport, err)
+			}
+			return auth.Credential{}, err
+		}
+		if authConfig == (types.DockerAuthConfig{}) && dockerErr != nil {
+			return auth.Credential{}, dockerErr
+		}
+		return auth.Credential{
+			Username:     authConfig.Username,
+			Password:     authConfig.Password,
+			RefreshToken: authConfig.IdentityToken,
+		}, nil
+	}
+}
+
+func dockerConfigCredential(hostport string) (auth.Credential, error) {
+	cfg, err := loadDockerConfig()
+	if err != nil {
+		return auth.Credential{}, fmt.Errorf("load docker config: %v", err)
+	}
+	key := dockerConfigKey(hostport)
+	authConfig, err := cfg.GetCredentialsStore(key).Get(key)
+	if err != nil {
+		return auth.Credential{}, fmt.Errorf("get credentials for %s: %v", hostport, err)
+	}
+	return auth.Credential{
+		Username:     authConfig.Username,
+		Password:     authConfig.Password,
+		RefreshToken: authConfig.IdentityToken,
+		AccessToken:  authConfig.RegistryToken,
+	}, nil
+}
+
+func dockerConfigKey(hostport string) string {
+	switch hostport {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return dockerHubConfigKey
+	}
+	return hostport
+}
+
+func registryTokenEnvVar(hostport string) string {
+	return EnvRegistryTokenPrefix + strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, hostport)
+}
+
+// Login verifies cred against the registry at hostport and, if the registry
+// accepts it, stores it in the docker config file (or the credential helper
+// configured for that registry).
+func Login(ctx context.Context, hostport string, cred auth.Credential) error {
+	reg, err := remote.NewRegistry(hostport)
+	if err != nil {
+		return err
+	}
+	rc := DefaultConfig.ForRegistry(hostport)
+	httpClient, err := rc.HTTPClient()
+	if err != nil {
+		return fmt.Errorf("configure client for registry %s: %v", hostport, err)
+	}
+	reg.PlainHTTP = rc.PlainHTTP
+	reg.Client = &auth.Client{
+		Client:     httpClient,
+		Credential: auth.StaticCredential(hostport, cred),
+	}
+	if err := reg.Ping(ctx); err != nil {
+		return fmt.Errorf("authenticate to %s: %v", hostport, err)
+	}
+
+	cfg, err := loadDockerConfig()
+	if err != nil {
+		return fmt.Errorf("load docker config: %v", err)
+	}
+	key := dockerConfigKey(hostport)
+	if err := cfg.GetCredentialsStore(key).Store(dockertypes.AuthConfig{
+		ServerAddress: key,
+		Username:      cred.Username,
+		Password:      cred.Password,
+		IdentityToken: cred.RefreshToken,
+	}); err != nil {
+		return fmt.Errorf("store credentials for %s: %v", hostport, err)
+	}
+	return cfg.Save()
+}
+
+// Logout removes the credentials stored for the registry at hostport.
+func Logout(hostport string) error {
+	cfg, err := loadDockerConfig()
+	if err != nil {
+		return fmt.Errorf("load docker config: %v", err)
+	}
+	key := dockerConfigKey(hostport)
+	if err := cfg.GetCredentialsStore(key).Erase(key); err != nil {
+		return fmt.Errorf("erase credentials for %s: %v", hostport, err)
+	}
+	return cfg.Save()
+}
@@ -0,0 +1,178 @@
+package remote
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/cli/cli/config/configfile"
+	dockertypes "github.com/docker/cli/cli/config/types"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+func TestRegistryTokenEnvVar(t *testing.T) {
+	for _, tc := range []struct {
+		hostport string
+		want     string
+	}{
+		{hostport: "quay.io", want: "OLMOCI_REGISTRY_TOKEN_QUAY_IO"},
+		{hostport: "localhost:5000", want: "OLMOCI_REGISTRY_TOKEN_LOCALHOST_5000"},
+		{hostport: "my-registry.example.com", want: "OLMOCI_REGISTRY_TOKEN_MY_REGISTRY_EXAMPLE_COM"},
+		{hostport: "[::1]:5000", want: "OLMOCI_REGISTRY_TOKEN____1__5000"},
+		{hostport: "régistry.io", want: "OLMOCI_REGISTRY_TOKEN_R_GISTRY_IO"},
+	} {
+		if got := registryTokenEnvVar(tc.hostport); got != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.hostport, tc.want, got)
+		}
+	}
+}
+
+// setDockerConfig replaces the docker config that credentials are looked up
+// in for the duration of the test.
+func setDockerConfig(t *testing.T, cfg *configfile.ConfigFile, err error) {
+	t.Helper()
+	loadDockerConfig()
+	oldCfg, oldErr := dockerCfg, loadConfigErr
+	dockerCfg, loadConfigErr = cfg, err
+	t.Cleanup(func() {
+		dockerCfg, loadConfigErr = oldCfg, oldErr
+	})
+}
+
+// setContainersAuthFile points the containers auth file lookup at a file with
+// content data for the duration of the test. No file is written if data is
+// empty.
+func setContainersAuthFile(t *testing.T, data string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, ".config"))
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	t.Setenv("DOCKER_CONFIG", "")
+	if data == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "containers"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "containers", "auth.json"), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetCredentials(t *testing.T) {
+	const host = "registry.example.com"
+	dockerAuth := func() *configfile.ConfigFile {
+		cfg := configfile.New(filepath.Join(t.TempDir(), "config.json"))
+		cfg.AuthConfigs[host] = dockertypes.AuthConfig{Username: "docker", Password: "docker-password"}
+		return cfg
+	}
+	failingHelper := func() *configfile.ConfigFile {
+		cfg := configfile.New(filepath.Join(t.TempDir(), "config.json"))
+		cfg.CredentialHelpers = map[string]string{host: "olmoci-test-missing-helper"}
+		return cfg
+	}
+	emptyDocker := func() *configfile.ConfigFile {
+		return configfile.New(filepath.Join(t.TempDir(), "config.json"))
+	}
+	containersAuth := `{"auths":{"` + host + `":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("podman:podman-password")) + `"}}}`
+	dockerCred := auth.Credential{Username: "docker", Password: "docker-password"}
+	containersCred := auth.Credential{Username: "podman", Password: "podman-password"}
+
+	for _, tc := range []struct {
+		name           string
+		token          string
+		dockerConfig   func() *configfile.ConfigFile
+		dockerLoadErr  error
+		containersAuth string
+		want           auth.Credential
+		wantErr        string
+	}{
+		{
+			name:           "token takes precedence",
+			token:          "token",
+			dockerConfig:   dockerAuth,
+			containersAuth: containersAuth,
+			want:           auth.Credential{AccessToken: "token"},
+		},
+		{
+			name:           "docker config takes precedence over containers auth file",
+			dockerConfig:   dockerAuth,
+			containersAuth: containersAuth,
+			want:           dockerCred,
+		},
+		{
+			name:           "containers auth file",
+			dockerConfig:   emptyDocker,
+			containersAuth: containersAuth,
+			want:           containersCred,
+		},
+		{
+			name:           "failing credential helper falls through to containers auth file",
+			dockerConfig:   failingHelper,
+			containersAuth: containersAuth,
+			want:           containersCred,
+		},
+		{
+			name:           "unloadable docker config falls through to containers auth file",
+			dockerLoadErr:  errors.New("invalid config"),
+			containersAuth: containersAuth,
+			want:           containersCred,
+		},
+		{
+			name:          "unloadable docker config without containers credentials",
+			dockerLoadErr: errors.New("invalid config"),
+			wantErr:       "load docker config: invalid config",
+		},
+		{
+			name:           "unloadable docker config and containers auth file",
+			dockerLoadErr:  errors.New("invalid config"),
+			containersAuth: "{",
+			wantErr:        "load docker config: invalid config; get containers credentials for " + host,
+		},
+		{
+			name:           "unloadable containers auth file",
+			dockerConfig:   emptyDocker,
+			containersAuth: "{",
+			wantErr:        "auth.json",
+		},
+		{
+			name:         "no credentials",
+			dockerConfig: emptyDocker,
+			want:         auth.EmptyCredential,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// An empty token is still a token, so the variable is unset
+			// (and restored by t.Setenv) unless the test case sets one.
+			t.Setenv(registryTokenEnvVar(host), tc.token)
+			if tc.token == "" {
+				os.Unsetenv(registryTokenEnvVar(host))
+			}
+			var cfg *configfile.ConfigFile
+			if tc.dockerConfig != nil {
+				cfg = tc.dockerConfig()
+			}
+			setDockerConfig(t, cfg, tc.dockerLoadErr)
+			setContainersAuthFile(t, tc.containersAuth)
+
+			got, err := getCredentials(host+"/foo/bar")(context.Background(), host)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"runtime"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/content"
+
+	"github.com/joelanford/olm-oci/pkg/memfs"
+	"github.com/joelanford/olm-oci/pkg/tar"
+)
+
+// Image is a container image whose layers have been unpacked.
+type Image struct {
+	Descriptor ocispec.Descriptor
+	Labels     map[string]string
+	FS         fs.FS
+}
+
+func FetchImage(ctx context.Context, imageRef string, include func(labels map[string]string, name string) bool) (*Image, error) {
+	return DefaultConfig.FetchImage(ctx, imageRef, include)
+}
+
+// FetchImage pulls the container image imageRef and unpacks its layers into
+// memory, keeping only the paths for which include returns true (or all
+// paths, if include is nil). include is given the image's config labels, so
+// that the paths to keep can depend on them. If imageRef is an image index, the manifest for
+// the current platform is used, falling back to linux/amd64 and then to the
+// first manifest.
+func (c *Config) FetchImage(ctx context.Context, imageRef string, include func(labels map[string]string, name string) bool) (*Image, error) {
+	repo, _, desc, err := c.ResolveNameAndReference(ctx, imageRef)
+	if err != nil {
+		return nil, err
+	}
+	manifestDesc := *desc
+	if manifestDesc.MediaType == ocispec.MediaTypeImageIndex || manifestDesc.MediaType == manifestlist.MediaTypeManifestList {
+		data, err := content.FetchAll(ctx, repo, manifestDesc)
+		if err != nil {
+			return nil, fmt.Errorf("fetch image index: %v", err)
+		}
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("decode image index: %v", err)
+		}
+		if manifestDesc, err = selectPlatform(index.Manifests); err != nil {
+			return nil, err
+		}
+	}
+	if manifestDesc.MediaType != ocispec.MediaTypeImageManifest && manifestDesc.MediaType != schema2.MediaTypeManifest {
+		return nil, fmt.Errorf("%s is not a container image: unexpected media type %q", imageRef, manifestDesc.MediaType)
+	}
+
+	data, err := content.FetchAll(ctx, repo, manifestDesc)
+	if err != nil {
+		return nil, fmt.Errorf("fetch image manifest: %v", err)
+	}
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("decode image manifest: %v", err)
+	}
+	configData, err := content.FetchAll(ctx, repo, manifest.Config)
+	if err != nil {
+		return nil, fmt.Errorf("fetch image config: %v", err)
+	}
+	var config ocispec.Image
+	if err := json.Unmarshal(configData, &config); err != nil {
+		return nil, fmt.Errorf("decode image config: %v", err)
+	}
+
+	img := &Image{
+		Descriptor: manifestDesc,
+		Labels:     config.Config.Labels,
+		FS:         memfs.New(),
+	}
+	var includeName func(name string) bool
+	if include != nil {
+		includeName = func(name string) bool { return include(img.Labels, name) }
+	}
+	for _, layer := range manifest.Layers {
+		if err := func() error {
+			rc, err := repo.Fetch(ctx, layer)
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			r, err := decompress(rc)
+			if err != nil {
+				return err
+			}
+			img.FS, err = tar.ApplyLayer(img.FS, r, includeName)
+			return err
+		}(); err != nil {
+			return nil, fmt.Errorf("unpack layer %s: %v", layer.Digest, err)
+		}
+	}
+	return img, nil
+}
+
+func selectPlatform(manifests []ocispec.Descriptor) (ocispec.Descriptor, error) {
+	if len(manifests) == 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("image index has no manifests")
+	}
+	for _, want := range []ocispec.Platform{{OS: runtime.GOOS, Architecture: runtime.GOARCH}, {OS: "linux", Architecture: "amd64"}} {
+		for _, m := range manifests {
+			if m.Platform != nil && m.Platform.OS == want.OS && m.Platform.Architecture == want.Architecture {
+				return m, nil
+			}
+		}
+	}
+	return manifests[0], nil
+}
+
+// decompress returns a reader for the uncompressed content of a layer that
+// may or may not be gzip-compressed.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+func PushImage(ctx context.Context, imageRef string, labels map[string]string, layers ...fs.FS) (ocispec.Descriptor, error) {
+	return DefaultConfig.PushImage(ctx, imageRef, labels, layers...)
+}
+
+// PushImage builds a linux/amd64 container image with one gzip-compressed
+// layer per entry in layers and the given config labels, and pushes it to
+// imageRef. If imageRef is tagged, the image is tagged as well.
+func (c *Config) PushImage(ctx context.Context, imageRef string, labels map[string]string, layers ...fs.FS) (ocispec.Descriptor, error) {
+	ref, err := reference.ParseNamed(imageRef)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	repo, err := c.NewRepository(ref.Name())
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	config := ocispec.Image{
+		Architecture: "amd64",
+		OS:           "linux",
+		Config:       ocispec.ImageConfig{Labels: labels},
+		RootFS:       ocispec.RootFS{Type: "layers"},
+	}
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+	}
+	for i, layer := range layers {
+		data, diffID, err := buildLayer(layer)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("build layer %d: %v", i, err)
+		}
+		desc := ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageLayerGzip,
+			Digest:    digest.FromBytes(data),
+			Size:      int64(len(data)),
+		}
+		if err := pushBlobIfNotExist(ctx, repo, desc, data); err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("push layer %s: %v", desc.Digest, err)
+		}
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, diffID)
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+
+	configData, err := json.Marshal(config)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	manifest.Config = ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageConfig,
+		Digest:    digest.FromBytes(configData),
+		Size:      int64(len(configData)),
+	}
+	if err := pushBlobIfNotExist(ctx, repo, manifest.Config, configData); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("push image config: %v", err)
+	}
+
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Digest:    digest.FromBytes(manifestData),
+		Size:      int64(len(manifestData)),
+	}
+	if tagged, ok := ref.(reference.Tagged); ok {
+		err = repo.PushReference(ctx, desc, bytes.NewReader(manifestData), tagged.Tag())
+	} else {
+		err = repo.Push(ctx, desc, bytes.NewReader(manifestData))
+	}
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("push image manifest: %v", err)
+	}
+	return desc, nil
+}
+
+// buildLayer returns the gzip-compressed tarball of fsys and the digest of
+// the uncompressed tarball.
+func buildLayer(fsys fs.FS) ([]byte, digest.Digest, error) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	digester := digest.Canonical.Digester()
+	if err := tar.WriteFS(fsys, io.MultiWriter(gzw, digester.Hash())); err != nil {
+		return nil, "", err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), digester.Digest(), nil
+}
+
+func pushBlobIfNotExist(ctx context.Context, store content.Storage, desc ocispec.Descriptor, data []byte) error {
+	exists, err := store.Exists(ctx, desc)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return store.Push(ctx, desc, bytes.NewReader(data))
+}
@@ -0,0 +1,109 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/pkg/sysregistriesv2"
+	"github.com/containers/image/v5/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	orasremote "oras.land/oras-go/v2/registry/remote"
+)
+
+// pullSources returns the sources to try, in order, when pulling ref. Unless
+// registries.conf is enabled, the only source is ref itself.
+func (c *Config) pullSources(ref reference.Named) ([]sysregistriesv2.PullSource, error) {
+	primary := []sysregistriesv2.PullSource{{Reference: ref}}
+	if !c.UseRegistriesConf && c.RegistriesConfPath == "" {
+		return primary, nil
+	}
+
+	sys := &types.SystemContext{SystemRegistriesConfPath: c.RegistriesConfPath}
+	reg, err := sysregistriesv2.FindRegistry(sys, ref.String())
+	if err != nil {
+		return nil, fmt.Errorf("load registries configuration: %v", err)
+	}
+	if reg == nil {
+		return primary, nil
+	}
+	if reg.Blocked {
+		return nil, fmt.Errorf("registry %s is blocked in %s", reference.Domain(ref), sysregistriesv2.ConfigurationSourceDescription(sys))
+	}
+	return reg.PullSourcesFromReference(ref)
+}
+
+// ResolveNameAndReference resolves nameAndReference for pulling. If
+// registries.conf is enabled, its mirrors are tried in order before the
+// primary location, and the repository of the first source that resolves the
+// reference is returned along with the (unrewritten) parsed reference.
+func (c *Config) ResolveNameAndReference(ctx context.Context, nameAndReference string) (*orasremote.Repository, reference.Reference, *ocispec.Descriptor, error) {
+	ref, err := reference.ParseNamed(nameAndReference)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	sources, err := c.pullSources(ref)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var errs []string
+	for _, src := range sources {
+		repo, desc, err := c.resolvePullSource(ctx, src)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", src.Reference, err))
+			continue
+		}
+		return repo, ref, desc, nil
+	}
+	return nil, nil, nil, fmt.Errorf("failed to resolve %s: %s", nameAndReference, strings.Join(errs, "; "))
+}
+
+func (c *Config) resolvePullSource(ctx context.Context, src sysregistriesv2.PullSource) (*orasremote.Repository, *ocispec.Descriptor, error) {
+	tagOrDigest, err := TagOrDigest(src.Reference)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !src.Endpoint.Insecure {
+		return c.resolve(ctx, src.Reference.Name(), tagOrDigest)
+	}
+
+	// As with containers/image, an insecure endpoint is tried over HTTPS
+	// without verifying its certificate, and then over plain HTTP.
+	host := reference.Domain(src.Reference)
+	rc := c.ForRegistry(host)
+	rc.InsecureSkipTLSVerify = true
+	repo, desc, err := c.withRegistry(host, rc).resolve(ctx, src.Reference.Name(), tagOrDigest)
+	if err == nil || rc.PlainHTTP {
+		return repo, desc, err
+	}
+	rc.PlainHTTP = true
+	repo, desc, httpErr := c.withRegistry(host, rc).resolve(ctx, src.Reference.Name(), tagOrDigest)
+	if httpErr != nil {
+		return nil, nil, fmt.Errorf("%v; over plain HTTP: %v", err, httpErr)
+	}
+	return repo, desc, nil
+}
+
+func (c *Config) resolve(ctx context.Context, repoName, tagOrDigest string) (*orasremote.Repository, *ocispec.Descriptor, error) {
+	repo, err := c.NewRepository(repoName)
+	if err != nil {
+		return nil, nil, err
+	}
+	desc, err := repo.Resolve(ctx, tagOrDigest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return repo, &desc, nil
+}
+
+func (c *Config) withRegistry(host string, rc RegistryConfig) *Config {
+	out := *c
+	out.Registries = make(map[string]RegistryConfig, len(c.Registries)+1)
+	for k, v := range c.Registries {
+		out.Registries[k] = v
+	}
+	out.Registries[host] = rc
+	return &out
+}
@@ -0,0 +1,257 @@
+package remote
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// writeRegistriesConf writes a registries.conf file with content data and
+// returns its path.
+func writeRegistriesConf(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "registries.conf")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPullSources(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		useRegistriesConf bool
+		registriesConf    string
+		ref               string
+		want              []string
+		wantInsecure      []bool
+		wantErr           string
+	}{
+		{
+			name:         "registries.conf disabled",
+			ref:          "example.com/foo/bar:v1",
+			want:         []string{"example.com/foo/bar:v1"},
+			wantInsecure: []bool{false},
+		},
+		{
+			name:              "registry not configured",
+			useRegistriesConf: true,
+			registriesConf:    "[[registry]]\nlocation = \"example.org\"\n",
+			ref:               "example.com/foo/bar:v1",
+			want:              []string{"example.com/foo/bar:v1"},
+			wantInsecure:      []bool{false},
+		},
+		{
+			name:              "mirrors before the primary location",
+			useRegistriesConf: true,
+			registriesConf: `[[registry]]
+location = "example.com"
+
+[[registry.mirror]]
+location = "mirror-1.example.com"
+
+[[registry.mirror]]
+location = "mirror-2.example.com/prefix"
+insecure = true
+`,
+			ref:          "example.com/foo/bar:v1",
+			want:         []string{"mirror-1.example.com/foo/bar:v1", "mirror-2.example.com/prefix/foo/bar:v1", "example.com/foo/bar:v1"},
+			wantInsecure: []bool{false, true, false},
+		},
+		{
+			name:              "prefix rewritten to another location",
+			useRegistriesConf: true,
+			registriesConf:    "[[registry]]\nprefix = \"example.com/foo\"\nlocation = \"other.example.com/bar\"\n",
+			ref:               "example.com/foo/baz@sha256:" + strings.Repeat("a", 64),
+			want:              []string{"other.example.com/bar/baz@sha256:" + strings.Repeat("a", 64)},
+			wantInsecure:      []bool{false},
+		},
+		{
+			name:              "mirrors by digest only",
+			useRegistriesConf: true,
+			registriesConf: `[[registry]]
+location = "example.com"
+
+[[registry.mirror]]
+location = "mirror.example.com"
+pull-from-mirror = "digest-only"
+`,
+			ref:          "example.com/foo/bar:v1",
+			want:         []string{"example.com/foo/bar:v1"},
+			wantInsecure: []bool{false},
+		},
+		{
+			name:              "blocked registry",
+			useRegistriesConf: true,
+			registriesConf:    "[[registry]]\nlocation = \"example.com\"\nblocked = true\n",
+			ref:               "example.com/foo/bar:v1",
+			wantErr:           "registry example.com is blocked in",
+		},
+		{
+			name:              "invalid registries.conf",
+			useRegistriesConf: true,
+			registriesConf:    "[[registry]\n",
+			ref:               "example.com/foo/bar:v1",
+			wantErr:           "load registries configuration",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewConfig()
+			if tc.useRegistriesConf {
+				cfg.RegistriesConfPath = writeRegistriesConf(t, tc.registriesConf)
+			}
+			ref, err := reference.ParseNamed(tc.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sources, err := cfg.pullSources(ref)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			var insecure []bool
+			for _, src := range sources {
+				got = append(got, src.Reference.String())
+				insecure = append(insecure, src.Endpoint.Insecure)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected sources %q, got %q", tc.want, got)
+			}
+			if !reflect.DeepEqual(insecure, tc.wantInsecure) {
+				t.Errorf("expected insecure %v, got %v", tc.wantInsecure, insecure)
+			}
+		})
+	}
+}
+
+// newManifestServer starts a plain HTTP registry that only resolves the
+// manifest of repoName:tag, and returns its host and port.
+func newManifestServer(t *testing.T, repoName, tag string, desc ocispec.Descriptor) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/v2/"+repoName+"/manifests/"+tag {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", desc.MediaType)
+		w.Header().Set("Content-Length", strconv.FormatInt(desc.Size, 10))
+		w.Header().Set("Docker-Content-Digest", desc.Digest.String())
+	}))
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestResolveNameAndReferenceMirrors(t *testing.T) {
+	manifest := `{"mediaType":"` + ocispec.MediaTypeArtifactManifest + `"}`
+	desc := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeArtifactManifest,
+		Digest:    digest.FromString(manifest),
+		Size:      int64(len(manifest)),
+	}
+	mirror := newManifestServer(t, "mirror/foo/bar", "v1", desc)
+	// A server that is not running refuses connections.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	downHost := strings.TrimPrefix(down.URL, "http://")
+
+	mirrorConf := func(mirrors ...string) string {
+		conf := "[[registry]]\nlocation = \"example.invalid\"\n"
+		for _, m := range mirrors {
+			conf += "\n[[registry.mirror]]\n" + m + "\n"
+		}
+		return conf
+	}
+	for _, tc := range []struct {
+		name           string
+		registriesConf string
+		wantRepo       string
+		wantErr        []string
+	}{
+		{
+			name:           "insecure mirror over plain HTTP",
+			registriesConf: mirrorConf(`location = "` + mirror + `/mirror"` + "\ninsecure = true"),
+			wantRepo:       mirror + "/mirror/foo/bar",
+		},
+		{
+			name: "falls back to the next mirror",
+			registriesConf: mirrorConf(
+				`location = "`+downHost+`/mirror"`+"\ninsecure = true",
+				`location = "`+mirror+`/missing"`+"\ninsecure = true",
+				`location = "`+mirror+`/mirror"`+"\ninsecure = true",
+			),
+			wantRepo: mirror + "/mirror/foo/bar",
+		},
+		{
+			name:           "secure mirror is not accessed over plain HTTP",
+			registriesConf: mirrorConf(`location = "` + mirror + `/mirror"`),
+			wantErr: []string{
+				"failed to resolve example.invalid/foo/bar:v1",
+				mirror + "/mirror/foo/bar:v1: ",
+				"example.invalid/foo/bar:v1: ",
+			},
+		},
+		{
+			name:           "all sources fail",
+			registriesConf: mirrorConf(`location = "` + downHost + `/mirror"` + "\ninsecure = true"),
+			wantErr: []string{
+				downHost + "/mirror/foo/bar:v1: ",
+				"over plain HTTP",
+				"example.invalid/foo/bar:v1: ",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewConfig()
+			cfg.Retry = RetryPolicy{}
+			cfg.RegistriesConfPath = writeRegistriesConf(t, tc.registriesConf)
+			cfg.Credential = func(context.Context, string) (auth.Credential, error) {
+				return auth.EmptyCredential, nil
+			}
+
+			repo, ref, got, err := cfg.ResolveNameAndReference(context.Background(), "example.invalid/foo/bar:v1")
+			if len(tc.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				for _, want := range tc.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("expected error containing %q, got %v", want, err)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name := repo.Reference.Registry + "/" + repo.Reference.Repository; name != tc.wantRepo {
+				t.Errorf("expected repository %q, got %q", tc.wantRepo, name)
+			}
+			if !repo.PlainHTTP {
+				t.Error("expected the repository to be accessed over plain HTTP")
+			}
+			if ref.String() != "example.invalid/foo/bar:v1" {
+				t.Errorf("expected the unrewritten reference, got %q", ref)
+			}
+			if got.Digest != desc.Digest {
+				t.Errorf("expected digest %s, got %s", desc.Digest, got.Digest)
+			}
+		})
+	}
+}
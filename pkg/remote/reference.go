@@ -35,19 +35,5 @@ func ParseNameAndReference(nameAndReference string) (*orasremote.Repository, ref
 }
 
 func ResolveNameAndReference(ctx context.Context, nameAndReference string) (*orasremote.Repository, reference.Reference, *ocispec.Descriptor, error) {
-	repo, ref, err := ParseNameAndReference(nameAndReference)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	tagOrDigest, err := TagOrDigest(ref)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-
-	desc, err := repo.Resolve(ctx, tagOrDigest)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to resolve %s: %v", nameAndReference, err)
-	}
-	return repo, ref, &desc, nil
+	return DefaultConfig.ResolveNameAndReference(ctx, nameAndReference)
 }
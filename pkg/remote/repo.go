@@ -1,44 +1,41 @@
 package remote
 
 import (
-	"context"
-	"sync"
+	"fmt"
 
-	"github.com/containers/image/v5/docker/reference"
-	"github.com/containers/image/v5/pkg/docker/config"
-	"github.com/docker/cli/cli/config/configfile"
 	"oras.land/oras-go/v2/registry/remote"
 	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/retry"
 )
 
-var (
-	loadOnce      sync.Once
-	dockerCfg     *configfile.ConfigFile
-	loadConfigErr error
-)
-
-func getCredentials(repoName string) func(context.Context, string) (auth.Credential, error) {
-	return func(ctx context.Context, _ string) (auth.Credential, error) {
-		ref, err := reference.ParseNamed(repoName)
-		if err != nil {
-			return auth.Credential{}, err
-		}
-		authConfig, err := config.GetCredentialsForRef(nil, ref)
-		if err != nil {
-			return auth.Credential{}, err
-		}
-		return auth.Credential{
-			Username: authConfig.Username,
-			Password: authConfig.Password,
-		}, nil
-	}
+func NewRepository(repoName string) (*remote.Repository, error) {
+	return DefaultConfig.NewRepository(repoName)
 }
 
-func NewRepository(repoName string) (*remote.Repository, error) {
+// NewRepository returns a client for the repository repoName, using the
+// transport settings configured for its registry.
+func (c *Config) NewRepository(repoName string) (*remote.Repository, error) {
 	repo, err := remote.NewRepository(repoName)
 	if err != nil {
 		return nil, err
 	}
-	repo.Client = &auth.Client{Credential: getCredentials(repoName)}
+	rc := c.ForRegistry(repo.Reference.Registry)
+	httpClient, err := rc.HTTPClient()
+	if err != nil {
+		return nil, fmt.Errorf("configure client for registry %s: %v", repo.Reference.Registry, err)
+	}
+	httpClient.Transport = &retry.Transport{
+		Base:   httpClient.Transport,
+		Policy: func() retry.Policy { return c.Retry },
+	}
+	credential := c.Credential
+	if credential == nil {
+		credential = getCredentials(repoName)
+	}
+	repo.PlainHTTP = rc.PlainHTTP
+	repo.Client = &auth.Client{
+		Client:     httpClient,
+		Credential: credential,
+	}
 	return repo, nil
 }
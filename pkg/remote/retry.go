@@ -0,0 +1,136 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+	"oras.land/oras-go/v2/registry/remote/retry"
+)
+
+// DefaultRetryPolicy is the retry policy used when a Config does not set one.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 5,
+	MinBackoff: metav1.Duration{Duration: 250 * time.Millisecond},
+	MaxBackoff: metav1.Duration{Duration: 10 * time.Second},
+	Jitter:     0.2,
+}
+
+var _ retry.Policy = RetryPolicy{}
+
+// RetryPolicy configures how failed registry operations are retried. Waits
+// between attempts grow exponentially from MinBackoff up to MaxBackoff, and
+// are randomly spread by up to +/- Jitter of their length.
+type RetryPolicy struct {
+	MaxRetries int             `json:"maxRetries"`
+	MinBackoff metav1.Duration `json:"minBackoff"`
+	MaxBackoff metav1.Duration `json:"maxBackoff"`
+	Jitter     float64         `json:"jitter"`
+}
+
+// Backoff returns how long to wait before retry number attempt (starting at
+// zero).
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	d := float64(p.MinBackoff.Duration) * math.Pow(2, float64(attempt))
+	if p.MaxBackoff.Duration > 0 && d > float64(p.MaxBackoff.Duration) {
+		d = float64(p.MaxBackoff.Duration)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// Retry implements retry.Policy so that p can also be used to retry
+// individual HTTP requests.
+func (p RetryPolicy) Retry(attempt int, resp *http.Response, err error) (time.Duration, error) {
+	if attempt >= p.MaxRetries {
+		return -1, nil
+	}
+	if err != nil {
+		if !IsRetryable(err) {
+			return -1, nil
+		}
+		return p.Backoff(attempt), nil
+	}
+	if !retryableStatus(resp.StatusCode) {
+		return -1, nil
+	}
+	return p.Backoff(attempt), nil
+}
+
+// Do calls op until it succeeds, returns an error that is not retryable, or
+// the policy's retries are exhausted. Errors wrapped with Permanent are
+// returned, unwrapped, without retrying.
+func (p RetryPolicy) Do(ctx context.Context, op func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := op()
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if err == nil || attempt >= p.MaxRetries || !IsRetryable(err) {
+			return err
+		}
+		if err := sleep(ctx, p.Backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// Permanent wraps err so that RetryPolicy.Do returns it without retrying,
+// for example because it has already been retried at a lower level.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// IsRetryable reports whether err is likely to be transient, such as a 5xx
+// or 429 response from the registry, a timeout or a dropped connection.
+func IsRetryable(err error) bool {
+	if err == nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, errdef.ErrNotFound) ||
+		errors.Is(err, errdef.ErrAlreadyExists) {
+		return false
+	}
+	var respErr *errcode.ErrorResponse
+	if errors.As(err, &respErr) {
+		return retryableStatus(respErr.StatusCode)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout ||
+		code == http.StatusTooManyRequests ||
+		code >= http.StatusInternalServerError
+}
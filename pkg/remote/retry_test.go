@@ -0,0 +1,156 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"oras.land/oras-go/v2/errdef"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+func testPolicy(maxRetries int) RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: maxRetries,
+		MinBackoff: metav1.Duration{Duration: time.Millisecond},
+		MaxBackoff: metav1.Duration{Duration: 4 * time.Millisecond},
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := testPolicy(5)
+	for _, tc := range []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 0, want: time.Millisecond},
+		{attempt: 1, want: 2 * time.Millisecond},
+		{attempt: 2, want: 4 * time.Millisecond},
+		{attempt: 3, want: 4 * time.Millisecond},
+		{attempt: 100, want: 4 * time.Millisecond},
+	} {
+		if got := p.Backoff(tc.attempt); got != tc.want {
+			t.Errorf("attempt %d: expected %v, got %v", tc.attempt, tc.want, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.Backoff(1); got < time.Millisecond || got > 3*time.Millisecond {
+			t.Fatalf("expected jittered backoff within 50%% of 2ms, got %v", got)
+		}
+	}
+}
+
+func TestRetryPolicyRetry(t *testing.T) {
+	p := testPolicy(2)
+	for _, tc := range []struct {
+		name    string
+		attempt int
+		status  int
+		err     error
+		want    bool
+	}{
+		{name: "server error", status: http.StatusServiceUnavailable, want: true},
+		{name: "too many requests", status: http.StatusTooManyRequests, want: true},
+		{name: "request timeout", status: http.StatusRequestTimeout, want: true},
+		{name: "not found", status: http.StatusNotFound},
+		{name: "success", status: http.StatusOK},
+		{name: "connection reset", err: syscall.ECONNRESET, want: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "retries exhausted", attempt: 2, status: http.StatusServiceUnavailable},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp *http.Response
+			if tc.err == nil {
+				resp = &http.Response{StatusCode: tc.status}
+			}
+			d, err := p.Retry(tc.attempt, resp, tc.err)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := d >= 0; got != tc.want {
+				t.Errorf("expected retry %t, got backoff %v", tc.want, d)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil"},
+		{name: "bad gateway", err: &errcode.ErrorResponse{StatusCode: http.StatusBadGateway}, want: true},
+		{name: "unauthorized", err: &errcode.ErrorResponse{StatusCode: http.StatusUnauthorized}},
+		{name: "wrapped unexpected EOF", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "connection refused", err: syscall.ECONNREFUSED, want: true},
+		{name: "broken pipe", err: syscall.EPIPE, want: true},
+		{name: "not found", err: errdef.ErrNotFound},
+		{name: "deadline exceeded", err: context.DeadlineExceeded},
+		{name: "other", err: errors.New("boom")},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsRetryable(tc.err); got != tc.want {
+				t.Errorf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	transient := &errcode.ErrorResponse{StatusCode: http.StatusServiceUnavailable}
+	permanentCause := errors.New("already retried")
+	for _, tc := range []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success", errs: []error{nil}, wantCalls: 1},
+		{name: "transient then success", errs: []error{transient, transient, nil}, wantCalls: 3},
+		{name: "retries exhausted", errs: []error{transient, transient, transient, transient}, wantCalls: 3, wantErr: transient},
+		{name: "not retryable", errs: []error{errdef.ErrNotFound}, wantCalls: 1, wantErr: errdef.ErrNotFound},
+		{name: "permanent", errs: []error{Permanent(permanentCause)}, wantCalls: 1, wantErr: permanentCause},
+		{name: "permanent transient error", errs: []error{Permanent(transient)}, wantCalls: 1, wantErr: transient},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			err := testPolicy(2).Do(context.Background(), func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+			if calls != tc.wantCalls {
+				t.Errorf("expected %d calls, got %d", tc.wantCalls, calls)
+			}
+			if err != tc.wantErr {
+				t.Errorf("expected error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := testPolicy(5)
+	p.MinBackoff.Duration = time.Hour
+	p.MaxBackoff.Duration = time.Hour
+	calls := 0
+	err := p.Do(ctx, func() error {
+		calls++
+		cancel()
+		return &errcode.ErrorResponse{StatusCode: http.StatusServiceUnavailable}
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("expected one call and context.Canceled, got %d calls and %v", calls, err)
+	}
+}
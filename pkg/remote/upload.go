@@ -0,0 +1,244 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// DefaultChunkSize is the chunk size used by PushBlobChunked when a Config
+// does not set one. Blobs at least this large are uploaded in chunks.
+const DefaultChunkSize = 16 << 20
+
+// PushBlobChunked uploads the blob described by desc to repo in chunks of
+// chunkSize bytes. If a chunk fails with a retryable error, the upload
+// session is queried for the number of bytes the registry has already
+// received, and the upload resumes from that offset after a backoff. open is
+// called to (re)open the blob's content each time the upload resumes.
+//
+// Only chunk uploads are retried here, according to policy. The other
+// requests are retried by repo's transport.
+func PushBlobChunked(ctx context.Context, repo *remote.Repository, desc ocispec.Descriptor, open func() (io.ReadCloser, error), chunkSize int64, policy RetryPolicy) error {
+	ctx = auth.AppendScopes(ctx, auth.ScopeRepository(repo.Reference.Repository, auth.ActionPull, auth.ActionPush))
+	u := uploader{repo: repo, client: repo.Client}
+	if u.client == nil {
+		u.client = http.DefaultClient
+	}
+
+	location, err := u.start(ctx)
+	if err != nil {
+		return fmt.Errorf("start upload: %w", err)
+	}
+
+	var (
+		offset int64
+		rc     io.ReadCloser
+	)
+	defer func() {
+		if rc != nil {
+			rc.Close()
+		}
+	}()
+	buf := make([]byte, chunkSize)
+	for attempt := 0; offset < desc.Size; {
+		if rc == nil {
+			var err error
+			if rc, err = open(); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(io.Discard, rc, offset); err != nil {
+				return fmt.Errorf("seek to offset %d: %w", offset, err)
+			}
+		}
+
+		n, err := io.ReadFull(rc, buf[:minInt64(chunkSize, desc.Size-offset)])
+		if err != nil {
+			return fmt.Errorf("read content: %w", err)
+		}
+		next, err := u.patch(ctx, location, offset, buf[:n])
+		if err == nil {
+			location, offset, attempt = next, offset+int64(n), 0
+			continue
+		}
+		if attempt >= policy.MaxRetries || !IsRetryable(err) {
+			return fmt.Errorf("upload chunk at offset %d: %w", offset, err)
+		}
+
+		if err := sleep(ctx, policy.Backoff(attempt)); err != nil {
+			return err
+		}
+		attempt++
+		if loc, received, statusErr := u.status(ctx, location, offset); statusErr == nil {
+			location, offset = loc, received
+		}
+		rc.Close()
+		rc = nil
+	}
+
+	if err := u.finish(ctx, location, desc); err != nil {
+		// If an earlier attempt of the request succeeded but its response
+		// was lost, the upload session no longer exists but the blob does.
+		if exists, existsErr := repo.Exists(ctx, desc); existsErr == nil && exists {
+			return nil
+		}
+		return fmt.Errorf("finish upload: %w", err)
+	}
+	return nil
+}
+
+type uploader struct {
+	repo   *remote.Repository
+	client remote.Client
+}
+
+func (u uploader) baseURL() *url.URL {
+	scheme := "https"
+	if u.repo.PlainHTTP {
+		scheme = "http"
+	}
+	return &url.URL{Scheme: scheme, Host: u.repo.Reference.Host()}
+}
+
+func (u uploader) do(req *http.Request, expectStatus int) (*http.Response, error) {
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expectStatus {
+		return nil, &errcode.ErrorResponse{
+			Method:     req.Method,
+			URL:        req.URL,
+			StatusCode: resp.StatusCode,
+		}
+	}
+	return resp, nil
+}
+
+func (u uploader) location(req *http.Request, resp *http.Response) (*url.URL, error) {
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return nil, fmt.Errorf("missing upload location in response")
+	}
+	return req.URL.Parse(loc)
+}
+
+func (u uploader) start(ctx context.Context) (*url.URL, error) {
+	startURL := u.baseURL().JoinPath("v2", u.repo.Reference.Repository, "blobs", "uploads")
+	startURL.Path += "/"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, startURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.do(req, http.StatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+	return u.location(req, resp)
+}
+
+func (u uploader) patch(ctx context.Context, location *url.URL, offset int64, chunk []byte) (*url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, location.String(), bytes.NewReader(chunk))
+	if err != nil {
+		return nil, err
+	}
+	// A failed chunk may have been partially received, so it is resumed from
+	// the registry's offset instead of being resent by the transport.
+	req.GetBody = nil
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+int64(len(chunk))-1))
+	resp, err := u.do(req, http.StatusAccepted)
+	if err != nil {
+		return nil, err
+	}
+	return u.location(req, resp)
+}
+
+// status returns the upload location and the number of bytes the registry
+// has received so far. confirmed is the number of bytes whose receipt the
+// registry has already acknowledged.
+func (u uploader) status(ctx context.Context, location *url.URL, confirmed int64) (*url.URL, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := u.do(req, http.StatusNoContent)
+	if err != nil {
+		return nil, 0, err
+	}
+	loc, err := u.location(req, resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	received, err := parseUploadRange(resp.Header.Get("Range"), confirmed)
+	if err != nil {
+		return nil, 0, err
+	}
+	return loc, received, nil
+}
+
+// parseUploadRange returns the number of bytes received by an upload whose
+// status has the Range header rng. Registries report an empty upload as
+// "0-0", the same as an upload of one byte, so "0-0" is treated as empty
+// unless confirmed, the number of bytes known to have been received, is
+// positive.
+func parseUploadRange(rng string, confirmed int64) (int64, error) {
+	if rng == "" {
+		return 0, nil
+	}
+	start, end, ok := strings.Cut(rng, "-")
+	if !ok || start != "0" {
+		return 0, fmt.Errorf("invalid upload range %q", rng)
+	}
+	last, err := strconv.ParseInt(end, 10, 64)
+	if err != nil || last < 0 {
+		return 0, fmt.Errorf("invalid upload range %q", rng)
+	}
+	if last == 0 && confirmed == 0 {
+		return 0, nil
+	}
+	return last + 1, nil
+}
+
+func (u uploader) finish(ctx context.Context, location *url.URL, desc ocispec.Descriptor) error {
+	finishURL := *location
+	q := finishURL.Query()
+	q.Set("digest", desc.Digest.String())
+	finishURL.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, finishURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	_, err = u.do(req, http.StatusCreated)
+	return err
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
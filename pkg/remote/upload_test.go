@@ -0,0 +1,40 @@
+package remote
+
+import "testing"
+
+func TestParseUploadRange(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		rng       string
+		confirmed int64
+		want      int64
+		wantErr   bool
+	}{
+		{name: "no range", rng: "", want: 0},
+		{name: "empty upload", rng: "0-0", want: 0},
+		{name: "one byte confirmed", rng: "0-0", confirmed: 1, want: 1},
+		{name: "partial upload", rng: "0-4095", want: 4096},
+		{name: "partial upload confirmed", rng: "0-4095", confirmed: 4096, want: 4096},
+		{name: "nonzero start", rng: "1-4095", wantErr: true},
+		{name: "bytes unit", rng: "bytes=0-4095", wantErr: true},
+		{name: "no separator", rng: "4095", wantErr: true},
+		{name: "negative end", rng: "0--1", wantErr: true},
+		{name: "non-numeric end", rng: "0-abc", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseUploadRange(tc.rng, tc.confirmed)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package tar
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/joelanford/olm-oci/pkg/memfs"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// ApplyLayer returns the result of applying the container image layer read
+// from r on top of fsys (which may be nil), honoring whiteout files. fsys
+// itself is not modified. Only regular files and directories are kept, and
+// only the paths for which include returns true (or all paths, if include is
+// nil).
+func ApplyLayer(fsys fs.FS, r io.Reader, include func(name string) bool) (fs.FS, error) {
+	out, err := memfs.Copy(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("copy base file system: %w", err)
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read layer: %w", err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+			continue
+		}
+		dir, base := path.Split(name)
+		dir = path.Clean(dir)
+		if base == whiteoutOpaque {
+			out.RemoveContents(dir)
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			out.RemoveAll(path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			continue
+		}
+		if include != nil && !include(name) {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			out.Mkdir(name, fs.FileMode(hdr.Mode), hdr.ModTime)
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", name, err)
+			}
+			out.WriteFile(name, data, fs.FileMode(hdr.Mode), hdr.ModTime)
+		}
+	}
+}